@@ -1,13 +1,23 @@
 package client
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"runtime"
+	"strings"
+	"zzschain/core"
 	"zzschain/wallet"
 )
 
+//walletDirEnv 钱包数据目录的环境变量，不设置时为wallet.DataDir
+const walletDirEnv = "WALLET_DIR"
+
+//walletUsage 钱包命令-wallet参数的说明
+const walletUsage = "钱包名称（必须指定），钱包先用createwallet创建；旧版本的wallet_节点ID.dat用节点ID作为名称"
+
 // CLI 响应处理命令行参数
 type CLI struct {
 	NodeId  string
@@ -17,8 +27,60 @@ type CLI struct {
 // printUsage 打印命令行帮助信息
 func (cli *CLI) printUsage() {
 	fmt.Println("Usage:")
-	fmt.Println("   send -from FROM -to TO -amount AMOUNT -mine - 发送amount数量的币，从地址FROM到TO,如果设定了-mine，则由本节点完成挖矿")
-	fmt.Println("   startnode -port NodeId -miner Address - 通过特定的环境变量NODE_ID启动一个节点，可选参数：-miner启动挖矿")
+	fmt.Println("   send -from FROM -to TO -amount AMOUNT -fee FEE -rbf -data DATA -mine - 发送amount数量的币，从地址FROM到TO,如果设定了-mine，则由本节点完成挖矿，-data附带上链的数据，-rbf声明交易可被替换，TO可以是通讯录中的联系人名称，-signer由外部签名者签名")
+	fmt.Println("        [-selector largest|smallest|bnb|random] [-inputs TXID:VOUT,...] - 选择选币策略，或手动指定交易输入")
+	fmt.Println("   sendmany -from FROM -file FILE -fee FEE -rbf -selector SELECTOR -dryrun -signer SIGNER -mine - 在一笔交易中向FILE（CSV或JSON）中的全部收款人付款，-dryrun只校验并显示总额与手续费")
+	fmt.Println("   bumpfee -txid TXID -fee FEE - 以更高的手续费FEE替换一笔声明了可替换且尚未上链的交易")
+	fmt.Println("   startnode -port NodeId -miner Address -admin -signer SIGNER -wallet NAME,... - 通过特定的环境变量NODE_ID启动一个节点，可选参数：-miner启动挖矿，-admin开放管理接口，-signer由外部签名者为HTTP转账签名，-wallet启动时加载的钱包")
+	fmt.Println("   signer -socket PATH - 作为外部签名程序运行，对每个签名请求显示交易摘要并由用户批准；不指定-socket时经标准输入输出通信（SIGNER为unix:PATH或exec:命令行）")
+	fmt.Println("   createpsbt -from FROM -to TO -amount AMOUNT | -file FILE -fee FEE -out PSBT - 在没有私钥的节点上创建部分签名交易")
+	fmt.Println("   signpsbt -in PSBT -out PSBT - 使用本地钱包文件签名部分签名交易，不需要区块链数据")
+	fmt.Println("   combinepsbt -in PSBT1,PSBT2,... -out PSBT - 合并多个签名者的签名")
+	fmt.Println("   finalizepsbt -in PSBT -send -mine -miner ADDRESS - 校验签名得到完整交易，-send广播交易，-mine在本节点挖矿并把奖励付给-miner地址")
+	fmt.Println("   createrawtransaction -inputs TXID:VOUT,... -outputs ADDRESS:AMOUNT,... -data DATA -locktime N -rbf - 创建未签名的原始交易")
+	fmt.Println("   decoderawtransaction -hex HEX - 将原始交易解码为人可读的JSON")
+	fmt.Println("   signrawtransaction -hex HEX -privkey KEY - 使用私钥签名原始交易")
+	fmt.Println("   sendrawtransaction -hex HEX -node ADDRESS - 将签名完成的原始交易提交到节点的交易池")
+	fmt.Println("   getbalance -address ADDRESS - 查询地址的余额，本地钱包的地址同时计入其找零地址")
+	fmt.Println("   gethistory -address ADDRESS - 显示地址所属账户的交易记录")
+	fmt.Println("   listaddresses - 列出钱包文件中的地址、标签与余额，找零地址列在所属账户之下")
+	fmt.Println("   setlabel -address ADDRESS -label LABEL - 为钱包中的地址设置标签，标签为空时删除")
+	fmt.Println("   addcontact -name NAME -address ADDRESS - 将收款地址加入通讯录，send的-to可以使用联系人名称")
+	fmt.Println("   removecontact -name NAME - 从通讯录中删除联系人")
+	fmt.Println("   listcontacts - 列出通讯录中的联系人")
+	fmt.Println("   importaddress -address ADDRESS | -pubkey PUBKEY - 导入只读地址，可以查询余额与交易记录，但不能签名")
+	fmt.Println("   importprivkey -privkey KEY -rescan - 导入带校验码的私钥，默认扫描区块链找出该地址的交易与余额")
+	fmt.Println("   dumpprivkey -address ADDRESS - 导出地址带校验码的私钥")
+	fmt.Println("   convertkeys - 为旧版本编码公钥的地址生成SEC1压缩公钥的新地址（以F开头）")
+	fmt.Println("   exportkeystore -address ADDRESS -passphrase PASS -out FILE - 将地址的私钥导出为以太坊keystore v3文件")
+	fmt.Println("   importkeystore -file FILE -passphrase PASS -rescan - 导入以太坊keystore v3文件中的私钥，默认扫描区块链")
+	fmt.Println("   migratekeystore -passphrase PASS - 将钱包文件转换为目录，每个私钥一个用钱包密码加密的keystore文件，放入目录的keystore文件解锁时自动导入")
+	fmt.Println("   rescanwallet -from HEIGHT - 从指定区块号开始遍历一次区块链，为钱包的全部地址重建交易与未花费输出缓存，不指定时从上次中断处继续")
+	fmt.Println("   signmessage -address ADDRESS -message MESSAGE - 用地址的私钥签名消息，证明对地址的控制权")
+	fmt.Println("   verifymessage -address ADDRESS -signature SIGNATURE -message MESSAGE - 验证消息签名")
+	fmt.Println("   createhdwallet -words 12|24 -seedpass PASS - 生成助记词，之后新地址都由助记词派生")
+	fmt.Println("   restorewallet -mnemonic WORDS -seedpass PASS -gap N - 由助记词恢复钱包，重新派生地址并扫描区块链")
+	fmt.Println("   restorewallet -shares SHARE,SHARE,... | -sharefile FILE - 由任意门限个备份分片恢复种子与私钥，并校验地址指纹")
+	fmt.Println("   backupwallet -shares N -threshold K -format hex|words - 将钱包的种子与私钥分为N个带校验码的分片，任意K个分片可以恢复")
+	fmt.Println("   getnewaddress -type p256|secp256k1 - 创建新地址，分层确定性钱包从收款链派生，secp256k1地址以S开头，需链参数激活后才能使用")
+	fmt.Println("   getnewstealthaddress - 创建隐身地址（以Y开头），付款人每次付款都派生新的一次性地址，需链参数激活后才能使用")
+	fmt.Println("   liststealthaddresses - 列出钱包的隐身地址，以及扫描找到的一次性地址与余额")
+	fmt.Println("   scanstealth -from HEIGHT - 扫描区块链，找出付给钱包隐身地址的输出并将一次性地址的私钥加入钱包，不指定时从上次扫描到的位置继续")
+	fmt.Println("   vanityaddress -prefix PREFIX -suffix SUFFIX -ignorecase -type p256|secp256k1 -workers N - 并行生成私钥直到地址匹配前缀或后缀（前缀包括开头的F或S），先显示难度与预计用时，找到后加入钱包")
+	fmt.Println("   encryptwallet -passphrase PASS - 用密码加密钱包文件中的私钥，之后签名需要解锁（命令行可设置环境变量WALLET_PASSPHRASE）")
+	fmt.Println("   walletpassphrasechange -old OLD -new NEW - 修改钱包密码")
+	fmt.Println("   walletunlock -passphrase PASS -timeout SECONDS -node ADDRESS - 解锁节点的加密钱包，超时后自动锁定")
+	fmt.Println("   walletlock -node ADDRESS - 立即锁定节点的加密钱包")
+	fmt.Println("   createwallet -name NAME - 在钱包数据目录中创建空的命名钱包，之后钱包命令用-wallet NAME使用该钱包")
+	fmt.Println("   loadwallet -name NAME -node ADDRESS - 由节点加载钱包，节点的钱包接口用查询参数wallet=NAME指定钱包")
+	fmt.Println("   unloadwallet -name NAME -node ADDRESS - 由节点卸载钱包，已解锁的加密钱包同时锁定")
+	fmt.Println("   listwallets -node ADDRESS - 列出钱包数据目录中的钱包，指定-node时同时显示节点已加载的钱包")
+	fmt.Println("   钱包命令均须用-wallet NAME指定钱包，钱包与节点端口无关；旧版本的钱包文件wallet_节点ID.dat用节点ID作为名称；钱包数据目录由环境变量WALLET_DIR指定，默认为./tmp/wallet")
+	fmt.Println("   initiate -from FROM -to TO -amount AMOUNT -locktime BLOCKS -mine - 发起原子交换，生成秘密并锁定amount给TO")
+	fmt.Println("   participate -from FROM -to TO -amount AMOUNT -hash HASH -locktime BLOCKS -mine - 使用对方的秘密哈希参与原子交换")
+	fmt.Println("   redeem -address ADDRESS -contract TXID -secret SECRET -mine - 公开秘密，赎回合约")
+	fmt.Println("   refund -address ADDRESS -contract TXID -mine - 合约超时后取回锁定的币")
+	fmt.Println("   extractsecret -tx TXID | -contract TXID -hash HASH - 从赎回交易中提取秘密")
 }
 
 // validateArgs 校验命令，如果无效，打印使用说明
@@ -33,17 +95,236 @@ func (cli *CLI) validateArgs() {
 // 使用标准库里面的 flag 包来解析命令行参数：
 func (cli *CLI) Run() {
 	cli.validateArgs()
+	if cli.NodeId == "" { //未指定节点时，从环境变量NODE_ID读取，以便在不同的链（节点）上执行命令
+		cli.NodeId = os.Getenv("NODE_ID")
+	}
+	if dir := os.Getenv(walletDirEnv); dir != "" { //钱包数据目录
+		if err := wallet.SetDataDir(dir); err != nil {
+			log.Panic(err)
+		}
+	}
 
 	//定义名称为"sendCmd"的空的flagset集合
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
 	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+	sendManyCmd := flag.NewFlagSet("sendmany", flag.ExitOnError)
+	signerCmd := flag.NewFlagSet("signer", flag.ExitOnError)
+	bumpFeeCmd := flag.NewFlagSet("bumpfee", flag.ExitOnError)
+	initiateCmd := flag.NewFlagSet("initiate", flag.ExitOnError)
+	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
+	getHistoryCmd := flag.NewFlagSet("gethistory", flag.ExitOnError)
+	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+	importAddressCmd := flag.NewFlagSet("importaddress", flag.ExitOnError)
+	setLabelCmd := flag.NewFlagSet("setlabel", flag.ExitOnError)
+	addContactCmd := flag.NewFlagSet("addcontact", flag.ExitOnError)
+	removeContactCmd := flag.NewFlagSet("removecontact", flag.ExitOnError)
+	listContactsCmd := flag.NewFlagSet("listcontacts", flag.ExitOnError)
+	importPrivKeyCmd := flag.NewFlagSet("importprivkey", flag.ExitOnError)
+	dumpPrivKeyCmd := flag.NewFlagSet("dumpprivkey", flag.ExitOnError)
+	rescanWalletCmd := flag.NewFlagSet("rescanwallet", flag.ExitOnError)
+	convertKeysCmd := flag.NewFlagSet("convertkeys", flag.ExitOnError)
+	exportKeystoreCmd := flag.NewFlagSet("exportkeystore", flag.ExitOnError)
+	importKeystoreCmd := flag.NewFlagSet("importkeystore", flag.ExitOnError)
+	migrateKeystoreCmd := flag.NewFlagSet("migratekeystore", flag.ExitOnError)
+	signMessageCmd := flag.NewFlagSet("signmessage", flag.ExitOnError)
+	verifyMessageCmd := flag.NewFlagSet("verifymessage", flag.ExitOnError)
+	createHDWalletCmd := flag.NewFlagSet("createhdwallet", flag.ExitOnError)
+	restoreWalletCmd := flag.NewFlagSet("restorewallet", flag.ExitOnError)
+	backupWalletCmd := flag.NewFlagSet("backupwallet", flag.ExitOnError)
+	getNewAddressCmd := flag.NewFlagSet("getnewaddress", flag.ExitOnError)
+	getNewAddressType := getNewAddressCmd.String("type", "p256", "私钥的曲线：p256或secp256k1")
+	encryptWalletCmd := flag.NewFlagSet("encryptwallet", flag.ExitOnError)
+	passphraseChangeCmd := flag.NewFlagSet("walletpassphrasechange", flag.ExitOnError)
+	walletUnlockCmd := flag.NewFlagSet("walletunlock", flag.ExitOnError)
+	walletLockCmd := flag.NewFlagSet("walletlock", flag.ExitOnError)
+	createRawCmd := flag.NewFlagSet("createrawtransaction", flag.ExitOnError)
+	decodeRawCmd := flag.NewFlagSet("decoderawtransaction", flag.ExitOnError)
+	signRawCmd := flag.NewFlagSet("signrawtransaction", flag.ExitOnError)
+	sendRawCmd := flag.NewFlagSet("sendrawtransaction", flag.ExitOnError)
+	createPSBTCmd := flag.NewFlagSet("createpsbt", flag.ExitOnError)
+	signPSBTCmd := flag.NewFlagSet("signpsbt", flag.ExitOnError)
+	combinePSBTCmd := flag.NewFlagSet("combinepsbt", flag.ExitOnError)
+	finalizePSBTCmd := flag.NewFlagSet("finalizepsbt", flag.ExitOnError)
+	participateCmd := flag.NewFlagSet("participate", flag.ExitOnError)
+	redeemCmd := flag.NewFlagSet("redeem", flag.ExitOnError)
+	refundCmd := flag.NewFlagSet("refund", flag.ExitOnError)
+	extractSecretCmd := flag.NewFlagSet("extractsecret", flag.ExitOnError)
+	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
+	loadWalletCmd := flag.NewFlagSet("loadwallet", flag.ExitOnError)
+	unloadWalletCmd := flag.NewFlagSet("unloadwallet", flag.ExitOnError)
+	listWalletsCmd := flag.NewFlagSet("listwallets", flag.ExitOnError)
+	vanityAddressCmd := flag.NewFlagSet("vanityaddress", flag.ExitOnError)
+	getNewStealthAddressCmd := flag.NewFlagSet("getnewstealthaddress", flag.ExitOnError)
+	listStealthAddressesCmd := flag.NewFlagSet("liststealthaddresses", flag.ExitOnError)
+	scanStealthCmd := flag.NewFlagSet("scanstealth", flag.ExitOnError)
 
 	sendFrom := sendCmd.String("from", "", "钱包源地址")
-	sendTo := sendCmd.String("to", "", "钱包目的地址")
+	sendTo := sendCmd.String("to", "", "钱包目的地址、隐身地址或通讯录中的联系人名称")
 	sendAmount := sendCmd.Int("amount", 0, "转移资金的数量")
 	sendMine := sendCmd.Bool("mine", false, "在该节点立即挖矿")
+	sendData := sendCmd.String("data", "", "附带上链的数据（如文档哈希），带0x前缀按hex解析")
+	sendFee := sendCmd.Int("fee", 0, "支付给矿工的手续费")
+	sendRBF := sendCmd.Bool("rbf", false, "声明交易可被替换，以便之后使用bumpfee提高手续费")
+	sendSelector := sendCmd.String("selector", "", "选币策略：largest、smallest、bnb（默认）、random")
+	sendInputs := sendCmd.String("inputs", "", "手动指定输入，格式为txid:vout，多个以逗号分隔")
+	sendSigner := sendCmd.String("signer", "", "外部签名者：unix:套接字路径，或exec:签名程序的命令行")
+	sendManyFrom := sendManyCmd.String("from", "", "钱包源地址")
+	sendManyFile := sendManyCmd.String("file", "", "付款列表文件：CSV（地址,金额）或JSON（[{\"address\":...,\"amount\":...}]）")
+	sendManyFee := sendManyCmd.Int("fee", 0, "支付给矿工的手续费")
+	sendManyMine := sendManyCmd.Bool("mine", false, "在该节点立即挖矿")
+	sendManyRBF := sendManyCmd.Bool("rbf", false, "声明交易可被替换，以便之后使用bumpfee提高手续费")
+	sendManySelector := sendManyCmd.String("selector", "", "选币策略：largest、smallest、bnb（默认）、random")
+	sendManyDryRun := sendManyCmd.Bool("dryrun", false, "只校验付款列表并显示总额与手续费，不广播")
+	sendManySigner := sendManyCmd.String("signer", "", "外部签名者：unix:套接字路径，或exec:签名程序的命令行")
+	signerSocket := signerCmd.String("socket", "", "监听的unix套接字路径，不指定时经标准输入输出通信")
+	bumpFeeTxID := bumpFeeCmd.String("txid", "", "需要提高手续费的交易ID")
+	bumpFeeFee := bumpFeeCmd.Int("fee", 0, "新的手续费总额")
 	startNodePort := startNodeCmd.String("port", "", "启动节点，并制定节点的端口")
 	startNodeMiner := startNodeCmd.String("miner", "", "启动挖矿模式，并制定奖励的钱包ADDRESS")
+	startNodeAdmin := startNodeCmd.Bool("admin", false, "管理模式：开放生成、返回私钥以及使用节点钱包签名的HTTP接口")
+	startNodeSigner := startNodeCmd.String("signer", "", "外部签名者：unix:套接字路径，或exec:签名程序的命令行")
+	createPSBTFrom := createPSBTCmd.String("from", "", "钱包源地址（本节点可以没有该地址的私钥）")
+	createPSBTTo := createPSBTCmd.String("to", "", "钱包目的地址")
+	createPSBTAmount := createPSBTCmd.Int("amount", 0, "转移资金的数量")
+	createPSBTFile := createPSBTCmd.String("file", "", "付款列表文件（CSV或JSON），代替-to与-amount")
+	createPSBTFee := createPSBTCmd.Int("fee", 0, "支付给矿工的手续费")
+	createPSBTRBF := createPSBTCmd.Bool("rbf", false, "声明交易可被替换")
+	createPSBTSelector := createPSBTCmd.String("selector", "", "选币策略：largest、smallest、bnb（默认）、random")
+	createPSBTOut := createPSBTCmd.String("out", "tx.psbt", "保存部分签名交易的文件")
+	signPSBTIn := signPSBTCmd.String("in", "", "部分签名交易文件")
+	signPSBTOut := signPSBTCmd.String("out", "", "保存签名后的部分签名交易的文件，默认覆盖-in")
+	combinePSBTIn := combinePSBTCmd.String("in", "", "需要合并的部分签名交易文件，以逗号分隔")
+	combinePSBTOut := combinePSBTCmd.String("out", "tx.psbt", "保存合并后的部分签名交易的文件")
+	finalizePSBTIn := finalizePSBTCmd.String("in", "", "部分签名交易文件")
+	finalizePSBTSend := finalizePSBTCmd.Bool("send", false, "校验通过后广播交易")
+	finalizePSBTMine := finalizePSBTCmd.Bool("mine", false, "在该节点立即挖矿")
+	finalizePSBTMiner := finalizePSBTCmd.String("miner", "", "立即挖矿时接收出块奖励和手续费的ADDRESS")
+	createRawInputs := createRawCmd.String("inputs", "", "交易输入，格式为txid:vout，多个以逗号分隔")
+	createRawOutputs := createRawCmd.String("outputs", "", "交易输出，格式为地址:金额，多个以逗号分隔")
+	createRawData := createRawCmd.String("data", "", "附带上链的数据，带0x前缀按hex解析")
+	createRawLockTime := createRawCmd.Int64("locktime", 0, "交易锁定至的区块号")
+	createRawRBF := createRawCmd.Bool("rbf", false, "声明交易可被替换")
+	decodeRawHex := decodeRawCmd.String("hex", "", "原始交易hex")
+	signRawHex := signRawCmd.String("hex", "", "原始交易hex")
+	signRawPrivKey := signRawCmd.String("privkey", "", "hex编码的私钥")
+	sendRawHex := sendRawCmd.String("hex", "", "签名完成的原始交易hex")
+	sendRawNode := sendRawCmd.String("node", knownNodes[0], "接收交易的节点地址")
+	getBalanceAddress := getBalanceCmd.String("address", "", "钱包地址")
+	getHistoryAddress := getHistoryCmd.String("address", "", "钱包地址")
+	importAddressAddress := importAddressCmd.String("address", "", "只读地址")
+	setLabelAddress := setLabelCmd.String("address", "", "钱包地址")
+	setLabelLabel := setLabelCmd.String("label", "", "标签，为空时删除标签")
+	addContactName := addContactCmd.String("name", "", "联系人名称")
+	addContactAddress := addContactCmd.String("address", "", "联系人的收款地址")
+	removeContactName := removeContactCmd.String("name", "", "联系人名称")
+	importAddressPubKey := importAddressCmd.String("pubkey", "", "hex编码的公钥，与-address二选一")
+	importPrivKeyKey := importPrivKeyCmd.String("privkey", "", "带版本号与校验码的Base58私钥")
+	importPrivKeyRescan := importPrivKeyCmd.Bool("rescan", true, "导入后扫描区块链")
+	dumpPrivKeyAddress := dumpPrivKeyCmd.String("address", "", "钱包地址")
+	exportKeystoreAddress := exportKeystoreCmd.String("address", "", "钱包地址")
+	exportKeystorePassphrase := exportKeystoreCmd.String("passphrase", "", "keystore文件的密码")
+	exportKeystoreOut := exportKeystoreCmd.String("out", "", "输出文件，不指定时输出到终端")
+	importKeystoreFile := importKeystoreCmd.String("file", "", "keystore v3文件")
+	importKeystorePassphrase := importKeystoreCmd.String("passphrase", "", "keystore文件的密码")
+	importKeystoreRescan := importKeystoreCmd.Bool("rescan", true, "导入后扫描区块链")
+	migrateKeystorePassphrase := migrateKeystoreCmd.String("passphrase", "", "钱包密码，未加密的钱包用其加密")
+	rescanWalletFrom := rescanWalletCmd.Int64("from", -1, "起始区块号，不指定时从上次扫描到的位置继续")
+	signMessageAddress := signMessageCmd.String("address", "", "签名的地址")
+	signMessageMessage := signMessageCmd.String("message", "", "要签名的消息")
+	verifyMessageAddress := verifyMessageCmd.String("address", "", "签名的地址")
+	verifyMessageSignature := verifyMessageCmd.String("signature", "", "signmessage输出的签名")
+	verifyMessageMessage := verifyMessageCmd.String("message", "", "被签名的消息")
+	createHDWalletWords := createHDWalletCmd.Int("words", 12, "助记词的单词数，12或24")
+	createHDWalletSeedPass := createHDWalletCmd.String("seedpass", "", "可选的助记词密码，恢复时必须提供同样的密码")
+	restoreWalletMnemonic := restoreWalletCmd.String("mnemonic", "", "助记词，单词之间以空格分隔")
+	restoreWalletSeedPass := restoreWalletCmd.String("seedpass", "", "助记词密码")
+	restoreWalletGap := restoreWalletCmd.Int("gap", wallet.DefaultGapLimit, "连续这么多个地址没有交易记录时停止派生")
+	restoreWalletShares := restoreWalletCmd.String("shares", "", "backupwallet输出的分片，多个以逗号分隔，与-mnemonic二选一")
+	restoreWalletShareFile := restoreWalletCmd.String("sharefile", "", "分片文件，每行一个分片")
+	backupWalletShares := backupWalletCmd.Int("shares", 0, "分片数，不超过255")
+	backupWalletThreshold := backupWalletCmd.Int("threshold", 0, "恢复所需的分片数，不小于2")
+	backupWalletFormat := backupWalletCmd.String("format", "words", "分片格式：words（BIP39词表中的单词）或hex")
+	encryptWalletPassphrase := encryptWalletCmd.String("passphrase", "", "钱包密码")
+	passphraseChangeOld := passphraseChangeCmd.String("old", "", "原密码")
+	passphraseChangeNew := passphraseChangeCmd.String("new", "", "新密码")
+	walletUnlockPassphrase := walletUnlockCmd.String("passphrase", "", "钱包密码")
+	walletUnlockTimeout := walletUnlockCmd.Int("timeout", 60, "解锁时长，单位秒")
+	walletUnlockNode := walletUnlockCmd.String("node", knownNodes[0], "节点地址（需以-admin启动）")
+	walletLockNode := walletLockCmd.String("node", knownNodes[0], "节点地址（需以-admin启动）")
+	initiateFrom := initiateCmd.String("from", "", "发起方钱包地址")
+	initiateTo := initiateCmd.String("to", "", "参与方在本链上的钱包地址")
+	initiateAmount := initiateCmd.Int("amount", 0, "锁定资金的数量")
+	initiateLockTime := initiateCmd.Int64("locktime", core.InitiatorLockTime, "合约锁定的区块数")
+	initiateMine := initiateCmd.Bool("mine", false, "在该节点立即挖矿")
+	participateFrom := participateCmd.String("from", "", "参与方钱包地址")
+	participateTo := participateCmd.String("to", "", "发起方在本链上的钱包地址")
+	participateAmount := participateCmd.Int("amount", 0, "锁定资金的数量")
+	participateHash := participateCmd.String("hash", "", "发起方公布的秘密哈希")
+	participateLockTime := participateCmd.Int64("locktime", core.ParticipantLockTime, "合约锁定的区块数")
+	participateMine := participateCmd.Bool("mine", false, "在该节点立即挖矿")
+	redeemAddress := redeemCmd.String("address", "", "合约接收方钱包地址")
+	redeemContract := redeemCmd.String("contract", "", "合约交易ID")
+	redeemSecret := redeemCmd.String("secret", "", "秘密")
+	redeemMine := redeemCmd.Bool("mine", false, "在该节点立即挖矿")
+	refundAddress := refundCmd.String("address", "", "合约发送方钱包地址")
+	refundContract := refundCmd.String("contract", "", "合约交易ID")
+	refundMine := refundCmd.Bool("mine", false, "在该节点立即挖矿")
+	extractSecretTx := extractSecretCmd.String("tx", "", "赎回交易ID")
+	extractSecretContract := extractSecretCmd.String("contract", "", "合约交易ID，未给出赎回交易时查找花费该合约的交易")
+	extractSecretHash := extractSecretCmd.String("hash", "", "秘密哈希")
+	createWalletName := createWalletCmd.String("name", "", "钱包名称，由字母、数字、-和_组成")
+	loadWalletName := loadWalletCmd.String("name", "", "钱包名称")
+	loadWalletNode := loadWalletCmd.String("node", knownNodes[0], "节点地址（需以-admin启动）")
+	unloadWalletName := unloadWalletCmd.String("name", "", "钱包名称")
+	unloadWalletNode := unloadWalletCmd.String("node", knownNodes[0], "节点地址（需以-admin启动）")
+	listWalletsNode := listWalletsCmd.String("node", "", "节点地址（需以-admin启动），指定时同时显示节点已加载的钱包")
+	vanityAddressPrefix := vanityAddressCmd.String("prefix", "", "地址的前缀，包括地址开头的F（secp256k1地址为S）")
+	vanityAddressSuffix := vanityAddressCmd.String("suffix", "", "地址的后缀")
+	vanityAddressIgnoreCase := vanityAddressCmd.Bool("ignorecase", false, "匹配时不区分大小写")
+	vanityAddressType := vanityAddressCmd.String("type", "p256", "私钥类型：p256或secp256k1")
+	vanityAddressWorkers := vanityAddressCmd.Int("workers", runtime.NumCPU(), "并行生成私钥的协程数")
+	scanStealthFrom := scanStealthCmd.Int64("from", -1, "起始区块号，不指定时从上次扫描到的位置继续")
+	startNodeWallets := startNodeCmd.String("wallet", "", "启动时加载的钱包，多个以逗号分隔，不指定时不加载钱包，之后可用loadwallet加载")
+
+	//钱包相关的命令由-wallet指定钱包名称
+	sendWallet := sendCmd.String("wallet", "", walletUsage)
+	sendManyWallet := sendManyCmd.String("wallet", "", walletUsage)
+	bumpFeeWallet := bumpFeeCmd.String("wallet", "", walletUsage)
+	signPSBTWallet := signPSBTCmd.String("wallet", "", walletUsage)
+	finalizePSBTWallet := finalizePSBTCmd.String("wallet", "", walletUsage)
+	getBalanceWallet := getBalanceCmd.String("wallet", "", walletUsage)
+	getHistoryWallet := getHistoryCmd.String("wallet", "", walletUsage)
+	listAddressesWallet := listAddressesCmd.String("wallet", "", walletUsage)
+	setLabelWallet := setLabelCmd.String("wallet", "", walletUsage)
+	addContactWallet := addContactCmd.String("wallet", "", walletUsage)
+	removeContactWallet := removeContactCmd.String("wallet", "", walletUsage)
+	listContactsWallet := listContactsCmd.String("wallet", "", walletUsage)
+	importAddressWallet := importAddressCmd.String("wallet", "", walletUsage)
+	importPrivKeyWallet := importPrivKeyCmd.String("wallet", "", walletUsage)
+	dumpPrivKeyWallet := dumpPrivKeyCmd.String("wallet", "", walletUsage)
+	convertKeysWallet := convertKeysCmd.String("wallet", "", walletUsage)
+	exportKeystoreWallet := exportKeystoreCmd.String("wallet", "", walletUsage)
+	importKeystoreWallet := importKeystoreCmd.String("wallet", "", walletUsage)
+	migrateKeystoreWallet := migrateKeystoreCmd.String("wallet", "", walletUsage)
+	rescanWalletWallet := rescanWalletCmd.String("wallet", "", walletUsage)
+	signMessageWallet := signMessageCmd.String("wallet", "", walletUsage)
+	signerWallet := signerCmd.String("wallet", "", walletUsage)
+	createHDWalletWallet := createHDWalletCmd.String("wallet", "", walletUsage)
+	restoreWalletWallet := restoreWalletCmd.String("wallet", "", walletUsage)
+	backupWalletWallet := backupWalletCmd.String("wallet", "", walletUsage)
+	getNewAddressWallet := getNewAddressCmd.String("wallet", "", walletUsage)
+	encryptWalletWallet := encryptWalletCmd.String("wallet", "", walletUsage)
+	passphraseChangeWallet := passphraseChangeCmd.String("wallet", "", walletUsage)
+	initiateWallet := initiateCmd.String("wallet", "", walletUsage)
+	participateWallet := participateCmd.String("wallet", "", walletUsage)
+	redeemWallet := redeemCmd.String("wallet", "", walletUsage)
+	refundWallet := refundCmd.String("wallet", "", walletUsage)
+	vanityAddressWallet := vanityAddressCmd.String("wallet", "", walletUsage)
+	getNewStealthAddressWallet := getNewStealthAddressCmd.String("wallet", "", walletUsage)
+	listStealthAddressesWallet := listStealthAddressesCmd.String("wallet", "", walletUsage)
+	scanStealthWallet := scanStealthCmd.String("wallet", "", walletUsage)
+	walletUnlockWallet := walletUnlockCmd.String("wallet", "", "节点上已加载的钱包名称（必须指定）")
+	walletLockWallet := walletLockCmd.String("wallet", "", "节点上已加载的钱包名称（必须指定）")
 
 	//os.Args包含以程序名称开始的命令行参数
 	switch os.Args[1] { //os.Args[0]为程序名称，真正传递的参数index从1开始，一般而言Args[1]为命令名称
@@ -57,6 +338,251 @@ func (cli *CLI) Run() {
 		if err != nil {
 			log.Panic(err)
 		}
+	case "sendmany":
+		err := sendManyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "bumpfee":
+		err := bumpFeeCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createpsbt":
+		err := createPSBTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signpsbt":
+		err := signPSBTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "combinepsbt":
+		err := combinePSBTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "finalizepsbt":
+		err := finalizePSBTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createrawtransaction":
+		err := createRawCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "decoderawtransaction":
+		err := decodeRawCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signrawtransaction":
+		err := signRawCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "sendrawtransaction":
+		err := sendRawCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getbalance":
+		err := getBalanceCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "gethistory":
+		err := getHistoryCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listaddresses":
+		err := listAddressesCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "setlabel":
+		err := setLabelCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "addcontact":
+		err := addContactCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "removecontact":
+		err := removeContactCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listcontacts":
+		err := listContactsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importaddress":
+		err := importAddressCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importprivkey":
+		err := importPrivKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "dumpprivkey":
+		err := dumpPrivKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "convertkeys":
+		err := convertKeysCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "exportkeystore":
+		err := exportKeystoreCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importkeystore":
+		err := importKeystoreCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "migratekeystore":
+		err := migrateKeystoreCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "rescanwallet":
+		err := rescanWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signmessage":
+		err := signMessageCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "verifymessage":
+		err := verifyMessageCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signer":
+		err := signerCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createhdwallet":
+		err := createHDWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "restorewallet":
+		err := restoreWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "backupwallet":
+		err := backupWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getnewaddress":
+		err := getNewAddressCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "encryptwallet":
+		err := encryptWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletpassphrasechange":
+		err := passphraseChangeCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletunlock":
+		err := walletUnlockCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletlock":
+		err := walletLockCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "initiate":
+		err := initiateCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "participate":
+		err := participateCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "redeem":
+		err := redeemCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "refund":
+		err := refundCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "extractsecret":
+		err := extractSecretCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createwallet":
+		err := createWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "loadwallet":
+		err := loadWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "unloadwallet":
+		err := unloadWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listwallets":
+		err := listWalletsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "vanityaddress":
+		err := vanityAddressCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getnewstealthaddress":
+		err := getNewStealthAddressCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "liststealthaddresses":
+		err := listStealthAddressesCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "scanstealth":
+		err := scanStealthCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	default:
 		cli.printUsage()
 		os.Exit(1)
@@ -68,7 +594,23 @@ func (cli *CLI) Run() {
 			os.Exit(1)
 		}
 
-		cli.send(*sendFrom, *sendTo, *sendAmount, cli.NodeId, *sendMine)
+		cli.send(*sendFrom, *sendTo, *sendAmount, cli.NodeId, cli.walletName(*sendWallet), *sendMine, *sendData, *sendFee, *sendRBF, *sendSelector, *sendInputs, *sendSigner)
+	}
+
+	if sendManyCmd.Parsed() {
+		if *sendManyFrom == "" || *sendManyFile == "" {
+			sendManyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.sendMany(*sendManyFrom, *sendManyFile, cli.NodeId, cli.walletName(*sendManyWallet), *sendManyMine, *sendManyFee, *sendManyRBF, *sendManySelector, *sendManyDryRun, *sendManySigner)
+	}
+
+	if bumpFeeCmd.Parsed() {
+		if *bumpFeeTxID == "" || *bumpFeeFee <= 0 {
+			bumpFeeCmd.Usage()
+			os.Exit(1)
+		}
+		cli.bumpFee(*bumpFeeTxID, *bumpFeeFee, cli.NodeId, cli.walletName(*bumpFeeWallet))
 	}
 
 	if startNodeCmd.Parsed() {
@@ -76,11 +618,374 @@ func (cli *CLI) Run() {
 			startNodeCmd.Usage()
 			os.Exit(1)
 		}
-		cli.startNode(*startNodePort, *startNodeMiner)
+		cli.startNode(*startNodePort, *startNodeMiner, *startNodeAdmin, *startNodeSigner, *startNodeWallets)
+	}
+
+	if createPSBTCmd.Parsed() {
+		if *createPSBTFrom == "" || (*createPSBTFile == "" && (*createPSBTTo == "" || *createPSBTAmount <= 0)) {
+			createPSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createPSBT(*createPSBTFrom, *createPSBTTo, *createPSBTAmount, *createPSBTFile, *createPSBTFee, *createPSBTRBF, *createPSBTSelector, *createPSBTOut, cli.NodeId)
+	}
+
+	if signPSBTCmd.Parsed() {
+		if *signPSBTIn == "" {
+			signPSBTCmd.Usage()
+			os.Exit(1)
+		}
+		if *signPSBTOut == "" {
+			*signPSBTOut = *signPSBTIn
+		}
+		cli.signPSBT(*signPSBTIn, *signPSBTOut, cli.walletName(*signPSBTWallet))
+	}
+
+	if combinePSBTCmd.Parsed() {
+		if *combinePSBTIn == "" {
+			combinePSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.combinePSBT(*combinePSBTIn, *combinePSBTOut)
+	}
+
+	if finalizePSBTCmd.Parsed() {
+		if *finalizePSBTIn == "" || (*finalizePSBTMine && *finalizePSBTMiner == "") {
+			finalizePSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.finalizePSBT(*finalizePSBTIn, *finalizePSBTSend, cli.NodeId, cli.walletName(*finalizePSBTWallet), *finalizePSBTMine, *finalizePSBTMiner)
+	}
+
+	if createRawCmd.Parsed() {
+		if *createRawInputs == "" || *createRawOutputs == "" {
+			createRawCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createRawTransaction(*createRawInputs, *createRawOutputs, *createRawData, *createRawLockTime, *createRawRBF)
+	}
+
+	if decodeRawCmd.Parsed() {
+		if *decodeRawHex == "" {
+			decodeRawCmd.Usage()
+			os.Exit(1)
+		}
+		cli.decodeRawTransaction(*decodeRawHex)
+	}
+
+	if signRawCmd.Parsed() {
+		if *signRawHex == "" || *signRawPrivKey == "" {
+			signRawCmd.Usage()
+			os.Exit(1)
+		}
+		cli.signRawTransaction(*signRawHex, *signRawPrivKey, cli.NodeId)
+	}
+
+	if sendRawCmd.Parsed() {
+		if *sendRawHex == "" {
+			sendRawCmd.Usage()
+			os.Exit(1)
+		}
+		cli.sendRawTransaction(*sendRawHex, *sendRawNode)
+	}
+
+	if getBalanceCmd.Parsed() {
+		if *getBalanceAddress == "" {
+			getBalanceCmd.Usage()
+			os.Exit(1)
+		}
+		cli.getBalance(*getBalanceAddress, cli.NodeId, cli.walletName(*getBalanceWallet))
+	}
+
+	if getHistoryCmd.Parsed() {
+		if *getHistoryAddress == "" {
+			getHistoryCmd.Usage()
+			os.Exit(1)
+		}
+		cli.getHistory(*getHistoryAddress, cli.NodeId, cli.walletName(*getHistoryWallet))
+	}
+
+	if listAddressesCmd.Parsed() {
+		cli.listAddresses(cli.NodeId, cli.walletName(*listAddressesWallet))
+	}
+
+	if setLabelCmd.Parsed() {
+		if *setLabelAddress == "" {
+			setLabelCmd.Usage()
+			os.Exit(1)
+		}
+		cli.setLabel(*setLabelAddress, *setLabelLabel, cli.walletName(*setLabelWallet))
+	}
+
+	if addContactCmd.Parsed() {
+		if *addContactName == "" || *addContactAddress == "" {
+			addContactCmd.Usage()
+			os.Exit(1)
+		}
+		cli.addContact(*addContactName, *addContactAddress, cli.walletName(*addContactWallet))
+	}
+
+	if removeContactCmd.Parsed() {
+		if *removeContactName == "" {
+			removeContactCmd.Usage()
+			os.Exit(1)
+		}
+		cli.removeContact(*removeContactName, cli.walletName(*removeContactWallet))
+	}
+
+	if listContactsCmd.Parsed() {
+		cli.listContacts(cli.walletName(*listContactsWallet))
+	}
+
+	if importAddressCmd.Parsed() {
+		if (*importAddressAddress == "") == (*importAddressPubKey == "") {
+			importAddressCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importAddress(*importAddressAddress, *importAddressPubKey, cli.walletName(*importAddressWallet))
+	}
+
+	if importPrivKeyCmd.Parsed() {
+		if *importPrivKeyKey == "" {
+			importPrivKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importPrivKey(*importPrivKeyKey, *importPrivKeyRescan, cli.NodeId, cli.walletName(*importPrivKeyWallet))
+	}
+
+	if dumpPrivKeyCmd.Parsed() {
+		if *dumpPrivKeyAddress == "" {
+			dumpPrivKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.dumpPrivKey(*dumpPrivKeyAddress, cli.walletName(*dumpPrivKeyWallet))
+	}
+
+	if convertKeysCmd.Parsed() {
+		cli.convertKeys(cli.walletName(*convertKeysWallet))
+	}
+
+	if exportKeystoreCmd.Parsed() {
+		if *exportKeystoreAddress == "" || *exportKeystorePassphrase == "" {
+			exportKeystoreCmd.Usage()
+			os.Exit(1)
+		}
+		cli.exportKeystore(*exportKeystoreAddress, *exportKeystorePassphrase, *exportKeystoreOut, cli.walletName(*exportKeystoreWallet))
+	}
+
+	if importKeystoreCmd.Parsed() {
+		if *importKeystoreFile == "" {
+			importKeystoreCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importKeystore(*importKeystoreFile, *importKeystorePassphrase, *importKeystoreRescan, cli.NodeId, cli.walletName(*importKeystoreWallet))
+	}
+
+	if migrateKeystoreCmd.Parsed() {
+		if *migrateKeystorePassphrase == "" {
+			migrateKeystoreCmd.Usage()
+			os.Exit(1)
+		}
+		cli.migrateKeystore(*migrateKeystorePassphrase, cli.walletName(*migrateKeystoreWallet))
+	}
+
+	if rescanWalletCmd.Parsed() {
+		cli.rescanWallet(*rescanWalletFrom, cli.NodeId, cli.walletName(*rescanWalletWallet))
+	}
+
+	if signMessageCmd.Parsed() {
+		if *signMessageAddress == "" {
+			signMessageCmd.Usage()
+			os.Exit(1)
+		}
+		cli.signMessage(*signMessageAddress, *signMessageMessage, cli.walletName(*signMessageWallet))
+	}
+
+	if verifyMessageCmd.Parsed() {
+		if *verifyMessageAddress == "" || *verifyMessageSignature == "" {
+			verifyMessageCmd.Usage()
+			os.Exit(1)
+		}
+		cli.verifyMessage(*verifyMessageAddress, *verifyMessageSignature, *verifyMessageMessage)
+	}
+
+	if signerCmd.Parsed() {
+		cli.runSigner(*signerSocket, cli.walletName(*signerWallet))
+	}
+
+	if createHDWalletCmd.Parsed() {
+		cli.createHDWallet(*createHDWalletWords, *createHDWalletSeedPass, cli.walletName(*createHDWalletWallet))
+	}
+
+	if restoreWalletCmd.Parsed() {
+		switch {
+		case *restoreWalletShares != "" || *restoreWalletShareFile != "":
+			cli.restoreWalletFromShares(*restoreWalletShares, *restoreWalletShareFile, cli.walletName(*restoreWalletWallet))
+		case *restoreWalletMnemonic != "":
+			cli.restoreWallet(*restoreWalletMnemonic, *restoreWalletSeedPass, *restoreWalletGap, cli.NodeId, cli.walletName(*restoreWalletWallet))
+		default:
+			restoreWalletCmd.Usage()
+			os.Exit(1)
+		}
+	}
+
+	if backupWalletCmd.Parsed() {
+		if *backupWalletShares <= 0 || *backupWalletThreshold <= 0 {
+			backupWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.backupWallet(*backupWalletShares, *backupWalletThreshold, *backupWalletFormat, cli.walletName(*backupWalletWallet))
+	}
+
+	if getNewAddressCmd.Parsed() {
+		switch *getNewAddressType {
+		case "p256":
+			cli.createWallet(cli.walletName(*getNewAddressWallet))
+		case "secp256k1":
+			cli.createSecp256k1Wallet(cli.walletName(*getNewAddressWallet))
+		default:
+			getNewAddressCmd.Usage()
+			os.Exit(1)
+		}
+	}
+
+	if encryptWalletCmd.Parsed() {
+		if *encryptWalletPassphrase == "" {
+			encryptWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.encryptWallet(*encryptWalletPassphrase, cli.walletName(*encryptWalletWallet))
+	}
+
+	if passphraseChangeCmd.Parsed() {
+		if *passphraseChangeOld == "" || *passphraseChangeNew == "" {
+			passphraseChangeCmd.Usage()
+			os.Exit(1)
+		}
+		cli.walletPassphraseChange(*passphraseChangeOld, *passphraseChangeNew, cli.walletName(*passphraseChangeWallet))
+	}
+
+	if walletUnlockCmd.Parsed() {
+		if *walletUnlockPassphrase == "" || *walletUnlockTimeout <= 0 || *walletUnlockWallet == "" {
+			walletUnlockCmd.Usage()
+			os.Exit(1)
+		}
+		cli.walletUnlock(*walletUnlockPassphrase, *walletUnlockTimeout, *walletUnlockNode, *walletUnlockWallet)
+	}
+
+	if walletLockCmd.Parsed() {
+		if *walletLockWallet == "" {
+			walletLockCmd.Usage()
+			os.Exit(1)
+		}
+		cli.walletLock(*walletLockNode, *walletLockWallet)
+	}
+
+	if initiateCmd.Parsed() {
+		if *initiateFrom == "" || *initiateTo == "" || *initiateAmount <= 0 {
+			initiateCmd.Usage()
+			os.Exit(1)
+		}
+		cli.initiate(*initiateFrom, *initiateTo, *initiateAmount, *initiateLockTime, cli.NodeId, cli.walletName(*initiateWallet), *initiateMine)
+	}
+
+	if participateCmd.Parsed() {
+		if *participateFrom == "" || *participateTo == "" || *participateAmount <= 0 || *participateHash == "" {
+			participateCmd.Usage()
+			os.Exit(1)
+		}
+		cli.participate(*participateFrom, *participateTo, *participateAmount, *participateHash, *participateLockTime, cli.NodeId, cli.walletName(*participateWallet), *participateMine)
+	}
+
+	if redeemCmd.Parsed() {
+		if *redeemAddress == "" || *redeemContract == "" || *redeemSecret == "" {
+			redeemCmd.Usage()
+			os.Exit(1)
+		}
+		cli.redeem(*redeemAddress, *redeemContract, *redeemSecret, cli.NodeId, cli.walletName(*redeemWallet), *redeemMine)
+	}
+
+	if refundCmd.Parsed() {
+		if *refundAddress == "" || *refundContract == "" {
+			refundCmd.Usage()
+			os.Exit(1)
+		}
+		cli.refund(*refundAddress, *refundContract, cli.NodeId, cli.walletName(*refundWallet), *refundMine)
+	}
+
+	if extractSecretCmd.Parsed() {
+		if *extractSecretHash == "" || (*extractSecretTx == "" && *extractSecretContract == "") {
+			extractSecretCmd.Usage()
+			os.Exit(1)
+		}
+		cli.extractSecret(*extractSecretTx, *extractSecretContract, *extractSecretHash, cli.NodeId)
+	}
+
+	if createWalletCmd.Parsed() {
+		if *createWalletName == "" {
+			createWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createNamedWallet(*createWalletName)
+	}
+
+	if loadWalletCmd.Parsed() {
+		if *loadWalletName == "" {
+			loadWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.loadWallet(*loadWalletName, *loadWalletNode)
+	}
+
+	if unloadWalletCmd.Parsed() {
+		if *unloadWalletName == "" {
+			unloadWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.unloadWallet(*unloadWalletName, *unloadWalletNode)
+	}
+
+	if listWalletsCmd.Parsed() {
+		cli.listWallets(*listWalletsNode)
+	}
+
+	if vanityAddressCmd.Parsed() {
+		if (*vanityAddressPrefix == "" && *vanityAddressSuffix == "") || *vanityAddressWorkers <= 0 {
+			vanityAddressCmd.Usage()
+			os.Exit(1)
+		}
+		cli.vanityAddress(*vanityAddressPrefix, *vanityAddressSuffix, *vanityAddressIgnoreCase, *vanityAddressType, *vanityAddressWorkers, cli.walletName(*vanityAddressWallet))
+	}
+
+	if getNewStealthAddressCmd.Parsed() {
+		cli.getNewStealthAddress(cli.walletName(*getNewStealthAddressWallet))
+	}
+
+	if listStealthAddressesCmd.Parsed() {
+		cli.listStealthAddresses(cli.NodeId, cli.walletName(*listStealthAddressesWallet))
+	}
+
+	if scanStealthCmd.Parsed() {
+		cli.scanStealth(*scanStealthFrom, cli.NodeId, cli.walletName(*scanStealthWallet))
 	}
 }
 
-func (cli *CLI) startNode(nodeID string, minerAddress string) {
+//walletName 返回命令使用的钱包名称：钱包命令必须用-wallet指定钱包，钱包必须已由createwallet创建
+//钱包与节点端口无关，旧版本的钱包文件wallet_节点ID.dat用节点ID作为名称即可使用
+func (cli *CLI) walletName(name string) string {
+	if name == "" {
+		log.Panic(errors.New("ERROR: 钱包命令必须用-wallet NAME指定钱包，可用listwallets查看钱包数据目录中的钱包"))
+	}
+	if err := wallet.ValidateWalletName(name); err != nil {
+		log.Panic(err)
+	}
+	if !wallet.WalletExists(name) {
+		log.Panic(fmt.Errorf("ERROR: 钱包%s不存在，请先用createwallet -name %s创建", name, name))
+	}
+
+	return name
+}
+
+func (cli *CLI) startNode(nodeID string, minerAddress string, admin bool, signerEndpoint string, walletNames string) {
 	fmt.Printf("开始节点 %s\n", nodeID)
 	if len(minerAddress) > 0 {
 		if wallet.ValidateAddress(minerAddress) {
@@ -89,5 +994,20 @@ func (cli *CLI) startNode(nodeID string, minerAddress string) {
 			log.Panic("错误的挖矿地址!")
 		}
 	}
-	StartServer(nodeID, minerAddress) //启动节点服务器：区块链中每一个节点都是服务器
+	if admin {
+		fmt.Println("管理模式: HTTP接口可以生成、返回私钥，并使用节点钱包签名，请勿对外开放!")
+	}
+	if signerEndpoint != "" {
+		fmt.Println("HTTP转账由外部签名者签名: ", signerEndpoint)
+	}
+	var names []string
+	for _, name := range strings.Split(walletNames, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		fmt.Println("加载钱包: ", strings.Join(names, ","))
+	}
+	StartServer(nodeID, minerAddress, admin, signerEndpoint, names) //启动节点服务器：区块链中每一个节点都是服务器
 }
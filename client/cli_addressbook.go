@@ -0,0 +1,48 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"zzschain/wallet"
+)
+
+//setLabel 为钱包中的地址设置标签，label为空时删除标签
+func (cli *CLI) setLabel(address, label string, walletName string) {
+	wallets, _ := wallet.NewWallets(walletName)
+	if err := wallets.SetLabel(address, label); err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(walletName)
+
+	fmt.Printf("地址%s的标签已设置为: %s\n", address, label)
+}
+
+//addContact 将收款地址加入通讯录，之后转账时可以用联系人名称代替地址
+func (cli *CLI) addContact(name, address string, walletName string) {
+	wallets, _ := wallet.NewWallets(walletName)
+	if err := wallets.AddContact(name, address); err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(walletName)
+
+	fmt.Printf("已添加联系人: %s  %s\n", name, address)
+}
+
+//removeContact 从通讯录中删除联系人
+func (cli *CLI) removeContact(name string, walletName string) {
+	wallets, _ := wallet.NewWallets(walletName)
+	if err := wallets.RemoveContact(name); err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(walletName)
+
+	fmt.Printf("已删除联系人: %s\n", name)
+}
+
+//listContacts 按名称列出通讯录中的联系人
+func (cli *CLI) listContacts(walletName string) {
+	wallets, _ := wallet.NewWallets(walletName)
+	for _, name := range wallets.ContactNames() {
+		fmt.Printf("%s  %s\n", name, wallets.Contacts[name])
+	}
+}
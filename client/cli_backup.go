@@ -0,0 +1,81 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"zzschain/wallet"
+)
+
+//backupWallet 将钱包的种子与私钥分为shares个分片，任意threshold个分片可以恢复，format为hex或words
+func (cli *CLI) backupWallet(shares, threshold int, format string, walletName string) {
+	if format != "hex" && format != "words" {
+		log.Panic(fmt.Errorf("ERROR: 不支持的分片格式%q，请使用hex或words", format))
+	}
+	wallets, err := loadWallets(walletName)
+	if err != nil {
+		log.Panic(err)
+	}
+	backup, err := wallets.BackupShares(shares, threshold)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	for _, share := range backup {
+		encoded := share.Hex()
+		if format == "words" {
+			encoded = share.Words()
+		}
+		fmt.Printf("分片%d/%d: %s\n", share.Index, shares, encoded)
+	}
+	fmt.Printf("地址指纹: %x\n", backup[0].Fingerprint)
+	fmt.Printf("请将分片分别交给不同的保管人，任意%d个分片即可恢复全部私钥，少于%d个分片得不到任何信息\n", threshold, threshold)
+}
+
+//restoreWalletFromShares 由分片恢复钱包的种子与私钥，shares以逗号分隔，或由shareFile每行给出一个分片
+//恢复出的地址与分片中记录的指纹一致才写入钱包文件
+func (cli *CLI) restoreWalletFromShares(shares, shareFile string, walletName string) {
+	var encoded []string
+	if shareFile != "" {
+		content, err := ioutil.ReadFile(shareFile)
+		if err != nil {
+			log.Panic(err)
+		}
+		encoded = strings.Split(string(content), "\n")
+	} else {
+		encoded = strings.Split(shares, ",")
+	}
+
+	var parsed []*wallet.Share
+	for _, s := range encoded {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		share, err := wallet.DecodeShare(s)
+		if err != nil {
+			log.Panic(err)
+		}
+		parsed = append(parsed, share)
+	}
+
+	wallets, _ := loadWallets(walletName)
+	count, err := wallets.RestoreShares(parsed)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(walletName)
+
+	addresses := wallets.GetAddresses()
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		if w := wallets.GetWallet(address); !w.WatchOnly {
+			fmt.Println(address)
+		}
+	}
+	fmt.Printf("地址指纹%x校验通过，已恢复%d个地址\n", parsed[0].Fingerprint, count)
+	if stealth := wallets.StealthAddresses(); len(stealth) > 0 {
+		fmt.Printf("已恢复%d个隐身地址，请用scanstealth -from 0扫描区块链找回收到的付款\n", len(stealth))
+	}
+}
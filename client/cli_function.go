@@ -1,9 +1,14 @@
 package client
 
 import (
+	"encoding/hex"
 	"fmt"
 	"log"
+	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 	"zzschain/core"
 	"zzschain/wallet"
 )
@@ -24,46 +29,157 @@ func (cli *CLI) createBlockchain(address string, nodeID string) {
 }
 
 //createWallet 创建钱包并且保存到本地
-func (cli *CLI) createWallet(nodeID string) {
-	wallets, _ := wallet.NewWallets(nodeID) //从钱包文件读取所有的钱包
-	address := wallets.CreateWallet()       //创建新钱包
-	wallets.SaveToFile(nodeID)              //创建完成后，保存到本地，不参与网络共享，必须自己保管好！
+func (cli *CLI) createWallet(walletName string) {
+	wallets, _ := loadWallets(walletName) //从钱包文件读取所有的钱包，已加密时用WALLET_PASSPHRASE解锁
+	address := wallets.CreateWallet()     //创建新钱包
+	wallets.SaveToFile(walletName)        //创建完成后，保存到本地，不参与网络共享，必须自己保管好！
+
+	fmt.Printf("你的新钱包地址是: %s\n", address)
+}
+
+//createSecp256k1Wallet 创建secp256k1私钥的钱包并且保存到本地
+func (cli *CLI) createSecp256k1Wallet(walletName string) {
+	wallets, _ := loadWallets(walletName)
+	address, err := wallets.CreateSecp256k1Wallet()
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(walletName)
 
 	fmt.Printf("你的新钱包地址是: %s\n", address)
 }
 
 //GetBalance 获得账号余额
-func (cli *CLI) getBalance(address string, nodeID string) {
+//address在本地钱包中时，同一账户下找零地址的余额一并计入
+func (cli *CLI) getBalance(address string, nodeID, walletName string) {
 	if !wallet.ValidateAddress(address) {
 		log.Panic("ERROR: 地址非法")
 	}
 	bc := core.NewBlockchain(nodeID)
 	defer bc.Database.Close()
+	wallets, _ := wallet.NewWallets(walletName)
 
 	balance := 0
-	pubKeyHash := wallet.Base58Decode([]byte(address))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-
 	UTXOSet := core.UTXOSet{bc}
-	UTXOs := UTXOSet.FindUTXO(pubKeyHash)
+	for _, addr := range wallets.AccountAddresses(address) {
+		value := 0
+		for _, output := range UTXOSet.FindUTXO(wallet.AddressToPubKeyHash([]byte(addr))) {
+			value += output.Value
+		}
+		if addr != address {
+			fmt.Printf("  找零 %s: %d\n", addr, value)
+		}
+		balance += value
+	}
+
+	fmt.Printf("'%s'的账号余额是: %d\n", address, balance)
+}
 
-	for _, output := range UTXOs {
-		balance += output.Value
+//listAddresses 列出所有钱包的地址、标签与余额，有标签的地址按标签排在前面
+//找零地址列在所属账户之下，账户的余额包括其找零地址的余额，只读地址标记为只读
+func (cli *CLI) listAddresses(nodeID, walletName string) {
+	wallets, err := wallet.NewWallets(walletName)
+	if err != nil {
+		log.Panic(err)
+	}
+	bc := core.NewBlockchain(nodeID)
+	defer bc.Database.Close()
+	UTXOSet := core.UTXOSet{Blockchain: bc}
+	balanceOf := func(address string) int {
+		balance := 0
+		for _, out := range UTXOSet.FindUTXO(wallet.AddressToPubKeyHash([]byte(address))) {
+			balance += out.Value
+		}
+		return balance
 	}
 
-	fmt.Printf("'%s'的账号余额是: %x\n", address, balance)
+	var accounts []string
+	for _, address := range wallets.GetAddresses() {
+		if wallets.Wallets[address].StealthKey { //隐身地址的私钥不用于收款，见liststealthaddresses
+			continue
+		}
+		if wallets.AccountOf(address) == address {
+			accounts = append(accounts, address)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		li, lj := wallets.Wallets[accounts[i]].Label, wallets.Wallets[accounts[j]].Label
+		if (li == "") != (lj == "") {
+			return li != ""
+		}
+		if li != lj {
+			return li < lj
+		}
+		return accounts[i] < accounts[j]
+	})
+
+	for _, account := range accounts {
+		w := wallets.Wallets[account]
+		addresses := wallets.AccountAddresses(account)
+		balances := make([]int, len(addresses))
+		total := 0
+		for i, address := range addresses {
+			balances[i] = balanceOf(address)
+			total += balances[i]
+		}
+
+		line := fmt.Sprintf("%s  %d", account, total)
+		if w.Label != "" {
+			line += "  " + w.Label
+		}
+		if w.WatchOnly {
+			line += "  (只读)"
+		}
+		if w.Stealth != "" {
+			line += "  (隐身地址收款)"
+		}
+		fmt.Println(line)
+		for i, change := range addresses[1:] {
+			fmt.Printf("  找零 %s  %d\n", change, balances[i+1])
+		}
+	}
 }
 
-//listAddresses 列出所有钱包的地址
-func (cli *CLI) listAddresses(nodeID string) {
-	wallets, err := wallet.NewWallets(nodeID)
+//importAddress 导入只读地址：address与pubKey二选一，导入后可以查询余额与交易记录，但不能签名
+func (cli *CLI) importAddress(address, pubKey string, walletName string) {
+	wallets, _ := wallet.NewWallets(walletName)
+
+	var err error
+	if pubKey != "" {
+		address, err = wallets.ImportPubKey(pubKey)
+	} else {
+		err = wallets.ImportAddress(address)
+	}
 	if err != nil {
 		log.Panic(err)
 	}
-	addresses := wallets.GetAddresses()
+	wallets.SaveToFile(walletName)
+
+	fmt.Printf("已导入只读地址: %s\n", address)
+}
 
-	for _, address := range addresses {
-		fmt.Println(address)
+//getHistory 显示地址address所属账户的交易记录，找零在账户内部流转，只计入净额
+//交易对方有标签或在通讯录中时显示其名称
+func (cli *CLI) getHistory(address string, nodeID, walletName string) {
+	if !wallet.ValidateAddress(address) {
+		log.Panic("ERROR: 地址非法")
+	}
+	bc := core.NewBlockchain(nodeID)
+	defer bc.Database.Close()
+	wallets, _ := wallet.NewWallets(walletName)
+
+	history := bc.AccountHistory(core.AccountPubKeyHashes(wallets, address))
+	core.AnnotateHistory(wallets, history)
+	for _, entry := range history {
+		var parties []string
+		for _, party := range entry.Counterparties {
+			if party.Label != "" {
+				parties = append(parties, fmt.Sprintf("%s(%s)", party.Label, party.Address))
+			} else {
+				parties = append(parties, party.Address)
+			}
+		}
+		fmt.Printf("%s  %s  %+d  %s\n", time.Unix(entry.Timestamp, 0).Format("2006-01-02 15:04:05"), entry.TxID, entry.Net, strings.Join(parties, ","))
 	}
 }
 
@@ -103,31 +219,184 @@ func (cli *CLI) reindexUTXO(nodeID string) {
 }
 
 //send 转账
-func (cli *CLI) send(from string, to string, amount int, nodeID string, mineNow bool) {
+//data不为空时，交易附带一个数据输出（带0x前缀按hex解析，否则为原始文本）
+//fee为支付给矿工的手续费，replaceable为true时交易声明可被替换（RBF）
+//signerEndpoint不为空时由外部签名者签名，from可以是只读地址
+func (cli *CLI) send(from string, to string, amount int, nodeID, walletName string, mineNow bool, data string, fee int, replaceable bool, selector string, inputs string, signerEndpoint string) {
 	if !wallet.ValidateAddress(from) {
 		log.Panic("ERROR: 发送地址非法")
 	}
-	if !wallet.ValidateAddress(to) {
-		log.Panic("ERROR: 接收地址非法")
+	wallets, err := loadWallets(walletName)
+	if err != nil {
+		log.Panic(err)
+	}
+	to, err = wallets.ResolveAddress(to) //收款人可以是通讯录中的联系人名称
+	if err != nil {
+		log.Panic(err)
 	}
 	bc := core.NewBlockchain(nodeID) //打开数据库，读取区块链并构建区块链实例
-	UTXOSet := core.UTXOSet{bc}
+	UTXOSet := core.UTXOSet{Blockchain: bc}
 	defer bc.Database.Close() //转账完毕，关闭数据库
-	wallets, err := wallet.NewWallets(nodeID)
+	opts := core.SendOptions{Fee: fee, Replaceable: replaceable}
+	opts.Selector, err = core.NewCoinSelector(selector)
+	if err != nil {
+		log.Panic(err)
+	}
+	opts.Inputs, err = core.ParseOutPoints(inputs)
+	if err != nil {
+		log.Panic(err)
+	}
+	if data != "" {
+		opts.Data, err = core.ParseData(data)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+	signer, closeSigner := dialSigner(signerEndpoint)
+	defer closeSigner()
+	w, err := opts.UseSender(wallets, from, signer)
+	if err != nil {
+		log.Panic(err)
+	}
+	tx, err := core.NewUTXOTransactionWithOptions(w, []byte(to), amount, &UTXOSet, opts)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(walletName) //保存新生成的找零地址
+	commitTransaction(bc, tx, fee, from, walletName, mineNow)
+
+	fmt.Println("转账成功！")
+}
+
+//sendMany 从地址from向付款列表文件file中的全部收款人付款，所有付款合并为一笔交易
+//付款列表先整体校验，并在广播之前显示收款人数、付款总额与手续费；dryRun为true时只显示不广播
+//signerEndpoint不为空时由外部签名者签名
+func (cli *CLI) sendMany(from string, file string, nodeID, walletName string, mineNow bool, fee int, replaceable bool, selector string, dryRun bool, signerEndpoint string) {
+	if !wallet.ValidateAddress(from) {
+		log.Panic("ERROR: 发送地址非法")
+	}
+	payments, err := core.LoadPayments(file)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets, err := loadWallets(walletName)
 	if err != nil {
 		log.Panic(err)
 	}
-	wallet := wallets.GetWallet(from)
-	tx := core.NewUTXOTransaction(&wallet, []byte(to), amount, &UTXOSet)
+	core.ResolvePayments(wallets, payments) //收款人可以是通讯录中的联系人名称
+	total, err := core.ValidatePayments(payments)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc := core.NewBlockchain(nodeID)
+	UTXOSet := core.UTXOSet{Blockchain: bc}
+	defer bc.Database.Close()
+	opts := core.SendOptions{Fee: fee, Replaceable: replaceable}
+	opts.Selector, err = core.NewCoinSelector(selector)
+	if err != nil {
+		log.Panic(err)
+	}
+	signer, closeSigner := dialSigner(signerEndpoint)
+	defer closeSigner()
+	w, err := opts.UseSender(wallets, from, signer)
+	if err != nil {
+		log.Panic(err)
+	}
+	tx, err := core.NewBatchTransaction(w, payments, &UTXOSet, opts)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("收款人数: %d\n", len(payments))
+	fmt.Printf("付款总额: %d\n", total)
+	fmt.Printf("手续费:   %d\n", fee)
+	if dryRun {
+		fmt.Println("付款列表校验通过，未广播交易")
+		return
+	}
+	wallets.SaveToFile(walletName) //保存新生成的找零地址
+	commitTransaction(bc, tx, fee, from, walletName, mineNow)
+
+	fmt.Println("批量转账成功！")
+}
+
+//commitTransaction 提交交易：mineNow为true时由本节点立即挖矿（出块奖励和交易手续费fee都付给rewardAddress），否则发送给中心节点
+//发送给中心节点的交易保存到钱包的交易记录中，以便之后提高手续费
+func commitTransaction(bc *core.Blockchain, tx *core.Transaction, fee int, rewardAddress string, walletName string, mineNow bool) {
 	if mineNow { //当前是挖矿节点，有奖励
-		cbTx := core.NewCoinbaseTX([]byte(from), "")
+		cbTx := core.NewCoinbaseTXWithFees([]byte(rewardAddress), "", fee)
 		txs := []*core.Transaction{cbTx, tx}
 
-		newBlock := bc.MineBlock(txs, from)
+		newBlock := bc.MineBlock(txs, rewardAddress)
+		UTXOSet := core.UTXOSet{Blockchain: bc}
 		UTXOSet.Update(newBlock)
 	} else { //非挖矿节点
+		//先读取钱包的交易文件，读取失败时不发送交易；第一笔交易之前还没有交易文件
+		store, err := wallet.NewTxStore(walletName)
+		if err != nil && !os.IsNotExist(err) {
+			log.Panic(err)
+		}
 		sendTx(knownNodes[0], tx) //发送给中心节点
+
+		store.Put(hex.EncodeToString(tx.ID), tx.Serialize())
+		store.SaveToFile(walletName)
 	}
+}
 
-	fmt.Println("转账成功！")
+//bumpFee 提高一笔尚未上链的交易的手续费（RBF），替换交易发送给中心节点
+func (cli *CLI) bumpFee(txID string, fee int, nodeID, walletName string) {
+	id, err := core.Decode(txID)
+	if err != nil {
+		log.Panic(err)
+	}
+	store, err := wallet.NewTxStore(walletName)
+	if err != nil {
+		log.Panic(err)
+	}
+	data, ok := store.Get(hex.EncodeToString(id))
+	if !ok {
+		log.Panic("ERROR: 钱包中没有这笔交易")
+	}
+	orig := core.DeserializeTransaction(data)
+
+	bc := core.NewBlockchain(nodeID)
+	UTXOSet := core.UTXOSet{Blockchain: bc}
+	defer bc.Database.Close()
+	if _, err := bc.FindTransactionForUTXO(orig.ID); err == nil {
+		store.Delete(hex.EncodeToString(orig.ID))
+		store.SaveToFile(walletName)
+		log.Panic("ERROR: 交易已经上链，无法替换")
+	}
+
+	//原交易的输入由发送者的钱包签名，根据被花费输出锁定的公钥哈希找到该钱包（secp256k1的输入不带公钥）
+	wallets, err := loadWallets(walletName)
+	if err != nil {
+		log.Panic(err)
+	}
+	prevOut, ok := UTXOSet.FindOutput(orig.Vin[0].Txid, orig.Vin[0].Vout)
+	if !ok {
+		log.Panic("ERROR: 原交易的输入已被花费，无法替换")
+	}
+	owner := wallets.FindByPubKeyHash(prevOut.PubKeyHash)
+	if owner == nil {
+		log.Panic("ERROR: 钱包文件中没有原交易发送地址的私钥")
+	}
+	//原交易可能花费了同一账户下找零地址上的币，账户的全部钱包都参与签名
+	from := string(owner.GetAddress())
+	if _, err := wallets.SigningWallet(from); err != nil {
+		log.Panic(err)
+	}
+
+	tx, err := core.NewBumpFeeTransaction(wallets.AccountWallets(from), &orig, fee, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+	sendTx(knownNodes[0], tx)
+
+	store.Delete(hex.EncodeToString(orig.ID))
+	store.Put(hex.EncodeToString(tx.ID), tx.Serialize())
+	store.SaveToFile(walletName)
+
+	fmt.Printf("已用交易%s替换交易%s，手续费: %d\n", core.Encode(tx.ID), core.Encode(orig.ID), fee)
 }
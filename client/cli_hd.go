@@ -0,0 +1,97 @@
+package client
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"zzschain/core"
+	"zzschain/wallet"
+)
+
+//createHDWallet 生成助记词，将钱包文件设为分层确定性钱包并派生第一个收款地址
+//之后CreateWallet都从种子派生，只需备份一次助记词
+func (cli *CLI) createHDWallet(words int, seedPassphrase string, walletName string) {
+	wallets, _ := loadWallets(walletName)
+	if wallets.IsHD() {
+		log.Panic("ERROR: 钱包文件已经是分层确定性钱包")
+	}
+
+	mnemonic, err := wallet.NewMnemonic(words)
+	if err != nil {
+		log.Panic(err)
+	}
+	seed, err := wallet.MnemonicToSeed(mnemonic, seedPassphrase)
+	if err != nil {
+		log.Panic(err)
+	}
+	if err := wallets.SetSeed(seed); err != nil {
+		log.Panic(err)
+	}
+	address := wallets.CreateWallet()
+	wallets.SaveToFile(walletName)
+
+	fmt.Printf("助记词: %s\n", mnemonic)
+	fmt.Println("请抄写并离线保管助记词，任何人得到助记词（及助记词密码）即可恢复全部私钥！")
+	if len(wallets.Wallets) > 1 {
+		fmt.Println("注意: 钱包文件中原有的随机私钥不由助记词派生，仍需单独备份")
+	}
+	fmt.Printf("你的新钱包地址是: %s\n", address)
+}
+
+//restoreWallet 由助记词恢复分层确定性钱包：重新派生收款链与找零链上的地址，
+//并扫描区块链，直到连续gapLimit个地址没有交易记录为止
+func (cli *CLI) restoreWallet(mnemonic, seedPassphrase string, gapLimit int, nodeID, walletName string) {
+	seed, err := wallet.MnemonicToSeed(mnemonic, seedPassphrase)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets, _ := loadWallets(walletName)
+	if wallets.IsHD() {
+		log.Panic("ERROR: 钱包文件已经是分层确定性钱包，请用createwallet创建新钱包后恢复")
+	}
+	if err := wallets.SetSeed(seed); err != nil {
+		log.Panic(err)
+	}
+
+	bc := core.NewBlockchain(nodeID)
+	defer bc.Database.Close()
+	UTXOSet := core.UTXOSet{Blockchain: bc}
+
+	fmt.Println("正在扫描区块链...")
+	used := bc.UsedPubKeyHashes()
+	isUsed := func(pubKeyHash []byte) bool {
+		return used[hex.EncodeToString(pubKeyHash)]
+	}
+	receive, err := wallets.Discover(wallet.ReceiveChain, gapLimit, isUsed)
+	if err != nil {
+		log.Panic(err)
+	}
+	change, err := wallets.Discover(wallet.ChangeChain, gapLimit, isUsed)
+	if err != nil {
+		log.Panic(err)
+	}
+	if receive == 0 { //没有用过的收款地址时派生一个新地址
+		wallets.CreateWallet()
+	}
+	wallets.SaveToFile(walletName)
+
+	total := 0
+	for _, chain := range []uint32{wallet.ReceiveChain, wallet.ChangeChain} {
+		for index := uint32(0); index < wallets.HD.Next(chain); index++ {
+			w, err := wallets.DeriveWallet(chain, index)
+			if err != nil {
+				log.Panic(err)
+			}
+			if legacy := wallets.FindByPubKeyHash(wallet.HashPubKey(wallet.LegacyPubKey(&w.PrivateKey.PublicKey))); legacy != nil {
+				w = legacy //旧版本编码的公钥用过的地址
+			}
+			balance := 0
+			for _, out := range UTXOSet.FindUTXO(wallet.HashPubKey(w.PublicKey)) {
+				balance += out.Value
+			}
+			total += balance
+			fmt.Printf("%s  %s  %d\n", w.HDPath, w.GetAddress(), balance)
+		}
+	}
+	fmt.Printf("已恢复%d个收款地址、%d个找零地址，余额合计: %d\n", receive, change, total)
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"zzschain/core"
+	"zzschain/wallet"
+)
+
+//initiate 发起原子交换：生成秘密，并在本链上锁定amount给to
+func (cli *CLI) initiate(from, to string, amount int, lockBlocks int64, nodeID, walletName string, mineNow bool) {
+	secret, secretHash := core.NewSecret()
+
+	contract := cli.lockHTLC(from, to, amount, secretHash, lockBlocks, nodeID, walletName, mineNow)
+
+	fmt.Printf("秘密:     %s\n", core.Encode(secret))
+	fmt.Printf("秘密哈希: %s\n", core.Encode(secretHash))
+	fmt.Printf("合约交易: %s\n", core.Encode(contract.ID))
+	fmt.Println("请妥善保存秘密，在对方参与前不要公开！")
+}
+
+//participate 参与原子交换：使用发起方公布的秘密哈希，在本链上锁定amount给to
+func (cli *CLI) participate(from, to string, amount int, secretHash string, lockBlocks int64, nodeID, walletName string, mineNow bool) {
+	hash, err := core.Decode(secretHash)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	contract := cli.lockHTLC(from, to, amount, hash, lockBlocks, nodeID, walletName, mineNow)
+
+	fmt.Printf("合约交易: %s\n", core.Encode(contract.ID))
+}
+
+//lockHTLC 创建并提交HTLC合约交易，锁定区块号为当前区块号加上lockBlocks
+func (cli *CLI) lockHTLC(from, to string, amount int, secretHash []byte, lockBlocks int64, nodeID, walletName string, mineNow bool) *core.Transaction {
+	if !wallet.ValidateAddress(from) {
+		log.Panic("ERROR: 发送地址非法")
+	}
+	if !wallet.ValidateAddress(to) {
+		log.Panic("ERROR: 接收地址非法")
+	}
+	bc := core.NewBlockchain(nodeID)
+	UTXOSet := core.UTXOSet{Blockchain: bc}
+	defer bc.Database.Close()
+	wallets, err := loadWallets(walletName)
+	if err != nil {
+		log.Panic(err)
+	}
+	w, err := wallets.SigningWallet(from)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	lockTime := bc.GetBestNumber().Int64() + lockBlocks
+	tx, err := core.NewHTLCTransaction(w, []byte(to), amount, secretHash, lockTime, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+	commitTransaction(bc, tx, 0, from, walletName, mineNow)
+	fmt.Printf("合约锁定至区块号: %d\n", lockTime)
+
+	return tx
+}
+
+//redeem 接收方公开秘密，赎回合约
+func (cli *CLI) redeem(address, contractID, secret string, nodeID, walletName string, mineNow bool) {
+	bc, w, contract := cli.loadHTLC(address, contractID, nodeID, walletName)
+	UTXOSet := core.UTXOSet{Blockchain: bc}
+	defer bc.Database.Close()
+
+	preimage, err := core.Decode(secret)
+	if err != nil {
+		log.Panic(err)
+	}
+	tx, err := core.NewHTLCRedeemTransaction(w, &contract, preimage, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+	commitTransaction(bc, tx, 0, address, walletName, mineNow)
+
+	fmt.Printf("赎回交易: %s\n", core.Encode(tx.ID))
+}
+
+//refund 发送方在合约超时后取回锁定的币
+func (cli *CLI) refund(address, contractID string, nodeID, walletName string, mineNow bool) {
+	bc, w, contract := cli.loadHTLC(address, contractID, nodeID, walletName)
+	UTXOSet := core.UTXOSet{Blockchain: bc}
+	defer bc.Database.Close()
+
+	tx, err := core.NewHTLCRefundTransaction(w, &contract, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+	next := new(big.Int).Add(bc.GetBestNumber(), core.Big1)
+	if mineNow && !tx.IsFinal(next) {
+		log.Panicf("ERROR: 合约锁定至区块号%d，尚不能退款", tx.LockTime)
+	}
+	commitTransaction(bc, tx, 0, address, walletName, mineNow)
+
+	fmt.Printf("退款交易: %s\n", core.Encode(tx.ID))
+}
+
+//loadHTLC 打开区块链，读取钱包address和合约交易contractID
+//注意，返回的区块链数据库是open状态，需要调用者负责close
+func (cli *CLI) loadHTLC(address, contractID string, nodeID, walletName string) (*core.Blockchain, *wallet.Wallet, core.Transaction) {
+	if !wallet.ValidateAddress(address) {
+		log.Panic("ERROR: 地址非法")
+	}
+	txID, err := core.Decode(contractID)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets, err := loadWallets(walletName)
+	if err != nil {
+		log.Panic(err)
+	}
+	w, err := wallets.SigningWallet(address)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc := core.NewBlockchain(nodeID)
+	contract, err := bc.FindTransaction(txID)
+	if err != nil {
+		bc.Database.Close()
+		log.Panic(err)
+	}
+
+	return bc, w, contract
+}
+
+//extractSecret 从赎回交易中提取秘密
+//txID为赎回交易；也可以只给出合约交易contractID，由本节点查找花费该合约的交易
+func (cli *CLI) extractSecret(txID, contractID, secretHash string, nodeID string) {
+	hash, err := core.Decode(secretHash)
+	if err != nil {
+		log.Panic(err)
+	}
+	bc := core.NewBlockchain(nodeID)
+	defer bc.Database.Close()
+
+	var redeem core.Transaction
+	if txID != "" {
+		id, err := core.Decode(txID)
+		if err != nil {
+			log.Panic(err)
+		}
+		redeem, err = bc.FindTransaction(id)
+		if err != nil {
+			log.Panic(err)
+		}
+	} else {
+		id, err := core.Decode(contractID)
+		if err != nil {
+			log.Panic(err)
+		}
+		contract, err := bc.FindTransaction(id)
+		if err != nil {
+			log.Panic(err)
+		}
+		vout, _, err := contract.FindHTLC()
+		if err != nil {
+			log.Panic(err)
+		}
+		redeem, err = bc.FindSpendingTransaction(id, vout)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	secret, err := core.ExtractSecret(&redeem, hash)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("秘密: %s\n", core.Encode(secret))
+}
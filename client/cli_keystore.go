@@ -0,0 +1,59 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"zzschain/core"
+	"zzschain/wallet"
+)
+
+//exportKeystore 用passphrase将地址address的私钥导出为keystore v3文件，out为空时输出到终端
+func (cli *CLI) exportKeystore(address, passphrase, out string, walletName string) {
+	wallets, _ := loadWallets(walletName)
+	content, err := wallets.ExportKeystore(address, passphrase)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if out == "" {
+		fmt.Println(string(content))
+		return
+	}
+	if err := ioutil.WriteFile(out, content, 0600); err != nil {
+		log.Panic(err)
+	}
+	fmt.Printf("地址%s的私钥已导出到%s\n", address, out)
+}
+
+//importKeystore 用passphrase解密keystore v3文件并将私钥加入钱包，rescan为true时扫描区块链找出该地址的交易与余额
+func (cli *CLI) importKeystore(file, passphrase string, rescan bool, nodeID, walletName string) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets, _ := loadWallets(walletName)
+	address, err := wallets.ImportKeystore(content, passphrase)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(walletName)
+	fmt.Printf("已导入地址: %s\n", address)
+
+	if !rescan {
+		return
+	}
+	bc := core.NewBlockchain(nodeID)
+	defer bc.Database.Close()
+	result := bc.RescanAddress(address)
+	fmt.Printf("扫描完成，交易%d笔，余额: %d\n", result.Transactions, result.Balance)
+}
+
+//migrateKeystore 将钱包文件转换为目录形式，每个私钥保存为一个用钱包密码加密的keystore文件
+func (cli *CLI) migrateKeystore(passphrase string, walletName string) {
+	if err := wallet.MigrateToKeystoreDir(walletName, passphrase); err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println("钱包已转换为目录形式，原钱包文件已改名为.bak，确认无误后可以删除")
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"zzschain/wallet"
+)
+
+//signMessage 用地址address的私钥签名消息，证明对地址的控制权
+func (cli *CLI) signMessage(address, message string, walletName string) {
+	wallets, _ := loadWallets(walletName)
+	signature, err := wallets.SignMessage(address, message)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(signature)
+}
+
+//verifyMessage 验证signature是地址address对消息message的签名
+func (cli *CLI) verifyMessage(address, signature, message string) {
+	valid, err := wallet.VerifyMessage(address, signature, message)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if valid {
+		fmt.Println("签名有效")
+	} else {
+		fmt.Println("签名无效")
+	}
+}
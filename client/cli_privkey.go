@@ -0,0 +1,62 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"zzschain/core"
+)
+
+//importPrivKey 将带校验码的私钥加入钱包文件，rescan为true时扫描区块链找出该地址的交易与余额
+func (cli *CLI) importPrivKey(privKey string, rescan bool, nodeID, walletName string) {
+	wallets, _ := loadWallets(walletName)
+	address, err := wallets.ImportPrivateKey(privKey)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(walletName)
+	fmt.Printf("已导入地址: %s\n", address)
+
+	if !rescan {
+		return
+	}
+	bc := core.NewBlockchain(nodeID)
+	defer bc.Database.Close()
+	result := bc.RescanAddress(address)
+	fmt.Printf("扫描完成，交易%d笔，余额: %d\n", result.Transactions, result.Balance)
+}
+
+//dumpPrivKey 显示地址address带校验码的私钥
+func (cli *CLI) dumpPrivKey(address string, walletName string) {
+	wallets, _ := loadWallets(walletName)
+	privKey, err := wallets.DumpPrivateKey(address)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(privKey)
+}
+
+//convertKeys 为旧版本编码公钥的地址加入SEC1压缩公钥的新地址，旧地址上的币可以转到新地址
+func (cli *CLI) convertKeys(walletName string) {
+	wallets, _ := loadWallets(walletName)
+	converted, err := wallets.ConvertLegacyKeys()
+	if err != nil {
+		log.Panic(err)
+	}
+	if len(converted) == 0 {
+		fmt.Println("钱包中没有旧版本编码的公钥")
+		return
+	}
+	wallets.SaveToFile(walletName)
+
+	var legacy []string
+	for address := range converted {
+		legacy = append(legacy, address)
+	}
+	sort.Strings(legacy)
+	for _, address := range legacy {
+		fmt.Printf("%s -> %s\n", address, converted[address])
+	}
+	fmt.Printf("已转换%d个地址，旧地址上的币仍可花费，建议转到新地址\n", len(converted))
+}
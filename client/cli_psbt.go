@@ -0,0 +1,163 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"zzschain/core"
+	"zzschain/wallet"
+)
+
+//createPSBT 在只有地址的节点（观察钱包）上创建部分签名交易，保存到文件out
+//收款人由to、amount给出，或者由付款列表文件payFile给出
+func (cli *CLI) createPSBT(from, to string, amount int, payFile string, fee int, replaceable bool, selector string, out string, nodeID string) {
+	if !wallet.ValidateAddress(from) {
+		log.Panic("ERROR: 发送地址非法")
+	}
+
+	var payments []core.Payment
+	if payFile != "" {
+		var err error
+		payments, err = core.LoadPayments(payFile)
+		if err != nil {
+			log.Panic(err)
+		}
+	} else {
+		payments = []core.Payment{{Address: to, Amount: amount}}
+	}
+
+	bc := core.NewBlockchain(nodeID)
+	UTXOSet := core.UTXOSet{Blockchain: bc}
+	defer bc.Database.Close()
+
+	opts := core.SendOptions{Fee: fee, Replaceable: replaceable}
+	var err error
+	opts.Selector, err = core.NewCoinSelector(selector)
+	if err != nil {
+		log.Panic(err)
+	}
+	psbt, err := core.NewPSBT([]byte(from), payments, &UTXOSet, opts)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	writePSBT(psbt, out)
+	printPSBT(psbt)
+	fmt.Printf("部分签名交易已保存到%s\n", out)
+}
+
+//signPSBT 使用本地钱包文件中的私钥对部分签名交易签名，不需要区块链数据，可以在离线的机器上执行
+func (cli *CLI) signPSBT(in, out string, walletName string) {
+	psbt := readPSBT(in)
+	printPSBT(psbt)
+
+	wallets, err := loadWallets(walletName)
+	if err != nil {
+		log.Panic(err)
+	}
+	if wallets.IsLocked() {
+		log.Panic(wallet.ErrWalletLocked)
+	}
+	signed := 0
+	for _, address := range wallets.GetAddresses() {
+		w := wallets.GetWallet(address)
+		signed += psbt.Sign(&w)
+	}
+	if signed == 0 {
+		log.Panic("ERROR: 本地钱包中没有可以签名的输入")
+	}
+
+	writePSBT(psbt, out)
+	fmt.Printf("已签名%d个输入，全部签名完成: %t\n", signed, psbt.IsComplete())
+	fmt.Printf("部分签名交易已保存到%s\n", out)
+}
+
+//combinePSBT 合并多个签名者分别签名的部分签名交易
+func (cli *CLI) combinePSBT(in string, out string) {
+	var psbts []*core.PSBT
+	for _, file := range strings.Split(in, ",") {
+		psbts = append(psbts, readPSBT(strings.TrimSpace(file)))
+	}
+
+	combined, err := core.CombinePSBT(psbts)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	writePSBT(combined, out)
+	fmt.Printf("全部签名完成: %t\n", combined.IsComplete())
+	fmt.Printf("部分签名交易已保存到%s\n", out)
+}
+
+//finalizePSBT 校验全部签名，得到完整交易；send为true时广播（或在本节点挖矿，奖励付给minerAddress）
+func (cli *CLI) finalizePSBT(in string, send bool, nodeID, walletName string, mineNow bool, minerAddress string) {
+	if send && mineNow && !wallet.ValidateAddress(minerAddress) {
+		log.Panic("ERROR: 挖矿奖励地址非法")
+	}
+	psbt := readPSBT(in)
+	tx, err := psbt.Finalize()
+	if err != nil {
+		log.Panic(err)
+	}
+	fmt.Printf("交易: %s\n", core.Encode(tx.ID))
+	fmt.Printf("%x\n", tx.Serialize())
+	if !send {
+		return
+	}
+
+	bc := core.NewBlockchain(nodeID)
+	defer bc.Database.Close()
+	if !bc.VerifyTransaction(tx) {
+		log.Panic("ERROR: 交易校验失败，输入可能已被花费")
+	}
+	commitTransaction(bc, tx, psbt.Fee(), minerAddress, walletName, mineNow)
+
+	fmt.Println("交易已广播！")
+}
+
+//printPSBT 显示部分签名交易的付款明细与手续费，供签名前核对
+func printPSBT(psbt *core.PSBT) {
+	fprintPSBT(os.Stdout, psbt)
+}
+
+//fprintPSBT 将部分签名交易的付款明细与手续费写入out
+func fprintPSBT(out io.Writer, psbt *core.PSBT) {
+	fmt.Fprintf(out, "交易: %s\n", core.Encode(psbt.Tx.ID))
+	for i, vout := range psbt.Tx.Vout {
+		if vout.IsDataCarrier() {
+			fmt.Fprintf(out, "  输出%d: 数据 %x\n", i, vout.Data)
+			continue
+		}
+		if vout.IsHTLC() {
+			fmt.Fprintf(out, "  输出%d: HTLC %s %d\n", i, vout.HTLC, vout.Value)
+			continue
+		}
+		fmt.Fprintf(out, "  输出%d: %s %d\n", i, wallet.PubKeyHashToAddress(vout.PubKeyHash), vout.Value)
+	}
+	fmt.Fprintf(out, "  手续费: %d\n", psbt.Fee())
+}
+
+//readPSBT 从文件读取部分签名交易
+func readPSBT(file string) *core.PSBT {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Panic(err)
+	}
+	psbt, err := core.DeserializePSBT(data)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return psbt
+}
+
+//writePSBT 保存部分签名交易到文件
+func writePSBT(psbt *core.PSBT, file string) {
+	err := ioutil.WriteFile(file, psbt.Serialize(), 0644)
+	if err != nil {
+		log.Panic(err)
+	}
+}
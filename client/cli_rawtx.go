@@ -0,0 +1,110 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"zzschain/core"
+	"zzschain/wallet"
+)
+
+//createRawTransaction 由明确的输入（txid:vout）与输出（地址:金额）创建未签名的原始交易，输出hex
+func (cli *CLI) createRawTransaction(inputs, outputs, data string, lockTime int64, replaceable bool) {
+	ops, err := core.ParseOutPoints(inputs)
+	if err != nil {
+		log.Panic(err)
+	}
+	payments, err := core.ParsePaymentList(outputs)
+	if err != nil {
+		log.Panic(err)
+	}
+	var payload []byte
+	if data != "" {
+		payload, err = core.ParseData(data)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	tx, err := core.CreateRawTransaction(ops, payments, payload, lockTime, replaceable)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(core.EncodeRawTransaction(tx))
+}
+
+//decodeRawTransaction 将原始交易hex解码为人可读的JSON
+func (cli *CLI) decodeRawTransaction(rawHex string) {
+	tx, err := core.DecodeRawTransaction(rawHex)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	out, err := json.MarshalIndent(core.NewRawTransaction(tx), "", "  ")
+	if err != nil {
+		log.Panic(err)
+	}
+	fmt.Println(string(out))
+}
+
+//signRawTransaction 使用给出的私钥签名原始交易，被花费的输出从本节点的UTXO集中查找
+func (cli *CLI) signRawTransaction(rawHex, privateKey string, nodeID string) {
+	tx, err := core.DecodeRawTransaction(rawHex)
+	if err != nil {
+		log.Panic(err)
+	}
+	signer, err := wallet.NewWalletFromPrivateKey(privateKey)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc := core.NewBlockchain(nodeID)
+	UTXOSet := core.UTXOSet{Blockchain: bc}
+	defer bc.Database.Close()
+
+	signed, err := core.SignRawTransaction(tx, signer, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(core.EncodeRawTransaction(tx))
+	fmt.Printf("已签名%d个输入，全部签名完成: %t\n", signed, tx.IsSigned())
+}
+
+//sendRawTransaction 将签名完成的原始交易提交到节点node的交易池，节点返回的校验错误原样输出
+func (cli *CLI) sendRawTransaction(rawHex string, node string) {
+	if _, err := core.DecodeRawTransaction(rawHex); err != nil {
+		log.Panic(err)
+	}
+
+	body, err := json.Marshal(core.RawTx{Hex: rawHex})
+	if err != nil {
+		log.Panic(err)
+	}
+	resp, err := http.Post(fmt.Sprintf("http://%s/sendrawtransaction", node), "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Panic(err)
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Panic(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		fmt.Println(strings.TrimSpace(string(content)))
+		os.Exit(1)
+	}
+
+	var result core.RawTx
+	if err := json.Unmarshal(content, &result); err != nil {
+		log.Panic(err)
+	}
+	fmt.Printf("交易已提交: %s\n", result.TxID)
+}
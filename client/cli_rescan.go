@@ -0,0 +1,65 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"zzschain/core"
+	"zzschain/wallet"
+)
+
+//rescanCheckpoint 每扫描这么多个区块保存一次钱包缓存
+const rescanCheckpoint = 100
+
+//rescanWallet 遍历一次区块链，为钱包文件中的全部地址重建钱包缓存
+//from小于0时从上次扫描到的位置继续；按Ctrl+C在当前区块扫描完成后停止，再次执行即可继续
+func (cli *CLI) rescanWallet(from int64, nodeID, walletName string) {
+	wallets, _ := wallet.NewWallets(walletName)
+	var pubKeyHashes [][]byte
+	for _, w := range wallets.Wallets {
+		pubKeyHashes = append(pubKeyHashes, w.GetPubKeyHash())
+	}
+
+	cache, _ := core.NewWalletCache(walletName)
+	if from < 0 && cache.Height >= 0 {
+		if missing := cache.MissingKeys(pubKeyHashes); missing > 0 {
+			fmt.Printf("钱包中有%d个地址没有扫描过，从创世块重新扫描\n", missing)
+		} else {
+			fmt.Printf("从区块%d继续扫描\n", cache.Height+1)
+		}
+	}
+
+	bc := core.NewBlockchain(nodeID)
+	defer bc.Database.Close()
+
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			fmt.Println("\n正在停止，当前区块扫描完成后保存进度...")
+			close(stop)
+		}
+	}()
+
+	opts := core.RescanOptions{
+		From: from,
+		Stop: stop,
+		Progress: func(height, tip int64) {
+			if height%rescanCheckpoint == 0 || height == tip {
+				cache.SaveToFile(walletName)
+				fmt.Printf("已扫描区块 %d/%d (%.1f%%)\n", height, tip, float64(height+1)*100/float64(tip+1))
+			}
+		},
+	}
+	done := bc.RescanWallet(cache, pubKeyHashes, opts)
+	cache.SaveToFile(walletName)
+
+	if !done {
+		fmt.Printf("扫描已中断，已扫描到区块%d，再次执行rescanwallet即可继续\n", cache.Height)
+		return
+	}
+	fmt.Printf("扫描完成，已扫描到区块%d，相关交易%d笔，未花费输出%d个，余额合计: %d\n",
+		cache.Height, len(cache.Txs), len(cache.Unspent()), cache.Balance())
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"zzschain/core"
+	"zzschain/wallet"
+)
+
+//stdio 签名程序由节点启动时，请求与响应经过标准输入输出
+type stdio struct {
+	io.Reader
+	io.Writer
+}
+
+//runSigner 作为外部签名程序运行：持有本地钱包的私钥，对节点发来的每个签名请求显示交易摘要，由用户批准或拒绝
+//socket不为空时监听该unix套接字，在本终端询问用户；否则经标准输入输出与启动它的节点通信，从/dev/tty询问用户
+func (cli *CLI) runSigner(socket string, walletName string) {
+	wallets, err := loadWallets(walletName)
+	if err != nil {
+		log.Panic(err)
+	}
+	if wallets.IsLocked() {
+		log.Panic(wallet.ErrWalletLocked)
+	}
+	var keys []*wallet.Wallet
+	for _, address := range wallets.GetAddresses() {
+		if w := wallets.Wallets[address]; w.CanSign() == nil {
+			keys = append(keys, w)
+		}
+	}
+	if len(keys) == 0 {
+		log.Panic("ERROR: 钱包中没有可以签名的私钥")
+	}
+	signer := core.NewWalletSigner(keys...)
+
+	if socket == "" {
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			log.Panic(fmt.Errorf("ERROR: 经标准输入输出签名时需要终端询问用户: %v", err))
+		}
+		defer tty.Close()
+		if err := core.ServeSigner(stdio{os.Stdin, os.Stdout}, signer, newApprover(tty, tty, wallets)); err != nil {
+			log.Panic(err)
+		}
+		return
+	}
+
+	//上次异常退出留下的套接字文件
+	if info, err := os.Stat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(socket)
+	}
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer ln.Close()
+	if err := os.Chmod(socket, 0600); err != nil {
+		log.Panic(err)
+	}
+	fmt.Printf("签名程序已启动，监听%s，共%d个私钥\n", socket, len(keys))
+
+	//多个连接的请求依次在终端询问
+	var mu sync.Mutex
+	approve := newApprover(os.Stdin, os.Stdout, wallets)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Panic(err)
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			err := core.ServeSigner(conn, signer, func(psbt *core.PSBT) bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return approve(psbt)
+			})
+			if err != nil {
+				log.Println(err)
+			}
+		}(conn)
+	}
+}
+
+//dialSigner 连接外部签名者，endpoint为空时返回nil，由本地钱包中的私钥签名；返回的函数断开连接
+func dialSigner(endpoint string) (core.Signer, func()) {
+	if endpoint == "" {
+		return nil, func() {}
+	}
+	s, err := core.DialSigner(endpoint)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return s, func() { s.Close() }
+}
+
+//newApprover 返回询问用户的函数：向out显示交易的输入、输出与手续费，从in读取用户的回答，y表示批准
+func newApprover(in io.Reader, out io.Writer, wallets *wallet.Wallets) func(psbt *core.PSBT) bool {
+	reader := bufio.NewReader(in)
+
+	return func(psbt *core.PSBT) bool {
+		fmt.Fprintln(out, "收到签名请求")
+		fprintPSBT(out, psbt)
+		for i, prevOut := range psbt.PrevOuts {
+			owner := "非本钱包"
+			if w := wallets.FindByPubKeyHash(prevOut.PubKeyHash); w != nil {
+				owner = "将签名 " + string(w.GetAddress())
+			}
+			switch {
+			case len(psbt.Tx.Vin[i].Signature) > 0:
+				owner = "已签名"
+			case prevOut.IsHTLC():
+				owner = "HTLC合约"
+			}
+			fmt.Fprintf(out, "  输入%d: %d %s\n", i, prevOut.Value, owner)
+		}
+		fmt.Fprint(out, "批准签名？(y/N): ")
+
+		answer, err := reader.ReadString('\n')
+		if err != nil && answer == "" {
+			return false
+		}
+		approved := strings.EqualFold(strings.TrimSpace(answer), "y")
+		if approved {
+			fmt.Fprintln(out, "已批准")
+		} else {
+			fmt.Fprintln(out, "已拒绝")
+		}
+		return approved
+	}
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"zzschain/core"
+	"zzschain/wallet"
+)
+
+//getNewStealthAddress 创建新的隐身地址并保存到钱包walletName
+func (cli *CLI) getNewStealthAddress(walletName string) {
+	wallets, _ := loadWallets(walletName) //已加密时用WALLET_PASSPHRASE解锁
+	address, err := wallets.NewStealthAddress()
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(walletName)
+
+	fmt.Printf("你的新隐身地址是: %s\n", address)
+	fmt.Println("付款人每次付款都派生新的一次性地址，链上看不出收款人；用scanstealth扫描区块找到收到的币")
+}
+
+//listStealthAddresses 列出钱包walletName中的隐身地址，以及扫描找到的一次性地址与余额
+func (cli *CLI) listStealthAddresses(nodeID, walletName string) {
+	wallets, err := wallet.NewWallets(walletName)
+	if err != nil {
+		log.Panic(err)
+	}
+	bc := core.NewBlockchain(nodeID)
+	defer bc.Database.Close()
+	UTXOSet := core.UTXOSet{Blockchain: bc}
+
+	for _, address := range wallets.StealthAddresses() {
+		received := wallets.StealthReceived(address)
+		balances := make([]int, len(received))
+		total := 0
+		for i, oneTime := range received {
+			for _, out := range UTXOSet.FindUTXO(wallet.AddressToPubKeyHash([]byte(oneTime))) {
+				balances[i] += out.Value
+			}
+			total += balances[i]
+		}
+
+		fmt.Printf("%s  %d  (已扫描到区块%d)\n", address, total, wallets.Stealth[address].Height)
+		for i, oneTime := range received {
+			fmt.Printf("  一次性 %s  %d\n", oneTime, balances[i])
+		}
+	}
+}
+
+//scanStealth 从区块from开始扫描区块链，找出付给钱包walletName中隐身地址的输出，将一次性地址的私钥加入钱包
+//from小于0时从上次扫描到的位置继续
+func (cli *CLI) scanStealth(from int64, nodeID, walletName string) {
+	wallets, err := loadWallets(walletName)
+	if err != nil {
+		log.Panic(err)
+	}
+	bc := core.NewBlockchain(nodeID)
+	defer bc.Database.Close()
+
+	payments, err := bc.ScanStealth(wallets, from)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(walletName)
+
+	total := 0
+	for _, p := range payments {
+		fmt.Printf("区块%d 交易%s:%d 付给%s，一次性地址%s，金额%d\n", p.Height, p.TxID, p.Vout, p.Stealth, p.Address, p.Value)
+		total += p.Value
+	}
+	height, _ := wallets.StealthHeight()
+	fmt.Printf("扫描完成，已扫描到区块%d，找到%d笔付款，合计: %d\n", height, len(payments), total)
+	if len(payments) > 0 {
+		fmt.Println("一次性地址已加入钱包，可以用send -from 一次性地址花费；钱包缓存需要用rescanwallet重新扫描")
+	}
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"time"
+	"zzschain/wallet"
+)
+
+//vanityAddress 并行生成私钥，直到地址以prefix开头、以suffix结尾，将私钥加入钱包walletName
+//搜索前显示难度与预计用时，搜索中每秒显示进度；按Ctrl+C放弃搜索
+func (cli *CLI) vanityAddress(prefix, suffix string, ignoreCase bool, keyType string, workers int, walletName string) {
+	if keyType != "p256" && keyType != "secp256k1" {
+		log.Panic(fmt.Errorf("ERROR: 不支持的私钥类型%q，请使用p256或secp256k1", keyType))
+	}
+	pattern := &wallet.VanityPattern{Prefix: prefix, Suffix: suffix, IgnoreCase: ignoreCase, Secp256k1: keyType == "secp256k1"}
+	if err := pattern.Validate(); err != nil {
+		log.Panic(err)
+	}
+	//找到的私钥必须能加入钱包，搜索前先检查钱包
+	wallets, _ := loadWallets(walletName)
+	if wallets.IsLocked() {
+		log.Panic(wallet.ErrWalletLocked)
+	}
+
+	difficulty := pattern.Difficulty()
+	rate := pattern.VanityRate(workers)
+	average, half := pattern.ExpectedTime(rate)
+	fmt.Printf("难度: 平均需要生成%.0f个地址\n", difficulty)
+	fmt.Printf("速度: 每秒约%.0f个地址（%d个协程）\n", rate, workers)
+	fmt.Printf("预计用时: 平均%s，50%%的概率在%s内找到\n", formatDuration(average), formatDuration(half))
+
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			close(stop)
+		}
+	}()
+
+	start := time.Now()
+	w := wallet.SearchVanity(pattern, wallet.VanityOptions{
+		Workers: workers,
+		Stop:    stop,
+		Progress: func(attempts uint64) {
+			elapsed := time.Since(start)
+			//已生成attempts个地址时至少找到一个的概率
+			found := -math.Expm1(float64(attempts) * math.Log1p(-1/difficulty))
+			fmt.Printf("已生成%d个地址，每秒%.0f个，用时%s，找到的概率%.1f%%\n", attempts, float64(attempts)/elapsed.Seconds(), formatDuration(elapsed), found*100)
+		},
+	})
+	if w == nil {
+		fmt.Println("\n已放弃搜索")
+		return
+	}
+
+	address, err := wallets.ImportVanityWallet(w)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(walletName)
+	fmt.Printf("用时%s，找到地址: %s\n", formatDuration(time.Since(start)), address)
+	fmt.Printf("私钥已加入钱包%s，可用dumpprivkey -address %s导出\n", walletName, address)
+}
+
+//formatDuration 将时长显示为人可读的形式，超过一天时以天或年为单位
+func formatDuration(d time.Duration) string {
+	const day = 24 * time.Hour
+	const year = 365 * day
+	switch {
+	case d == time.Duration(math.MaxInt64):
+		return "数百年以上"
+	case d >= year:
+		return fmt.Sprintf("%.1f年", d.Hours()/year.Hours())
+	case d >= day:
+		return fmt.Sprintf("%.1f天", d.Hours()/day.Hours())
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"zzschain/core"
+	"zzschain/wallet"
+)
+
+//passphraseEnv 命令行签名时从该环境变量读取钱包密码，只在本条命令执行期间解锁
+const passphraseEnv = "WALLET_PASSPHRASE"
+
+//loadWallets 读取钱包文件；钱包已加密且设置了环境变量WALLET_PASSPHRASE时用其解锁
+func loadWallets(walletName string) (*wallet.Wallets, error) {
+	wallets, err := wallet.NewWallets(walletName)
+	if err != nil {
+		return wallets, err
+	}
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" && wallets.IsEncrypted() {
+		if err := wallets.Unlock(passphrase, time.Minute); err != nil {
+			return wallets, err
+		}
+	}
+
+	return wallets, nil
+}
+
+//encryptWallet 用密码加密钱包文件中的全部私钥，加密后签名前需要解锁
+func (cli *CLI) encryptWallet(passphrase string, walletName string) {
+	wallets, err := wallet.NewWallets(walletName)
+	if err != nil {
+		log.Panic(err)
+	}
+	if err := wallets.Encrypt(passphrase); err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(walletName)
+
+	fmt.Printf("钱包已加密，共%d个私钥，请牢记密码！\n", len(wallets.Wallets))
+}
+
+//walletPassphraseChange 修改钱包密码
+func (cli *CLI) walletPassphraseChange(oldPassphrase, newPassphrase string, walletName string) {
+	wallets, err := wallet.NewWallets(walletName)
+	if err != nil {
+		log.Panic(err)
+	}
+	if err := wallets.ChangePassphrase(oldPassphrase, newPassphrase); err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(walletName)
+
+	fmt.Println("钱包密码已修改")
+}
+
+//walletUnlock 解锁节点node上的加密钱包walletName timeout秒，期间节点可以使用钱包签名
+func (cli *CLI) walletUnlock(passphrase string, timeout int, node string, walletName string) {
+	req := core.WalletUnlock{Passphrase: passphrase, Timeout: timeout}
+	fmt.Println(postNode(node, walletPath("walletunlock", walletName), req))
+}
+
+//walletLock 立即锁定节点node上的加密钱包walletName
+func (cli *CLI) walletLock(node string, walletName string) {
+	fmt.Println(postNode(node, walletPath("walletlock", walletName), struct{}{}))
+}
+
+//walletPath 在节点接口path后加上指定钱包的查询参数
+func walletPath(path, walletName string) string {
+	return path + "?wallet=" + url.QueryEscape(walletName)
+}
+
+//postNode 向节点node的管理接口path提交JSON请求，返回节点的消息；请求失败时输出节点返回的错误并退出
+func postNode(node, path string, req interface{}) string {
+	body, err := json.Marshal(req)
+	if err != nil {
+		log.Panic(err)
+	}
+	resp, err := http.Post(fmt.Sprintf("http://%s/%s", node, path), "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Panic(err)
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Panic(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		fmt.Println(strings.TrimSpace(string(content)))
+		os.Exit(1)
+	}
+
+	var result core.Resp
+	if err := json.Unmarshal(content, &result); err != nil {
+		log.Panic(err)
+	}
+	return result.Message
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"zzschain/core"
+	"zzschain/wallet"
+)
+
+//createNamedWallet 在钱包数据目录中创建空的命名钱包，之后钱包命令用-wallet name使用该钱包
+func (cli *CLI) createNamedWallet(name string) {
+	if _, err := wallet.CreateNamedWallet(name); err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("钱包%s已创建，保存在%s\n", name, wallet.DataDir)
+	fmt.Printf("用getnewaddress -wallet %s创建地址，或用createhdwallet、restorewallet设置种子\n", name)
+}
+
+//loadWallet 由节点node加载钱包name
+func (cli *CLI) loadWallet(name string, node string) {
+	fmt.Println(postNode(node, "loadwallet", core.WalletName{Name: name}))
+}
+
+//unloadWallet 由节点node卸载钱包name
+func (cli *CLI) unloadWallet(name string, node string) {
+	fmt.Println(postNode(node, "unloadwallet", core.WalletName{Name: name}))
+}
+
+//listWallets 列出钱包数据目录中的钱包；node不为空时列出该节点的钱包，已加载的钱包标记为已加载
+func (cli *CLI) listWallets(node string) {
+	var list core.WalletList
+	if node == "" {
+		names, err := wallet.ListWallets()
+		if err != nil {
+			log.Panic(err)
+		}
+		list.Wallets = names
+	} else {
+		resp, err := http.Get(fmt.Sprintf("http://%s/listwallets", node))
+		if err != nil {
+			log.Panic(err)
+		}
+		defer resp.Body.Close()
+		content, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			log.Panic(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			fmt.Println(strings.TrimSpace(string(content)))
+			os.Exit(1)
+		}
+		if err := json.Unmarshal(content, &list); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	loaded := make(map[string]bool)
+	for _, name := range list.Loaded {
+		loaded[name] = true
+	}
+	for _, name := range list.Wallets {
+		if loaded[name] {
+			fmt.Printf("%s  (已加载)\n", name)
+		} else {
+			fmt.Println(name)
+		}
+	}
+}
@@ -22,7 +22,7 @@ var nodeAddress string                      //当前节点地址
 var miningAddress string                    //挖矿节点地址
 var knownNodes = []string{"localhost:3000"} //初始化为中心节点
 var blocksInTransit = [][]byte{}            //待下载的区块，用于跟踪下载区块
-var mempool = make(map[string]core.Transaction)
+var mempool = core.NewMempool()                //交易池
 
 // addr 服务器列表
 type addr struct {
@@ -242,6 +242,7 @@ func handleBlock(request []byte, bc *core.Blockchain) {
 
 	fmt.Println("接收到一个新区块!")
 	bc.AddBlock(block)
+	mempool.RemoveBlock(block) //区块中的交易已上链，与之双花的交易也不再有效
 
 	fmt.Printf("添加到区块： %x\n", block.Hash)
 
@@ -292,7 +293,7 @@ func handleInv(request []byte, bc *core.Blockchain) {
 	if payload.Type == "tx" {
 		txID := payload.Items[0] //本案例中，不会存在传送多个tx的情形
 
-		if mempool[hex.EncodeToString(txID)].ID == nil {
+		if !mempool.Has(hex.EncodeToString(txID)) {
 			sendGetData(payload.AddrFrom, "tx", txID) //向对方请求某条交易信息
 		}
 	}
@@ -338,7 +339,10 @@ func handleGetData(request []byte, bc *core.Blockchain) {
 
 	if payload.Type == "tx" {
 		txID := hex.EncodeToString(payload.ID)
-		tx := mempool[txID]
+		tx, ok := mempool.Get(txID)
+		if !ok {
+			return
+		}
 
 		sendTx(payload.AddrFrom, &tx)
 		// delete(mempool, txID)
@@ -359,63 +363,71 @@ func handleTx(request []byte, bc *core.Blockchain) {
 
 	txData := payload.Transaction
 	tx := core.DeserializeTransaction(txData)
-	mempool[hex.EncodeToString(tx.ID)] = tx //将交易丢到待上链的交易池中
+	err = acceptTransaction(tx, payload.AddFrom, bc)
+	if err != nil {
+		fmt.Printf("拒绝交易%s: %s\n", hex.EncodeToString(tx.ID), err)
+	}
+}
+
+// acceptTransaction 将来自节点from的交易加入交易池：中心节点转发给其它节点，挖矿节点打包挖矿
+func acceptTransaction(tx core.Transaction, from string, bc *core.Blockchain) error {
+	evicted, err := mempool.Add(tx, bc) //将交易丢到待上链的交易池中，与池中交易冲突时按RBF规则处理
+	if err != nil {
+		return err
+	}
+	for _, id := range evicted {
+		fmt.Printf("交易%s已被替换\n", id)
+	}
 
 	if nodeAddress == knownNodes[0] { //当前节点为中心节点，中心节点收到新交易
 		for _, node := range knownNodes {
-			if node != nodeAddress && node != payload.AddFrom {
+			if node != nodeAddress && node != from {
 				//将当前交易ID通过inv命令发送给既非当前节点也非交易发起者节点之外的所有其它节点
 				sendInv(node, "tx", [][]byte{tx.ID})
 			}
 		}
-	} else { //当前节点为非中心节点
-		if len(mempool) >= 2 && len(miningAddress) > 0 { //如果当前是挖矿节点，打包发来的交易进行挖矿处理：minerAddress不为空值
-		MineTransactions:
-			var txs []*core.Transaction
-
-			for id := range mempool {
-				tx := mempool[id]
-				fmt.Printf("%s to be veryfied...\n", hex.EncodeToString(tx.ID))
-
-				if bc.VerifyTransaction(&tx) == true {
-					txs = append(txs, &tx)
-					fmt.Printf("%s veryfied true.\n", hex.EncodeToString(tx.ID))
-				} else {
-					fmt.Printf("%s veryfied false.\n", hex.EncodeToString(tx.ID))
-				}
-			}
+	} else if mempool.Count() >= 2 && len(miningAddress) > 0 { //如果当前是挖矿节点，打包发来的交易进行挖矿处理：minerAddress不为空值
+		mineTransactions(bc)
+	}
 
-			if len(txs) == 0 {
-				fmt.Println("所有的新交易均非法! 等待新的交易...")
-				return
-			}
+	return nil
+}
 
-			cbTx := core.NewCoinbaseTX([]byte(miningAddress), "")
-			txs = append(txs, cbTx)
+// mineTransactions 挖矿节点打包交易池中的交易挖出新区块，直到交易池为空或剩余交易均不能打包
+func mineTransactions(bc *core.Blockchain) {
+MineTransactions:
+	//按交易包的每字节手续费从高到低选取交易，父交易总是排在子交易前面
+	txs, fees := mempool.SelectTransactions(bc, core.MaxBlockTransactions)
+	for _, tx := range txs {
+		fmt.Printf("%s veryfied true.\n", hex.EncodeToString(tx.ID))
+	}
 
-			newBlock := bc.MineBlock(txs, miningAddress)
-			UTXOSet := core.UTXOSet{bc}
-			UTXOSet.Update(newBlock)
+	if len(txs) == 0 {
+		fmt.Println("所有的新交易均非法! 等待新的交易...")
+		return
+	}
 
-			fmt.Println("新区块已挖出!")
+	cbTx := core.NewCoinbaseTXWithFees([]byte(miningAddress), "", fees)
+	txs = append(txs, cbTx)
 
-			for _, tx := range txs {
-				txID := hex.EncodeToString(tx.ID)
-				delete(mempool, txID) //从交易池中删除当前已经上链的全部交易
-			}
+	newBlock := bc.MineBlock(txs, miningAddress)
+	UTXOSet := core.UTXOSet{Blockchain: bc}
+	UTXOSet.Update(newBlock)
 
-			for _, node := range knownNodes {
-				if node != nodeAddress {
-					//将新的模块哈希通过inv命令发送给除本地节点之外的其他节点，通知对方进行本地区块链更新
-					sendInv(node, "block", [][]byte{newBlock.Hash.Bytes()})
-				}
-			}
+	fmt.Println("新区块已挖出!")
 
-			if len(mempool) > 0 {
-				goto MineTransactions
-			}
+	mempool.RemoveBlock(newBlock) //从交易池中删除当前已经上链的全部交易
+
+	for _, node := range knownNodes {
+		if node != nodeAddress {
+			//将新的模块哈希通过inv命令发送给除本地节点之外的其他节点，通知对方进行本地区块链更新
+			sendInv(node, "block", [][]byte{newBlock.Hash.Bytes()})
 		}
 	}
+
+	if mempool.Count() > 0 {
+		goto MineTransactions
+	}
 }
 
 // handleVersion 处理版本请求回复消息
@@ -482,7 +494,9 @@ func handleConnection(conn net.Conn, bc *core.Blockchain) {
 
 // StartServer 启动一个节点
 //minerAddress若是空值，为非挖矿节点，不为空值，为挖矿节点
-func StartServer(nodeID string, minerAddress string) {
+//signerEndpoint不为空时，HTTP转账接口由该外部签名者签名，而不使用节点钱包中的私钥
+//walletNames为启动时加载的钱包，之后可以通过管理接口加载、卸载
+func StartServer(nodeID string, minerAddress string, admin bool, signerEndpoint string, walletNames []string) {
 	nodeAddress = fmt.Sprintf("localhost:%s", nodeID)
 	//如果当前是挖矿节点，那么miningAddress的长度不会为空，否则miningAddress是空值
 	miningAddress = minerAddress
@@ -497,7 +511,31 @@ func StartServer(nodeID string, minerAddress string) {
 	if nodeAddress != knownNodes[0] { //如果不是中心节点，发送Version命令，从网络（中心节点）请求缺失区块
 		sendVersion(knownNodes[0], bc) //服务器启动后，非中心节点要干的第一件事，就是下载缺失区块
 	}
-	mux := bc.Rount()
+	//通过HTTP接口提交的交易与网络中收到的交易一样进入交易池；非中心节点同时转发给中心节点
+	bc.SubmitTx = func(tx *core.Transaction) error {
+		err := acceptTransaction(*tx, nodeAddress, bc)
+		if err != nil {
+			return err
+		}
+		if nodeAddress != knownNodes[0] && len(miningAddress) == 0 {
+			sendTx(knownNodes[0], tx)
+		}
+		return nil
+	}
+	if signerEndpoint != "" {
+		signer, err := core.DialSigner(signerEndpoint)
+		if err != nil {
+			log.Panic(err)
+		}
+		bc.Signer = signer
+	}
+	bc.Wallets = core.NewLoadedWallets()
+	for _, name := range walletNames {
+		if err := bc.Wallets.Load(name); err != nil {
+			log.Panic(err)
+		}
+	}
+	mux := bc.Rount(admin)
 	// 创建 HTTP 服务器
 	server := &http.Server{
 		Handler: mux,
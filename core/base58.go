@@ -23,7 +23,7 @@ func Base58Encode(input []byte) []byte {
 	}
 
 	ReverseBytes(result)
-	for b := range input {
+	for _, b := range input { //每个前导0字节编码为一个'1'
 		if b == 0x00 {
 			result = append([]byte{b58Alphabet[0]}, result...)
 		} else {
@@ -35,14 +35,16 @@ func Base58Encode(input []byte) []byte {
 }
 
 // Base58Decode 反编码Base58-encoded数据
+//每个前导'1'还原为一个0字节，公钥哈希以0字节开头的地址也能还原出完整的公钥哈希
 func Base58Decode(input []byte) []byte {
 	result := big.NewInt(0)
 	zeroBytes := 0
 
-	for b := range input {
-		if b == 0x00 {
-			zeroBytes++
+	for _, b := range input { //每个前导'1'对应一个0字节
+		if b != b58Alphabet[0] {
+			break
 		}
+		zeroBytes++
 	}
 
 	payload := input[zeroBytes:]
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+	"zzschain/wallet"
+)
+
+//TestBase58LeadingZeros 每个前导0字节（包括为0的版本号）编码为一个'1'，解码后还原出相同个数的0字节，与钱包的编码一致
+func TestBase58LeadingZeros(t *testing.T) {
+	inputs := [][]byte{
+		{0x00, 0x01, 0x02},
+		{0x00, 0x00, 0x00, 0xff},
+		{0x3f, 0x00, 0x01},
+		{0x00},
+	}
+	for _, input := range inputs {
+		encoded := Base58Encode(input)
+		if !bytes.Equal(encoded, wallet.Base58Encode(input)) {
+			t.Fatalf("%x编码为%s，钱包编码为%s", input, encoded, wallet.Base58Encode(input))
+		}
+		if decoded := Base58Decode(encoded); !bytes.Equal(decoded, input) {
+			t.Fatalf("%x编码为%s后解码为%x", input, encoded, decoded)
+		}
+	}
+}
+
+//TestLockAddressWithZeroHash 公钥哈希以0字节开头的地址锁定到完整的20字节公钥哈希
+func TestLockAddressWithZeroHash(t *testing.T) {
+	pubKeyHash := append([]byte{0x00, 0x00}, bytes.Repeat([]byte{0x42}, 18)...)
+	address := wallet.PubKeyHashToAddress(pubKeyHash)
+	if !wallet.ValidateAddress(string(address)) {
+		t.Fatalf("地址%s非法", address)
+	}
+
+	out := &TxOutput{Value: 1}
+	out.Lock(address)
+	if !bytes.Equal(out.PubKeyHash, pubKeyHash) {
+		t.Fatalf("地址%s锁定到%x，应为%x", address, out.PubKeyHash, pubKeyHash)
+	}
+}
@@ -12,7 +12,6 @@ import (
 	"net/http"
 	"os"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 	"zzschain/wallet"
@@ -29,16 +28,24 @@ var mutex = &sync.Mutex{}
 type Blockchain struct {
 	Tip      []byte   //区块链最后一块的哈希值
 	Database *bolt.DB //数据库
+
+	SubmitTx func(tx *Transaction) error //将交易提交到节点的交易池并广播，由启动节点时设置
+	Signer   Signer                      //外部签名者，由启动节点时设置；为nil时HTTP接口使用节点钱包中的私钥签名
+	Wallets  *LoadedWallets              //节点已加载的钱包，由启动节点时设置；钱包相关的接口由查询参数wallet指定钱包
+
+	Spec *ChainSpec //链参数，决定新共识规则的激活区块号
 }
 
 //MineBlock 挖出普通区块并将新区块加入到区块链中
 //此方法通过区块链的指针调用，将修改区块链bc的内容
 func (bc *Blockchain) MineBlock(transactions []*Transaction, coinbase string) *Block {
-	//在将交易放入块之前进行签名验证
+	//在将交易放入块之前进行签名验证，子交易可以花费同一区块中排在它前面的父交易的输出
+	inBlock := make(map[string]Transaction)
 	for _, tx := range transactions {
-		if bc.VerifyTransaction(tx) != true {
+		if bc.VerifyTransactionWithParents(tx, inBlock) != true {
 			log.Panic("ERROR: 非法交易")
 		}
+		inBlock[hex.EncodeToString(tx.ID)] = *tx
 	}
 
 	coinba := []byte(coinbase)
@@ -96,7 +103,7 @@ func CreatBlockchain(address []byte, nodeId string) *Blockchain {
 	})
 	Handle(err)
 
-	BC := Blockchain{tip.Bytes(), db} //构建区块链实例
+	BC := Blockchain{Tip: tip.Bytes(), Database: db, Spec: LoadChainSpec()} //构建区块链实例
 
 	return &BC //返回区块链实例的指针
 }
@@ -191,20 +198,24 @@ func (bc *Blockchain) FindUTXO() (map[string]TxOutputs, map[string]common.Hash)
 
 		Outputs:
 			for outIdx, out := range tx.Vout {
+				//数据输出不可花费，不加入UTXO
+				if out.IsDataCarrier() {
+					continue
+				}
+
 				// tx的输出是否已经花费
 				if spentTXOs[txID] != nil {
 					for _, spentOutIdx := range spentTXOs[txID] {
 						if spentOutIdx == outIdx {
-							outs := make([]TxOutput, 0)  //创建切片，长度为0，out实际上是一个空数组
-							UTXO[txID] = TxOutputs{outs} //UTXO[txID]为空
-							break Outputs                //返回到Outputs标识处，即退出循环for outIdx, out := range tx.Vout
+							continue Outputs //该输出已花费，检查下一个输出
 						}
 					}
 				}
 
-				//spentTXOs[txID] == nil
+				//记录未花费输出及其在交易中的索引
 				outs := UTXO[txID]
 				outs.Outputs = append(outs.Outputs, out)
+				outs.Index = append(outs.Index, outIdx)
 				UTXO[txID] = outs
 			}
 
@@ -252,7 +263,8 @@ func NewBlockchain(nodeID string) *Blockchain {
 		log.Panic(err)
 	}
 
-	bc := Blockchain{Tip: tip, Database: db}
+	bc := Blockchain{Tip: tip, Database: db, Spec: LoadChainSpec()}
+	bc.activateSigHash() //升级之前的链没有约定哈希签名激活区块号，必须强制激活
 
 	return &bc
 }
@@ -431,6 +443,9 @@ func (bc *Blockchain) FindTransactionForUTXO(txID []byte) (Transaction, error) {
 		blockb := tx.Bucket([]byte(utxoBlockBucket)) //UTXOBlock
 
 		blockhash := blockb.Get(txID) //UTXOBlock
+		if blockhash == nil {         //交易尚未上链
+			return nil
+		}
 		blockData := b.Get(blockhash)
 		block := *DeserializeBlock(blockData)
 		for _, tx := range block.Transactions {
@@ -443,7 +458,7 @@ func (bc *Blockchain) FindTransactionForUTXO(txID []byte) (Transaction, error) {
 	if err != nil {
 		log.Panic(err)
 	}
-	if &tnx != nil {
+	if tnx.ID != nil {
 		return tnx, nil
 	}
 
@@ -471,6 +486,62 @@ func (bc *Blockchain) FindTransaction(txID []byte) (Transaction, error) {
 	return Transaction{}, errors.New("未找到交易")
 }
 
+// FindSpendingTransaction 迭代整个区块链，查找花费了交易txID第vout个输出的交易
+func (bc *Blockchain) FindSpendingTransaction(txID []byte, vout int) (Transaction, error) {
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions {
+			if tx.IsCoinbase() {
+				continue
+			}
+			for _, vin := range tx.Vin {
+				if bytes.Equal(vin.Txid, txID) && vin.Vout == vout {
+					return *tx, nil
+				}
+			}
+		}
+
+		if IsInitBlock(block.PrevHash.Bytes()) {
+			break
+		}
+	}
+
+	return Transaction{}, errors.New("未找到花费该输出的交易")
+}
+
+//UsedPubKeyHashes 遍历一次区块链，返回在交易输出（包括HTLC的接收方与退款方）中出现过的全部公钥哈希（hex编码）
+//用于恢复分层确定性钱包时判断派生出的地址是否用过
+func (bc *Blockchain) UsedPubKeyHashes() map[string]bool {
+	used := make(map[string]bool)
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions {
+			for _, out := range tx.Vout {
+				if out.IsHTLC() {
+					used[hex.EncodeToString(out.HTLC.RecipientPubKeyHash)] = true
+					used[hex.EncodeToString(out.HTLC.RefundPubKeyHash)] = true
+					continue
+				}
+				if len(out.PubKeyHash) > 0 {
+					used[hex.EncodeToString(out.PubKeyHash)] = true
+				}
+			}
+		}
+
+		if IsInitBlock(block.PrevHash.Bytes()) {
+			break
+		}
+	}
+
+	return used
+}
+
 // SignTransaction 对一个交易的所有输入引用的输出的交易进行签名
 //注意，这里签名的不是参数tx（当前交易），而是tx输入所引用的输出的交易
 func (bc *Blockchain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey) {
@@ -489,46 +560,141 @@ func (bc *Blockchain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey)
 
 // VerifyTransaction 验证一个交易的所有输入的签名
 func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
+	return bc.VerifyTransactionWithParents(tx, nil)
+}
+
+// VerifyTransactionWithParents 验证一个交易的所有输入的签名
+//输入引用的交易可以是尚未上链的parents（如交易池中的父交易，或同一区块中排在前面的交易）
+func (bc *Blockchain) VerifyTransactionWithParents(tx *Transaction, parents map[string]Transaction) bool {
+	if err := bc.CheckTransaction(tx, parents); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	return true
+}
+
+// CheckTransaction 校验交易，与VerifyTransactionWithParents相同，但返回不合法的具体原因
+func (bc *Blockchain) CheckTransaction(tx *Transaction, parents map[string]Transaction) error {
 	if tx.IsCoinbase() {
-		return true
+		return nil
 	}
 
-	prevTXs := make(map[string]Transaction)
+	if err := tx.ValidateOutputs(); err != nil {
+		return err
+	}
+
+	//锁定区块号未到的交易不能被打包进下一个区块
+	next := new(big.Int).Add(bc.GetBestNumber(), Big1)
+	if !tx.IsFinal(next) {
+		return fmt.Errorf("ERROR: 交易锁定至区块号%d，尚不能上链", tx.LockTime)
+	}
+	for inID, vin := range tx.Vin {
+		if vin.IsRecoverable() && !bc.Spec.Secp256k1Active(next) {
+			return fmt.Errorf("ERROR: 第%d个输入为secp256k1签名，链参数中尚未激活", inID)
+		}
+	}
+	for i, out := range tx.Vout {
+		if out.IsStealth() && !bc.Spec.StealthActive(next) {
+			return fmt.Errorf("ERROR: 第%d个输出付给隐身地址，链参数中尚未激活", i)
+		}
+	}
 
+	var prevOuts []TxOutput
 	for _, vin := range tx.Vin {
-		prevTX, err := bc.FindTransactionForUTXO(vin.Txid)
-		if err != nil {
-			log.Panic(err)
+		prevTX, ok := parents[hex.EncodeToString(vin.Txid)]
+		if !ok {
+			var err error
+			prevTX, err = bc.FindTransactionForUTXO(vin.Txid)
+			if err != nil {
+				return fmt.Errorf("ERROR: 输入引用的交易%x不存在", vin.Txid)
+			}
+		}
+		if vin.Vout < 0 || vin.Vout >= len(prevTX.Vout) {
+			return fmt.Errorf("ERROR: 输入引用的输出%x:%d不存在", vin.Txid, vin.Vout)
+		}
+		prevOuts = append(prevOuts, prevTX.Vout[vin.Vout])
+	}
+
+	unhashed := !bc.Spec.SigHashActive(next)
+	for inID := range tx.Vin {
+		if !tx.verifyInput(inID, prevOuts[inID], unhashed) {
+			return fmt.Errorf("ERROR: 第%d个输入的签名校验失败", inID)
 		}
-		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
 	}
 
-	return tx.Verify(prevTXs)
+	return nil
 }
 
 //Rount() 创建多复用路由
-func (bc *Blockchain) Rount() *http.ServeMux {
+//admin为false时，不注册生成、返回私钥或使用节点钱包私钥签名的接口，客户端通过交易模板自行签名
+func (bc *Blockchain) Rount(admin bool) *http.ServeMux {
 	// 创建路由器
 	mux := http.NewServeMux()
 
-	//通过区块号查询区块
-	mux.HandleFunc("/", Hello)
 	//通过区块号查询区块
 	mux.HandleFunc("/blockbynumber", bc.blockbynumber)
 	//通过区块哈希查询区块
 	mux.HandleFunc("/blockbyhash", bc.blockbyhash)
-	//转账
-	mux.HandleFunc("/sendtransation", bc.send)
+	//创建未签名的交易模板，附带被花费的输出与需要签名的数据
+	mux.HandleFunc("/createtransaction", bc.createtransaction)
+	//提交客户端签名后的交易模板
+	mux.HandleFunc("/submittransaction", bc.submittransaction)
+	//由明确的输入输出创建未签名的原始交易
+	mux.HandleFunc("/createrawtransaction", bc.createrawtransaction)
+	//将原始交易解码为人可读的JSON
+	mux.HandleFunc("/decoderawtransaction", bc.decoderawtransaction)
+	//提交签名完成的原始交易到交易池
+	mux.HandleFunc("/sendrawtransaction", bc.sendrawtransaction)
 	//交易查询
 	mux.HandleFunc("/transationtohash", bc.gettransation)
 	//查询余额
 	mux.HandleFunc("/getbalance", bc.getbalance)
+	//显示历史交易
+	mux.HandleFunc("/gethistory", bc.gethistory)
+	//按前缀查找上链的数据
+	mux.HandleFunc("/searchdata", bc.searchdata)
+	//验证消息签名
+	mux.HandleFunc("/verifymessage", bc.verifymessage)
+	//返回消息的签名数据与摘要，由客户端签名
+	mux.HandleFunc("/messagedigest", bc.messagedigest)
+
+	if !admin {
+		return mux
+	}
+
+	//以下接口会生成、返回私钥，或者使用节点钱包中的私钥签名，只在管理模式下开放
+	//钱包相关的接口必须由查询参数wallet指定使用的钱包
+	//创建新钱包
+	mux.HandleFunc("/", bc.Hello)
+	//在钱包数据目录中创建命名钱包并加载
+	mux.HandleFunc("/createwallet", bc.createwallet)
+	//加载钱包数据目录中的钱包
+	mux.HandleFunc("/loadwallet", bc.loadwallet)
+	//卸载钱包
+	mux.HandleFunc("/unloadwallet", bc.unloadwallet)
+	//列出钱包数据目录中的钱包与节点已加载的钱包
+	mux.HandleFunc("/listwallets", bc.listwallets)
+	//转账
+	mux.HandleFunc("/sendtransation", bc.send)
+	//批量转账
+	mux.HandleFunc("/sendmany", bc.sendmany)
+	//使用给出的私钥签名原始交易
+	mux.HandleFunc("/signrawtransaction", bc.signrawtransaction)
 	//添加新钱包
 	mux.HandleFunc("/addwallet", bc.addwallet)
 	//加载钱包
 	mux.HandleFunc("/getwallet", bc.getwallet)
-	//显示历史交易
-	mux.HandleFunc("/gethistory", bc.gethistory)
+	//用密码解锁加密的钱包，超时后自动锁定
+	mux.HandleFunc("/walletunlock", bc.walletunlock)
+	//立即锁定钱包
+	mux.HandleFunc("/walletlock", bc.walletlock)
+	//导入带校验码的私钥，并扫描区块链
+	mux.HandleFunc("/importprivkey", bc.importprivkey)
+	//导出带校验码的私钥
+	mux.HandleFunc("/dumpprivkey", bc.dumpprivkey)
+	//用钱包中的私钥签名消息
+	mux.HandleFunc("/signmessage", bc.signmessage)
 	return mux
 }
 
@@ -603,6 +769,10 @@ type Send struct {
 	Sender string `json:"sender_blockchain_address"`
 	Recip  string `json:"recipient_blockchain_address"`
 	Value  string `json:"value"`
+	Data   string `json:"data"`
+
+	CoinSelection string   `json:"coin_selection"` //选币策略，为空时使用默认策略
+	Inputs        []string `json:"inputs"`         //手动指定的输入，格式为txid:vout
 }
 
 type Resp struct {
@@ -620,16 +790,6 @@ func (bc *Blockchain) send(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
 		return
 	}
-	// 获取请求的完整主机地址
-	host := r.Host
-	nodeId := ""
-	// 使用 ":" 进行分割，提取出端口信息
-	parts := strings.Split(host, ":")
-	if len(parts) > 1 {
-		nodeId = parts[1]
-	} else {
-		fmt.Println("无法获取请求的端口")
-	}
 	var tra Send
 	err := json.NewDecoder(r.Body).Decode(&tra)
 	if err != nil {
@@ -639,17 +799,49 @@ func (bc *Blockchain) send(w http.ResponseWriter, r *http.Request) {
 	if !wallet.ValidateAddress(tra.Sender) {
 		log.Panic("ERROR: 发送地址非法")
 	}
-	if !wallet.ValidateAddress(tra.Recip) {
-		log.Panic("ERROR: 接收地址非法")
-	}
 	UTXOSet := UTXOSet{bc}
-	wallets, err := wallet.NewWallets(nodeId)
+	wallets, walletName, ok := bc.requestWallet(w, r)
+	if !ok {
+		return
+	}
+	tra.Recip, err = wallets.ResolveAddress(tra.Recip) //收款人可以是通讯录中的联系人名称
 	if err != nil {
-		log.Panic(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var opts SendOptions
+	opts.Selector, err = NewCoinSelector(tra.CoinSelection)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, input := range tra.Inputs {
+		op, err := ParseOutPoint(input)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts.Inputs = append(opts.Inputs, op)
+	}
+	if tra.Data != "" {
+		opts.Data, err = ParseData(tra.Data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	sender, err := opts.UseSender(wallets, tra.Sender, bc.Signer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	wallet := wallets.GetWallet(tra.Sender)
 	value, _ := strconv.Atoi(tra.Value)
-	tx := NewUTXOTransaction(&wallet, []byte(tra.Recip), value, &UTXOSet)
+	tx, err := NewUTXOTransactionWithOptions(sender, []byte(tra.Recip), value, &UTXOSet, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	wallets.SaveToFile(walletName) //保存新生成的找零地址
 	//当前是挖矿节点，有奖励
 	cbTx := NewCoinbaseTX([]byte(tra.Sender), "")
 	txs := []*Transaction{cbTx, tx}
@@ -670,6 +862,316 @@ func (bc *Blockchain) send(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+//SendMany 批量转账请求
+type SendMany struct {
+	Sender        string    `json:"sender_blockchain_address"`
+	Payments      []Payment `json:"payments"`
+	Fee           int       `json:"fee"`
+	CoinSelection string    `json:"coin_selection"`
+	DryRun        bool      `json:"dry_run"` //只校验并返回总额与手续费，不上链
+}
+
+//SendManyResp 批量转账结果
+type SendManyResp struct {
+	TxID       string `json:"txid"`
+	Recipients int    `json:"recipients"`
+	Total      int    `json:"total"`
+	Fee        int    `json:"fee"`
+	Committed  bool   `json:"committed"`
+}
+
+func (bc *Blockchain) sendmany(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+		return
+	}
+	var req SendMany
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !wallet.ValidateAddress(req.Sender) {
+		http.Error(w, "ERROR: 发送地址非法", http.StatusBadRequest)
+		return
+	}
+	wallets, walletName, ok := bc.requestWallet(w, r)
+	if !ok {
+		return
+	}
+	ResolvePayments(wallets, req.Payments) //收款人可以是通讯录中的联系人名称
+	total, err := ValidatePayments(req.Payments)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts := SendOptions{Fee: req.Fee}
+	opts.Selector, err = NewCoinSelector(req.CoinSelection)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	UTXOSet := UTXOSet{bc}
+	sender, err := opts.UseSender(wallets, req.Sender, bc.Signer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tx, err := NewBatchTransaction(sender, req.Payments, &UTXOSet, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result := SendManyResp{TxID: Encode(tx.ID), Recipients: len(req.Payments), Total: total, Fee: req.Fee}
+	if !req.DryRun {
+		wallets.SaveToFile(walletName) //保存新生成的找零地址
+		//当前是挖矿节点，有奖励
+		cbTx := NewCoinbaseTXWithFees([]byte(req.Sender), "", req.Fee)
+		newBlock := bc.MineBlock([]*Transaction{cbTx, tx}, req.Sender)
+		UTXOSet.Update(newBlock)
+		result.Committed = true
+	}
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(jsonData)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+//CreateRaw 创建原始交易请求
+type CreateRaw struct {
+	Inputs      []string  `json:"inputs"` //txid:vout
+	Outputs     []Payment `json:"outputs"`
+	Data        string    `json:"data"`
+	LockTime    int64     `json:"locktime"`
+	Replaceable bool      `json:"replaceable"`
+}
+
+//RawTx 原始交易请求与结果
+type RawTx struct {
+	Hex        string `json:"hex"`
+	TxID       string `json:"txid,omitempty"`
+	PrivateKey string `json:"privatekey,omitempty"` //仅签名请求使用
+	Signed     int    `json:"signed,omitempty"`     //本次签名的输入个数
+	Complete   bool   `json:"complete,omitempty"`   //全部输入均已签名
+}
+
+//decodeJSONRequest 检查请求方法与Content-Type，并解析JSON请求体到v，失败时写入错误并返回false
+func decodeJSONRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+		return false
+	}
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+//writeJSON 以JSON格式返回结果
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(jsonData)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (bc *Blockchain) createrawtransaction(w http.ResponseWriter, r *http.Request) {
+	var req CreateRaw
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	var inputs []OutPoint
+	for _, input := range req.Inputs {
+		op, err := ParseOutPoint(input)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		inputs = append(inputs, op)
+	}
+	var data []byte
+	if req.Data != "" {
+		var err error
+		data, err = ParseData(req.Data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	tx, err := CreateRawTransaction(inputs, req.Outputs, data, req.LockTime, req.Replaceable)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, RawTx{Hex: EncodeRawTransaction(tx), TxID: Encode(tx.ID)})
+}
+
+func (bc *Blockchain) decoderawtransaction(w http.ResponseWriter, r *http.Request) {
+	var req RawTx
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	tx, err := DecodeRawTransaction(req.Hex)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, NewRawTransaction(tx))
+}
+
+func (bc *Blockchain) signrawtransaction(w http.ResponseWriter, r *http.Request) {
+	var req RawTx
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	tx, err := DecodeRawTransaction(req.Hex)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	signer, err := wallet.NewWalletFromPrivateKey(req.PrivateKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	UTXOSet := UTXOSet{bc}
+	signed, err := SignRawTransaction(tx, signer, &UTXOSet)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, RawTx{Hex: EncodeRawTransaction(tx), TxID: Encode(tx.ID), Signed: signed, Complete: tx.IsSigned()})
+}
+
+func (bc *Blockchain) sendrawtransaction(w http.ResponseWriter, r *http.Request) {
+	var req RawTx
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	tx, err := DecodeRawTransaction(req.Hex)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if bc.SubmitTx == nil {
+		http.Error(w, "ERROR: 节点未启动，无法提交交易", http.StatusServiceUnavailable)
+		return
+	}
+	//交易池的校验错误原样返回
+	if err := bc.SubmitTx(tx); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, RawTx{Hex: req.Hex, TxID: Encode(tx.ID)})
+}
+
+//TemplateRequest 创建交易模板请求
+type TemplateRequest struct {
+	Sender        string    `json:"sender_blockchain_address"`
+	Payments      []Payment `json:"payments"`
+	Fee           int       `json:"fee"`
+	CoinSelection string    `json:"coin_selection"`
+	Data          string    `json:"data"`
+	Replaceable   bool      `json:"replaceable"`
+}
+
+//SignedTemplate 客户端签名后的交易模板
+type SignedTemplate struct {
+	Hex        string           `json:"hex"`
+	Signatures []InputSignature `json:"signatures"`
+}
+
+func (bc *Blockchain) createtransaction(w http.ResponseWriter, r *http.Request) {
+	var req TemplateRequest
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	if !wallet.ValidateAddress(req.Sender) {
+		http.Error(w, "ERROR: 发送地址非法", http.StatusBadRequest)
+		return
+	}
+	opts := SendOptions{Fee: req.Fee, Replaceable: req.Replaceable}
+	var err error
+	opts.Selector, err = NewCoinSelector(req.CoinSelection)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Data != "" {
+		opts.Data, err = ParseData(req.Data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	UTXOSet := UTXOSet{bc}
+	psbt, err := NewPSBT([]byte(req.Sender), req.Payments, &UTXOSet, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, NewTxTemplate(psbt))
+}
+
+func (bc *Blockchain) submittransaction(w http.ResponseWriter, r *http.Request) {
+	var req SignedTemplate
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	tx, err := DecodeRawTransaction(req.Hex)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	psbt, err := newPSBTFromTx(tx, &UTXOSet{bc})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := ApplySignatures(tx, psbt.PrevOuts, req.Signatures); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if bc.SubmitTx == nil {
+		http.Error(w, "ERROR: 节点未启动，无法提交交易", http.StatusServiceUnavailable)
+		return
+	}
+	if err := bc.SubmitTx(tx); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, RawTx{Hex: EncodeRawTransaction(tx), TxID: Encode(tx.ID)})
+}
+
 type Tra struct {
 	Trans string `json:"hash"`
 }
@@ -735,15 +1237,17 @@ func (bc *Blockchain) getbalance(w http.ResponseWriter, r *http.Request) {
 	if !wallet.ValidateAddress(addr.Blockchainaddress) {
 		log.Panic("ERROR: 地址非法")
 	}
+	//地址在节点钱包中时，同一账户下找零地址的余额一并计入
+	wallets, ok := bc.accountWallet(w, r)
+	if !ok {
+		return
+	}
 	balance := 0
-	pubKeyHash := wallet.Base58Decode([]byte(addr.Blockchainaddress))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-
 	UTXOSet := UTXOSet{bc}
-	UTXOs := UTXOSet.FindUTXO(pubKeyHash)
-
-	for _, output := range UTXOs {
-		balance += output.Value
+	for _, pubKeyHash := range AccountPubKeyHashes(wallets, addr.Blockchainaddress) {
+		for _, output := range UTXOSet.FindUTXO(pubKeyHash) {
+			balance += output.Value
+		}
 	}
 
 	fmt.Printf("'%s'的账号余额是: %d\n", addr.Blockchainaddress, balance)
@@ -759,19 +1263,17 @@ func (bc *Blockchain) getbalance(w http.ResponseWriter, r *http.Request) {
 	}
 }
 func (bc *Blockchain) addwallet(w http.ResponseWriter, r *http.Request) {
-	// 获取请求的完整主机地址
-	host := r.Host
-	nodeId := ""
-	// 使用 ":" 进行分割，提取出端口信息
-	parts := strings.Split(host, ":")
-	if len(parts) > 1 {
-		nodeId = parts[1]
-	} else {
-		fmt.Println("无法获取请求的端口")
-	}
-	wallets, _ := wallet.NewWallets(nodeId) //从钱包文件读取所有的钱包
-	address := wallets.CreateWallet()       //创建新钱包
-	wallets.SaveToFile(nodeId)              //创建完成后，保存到本地，不参与网络共享，必须自己保管好！
+	wallets, walletName, ok := bc.requestWallet(w, r) //从钱包文件读取所有的钱包
+	if !ok {
+		return
+	}
+	//加密的钱包需要先解锁才能加密保存新私钥
+	if wallets.IsLocked() {
+		http.Error(w, wallet.ErrWalletLocked.Error(), http.StatusForbidden)
+		return
+	}
+	address := wallets.CreateWallet() //创建新钱包
+	wallets.SaveToFile(walletName)    //创建完成后，保存到本地，不参与网络共享，必须自己保管好！
 
 	private := wallets.Wallets[address].PrivateKey.D.Bytes()
 	privateStr := hex.EncodeToString(private)
@@ -801,16 +1303,6 @@ func (bc *Blockchain) getwallet(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	// 获取请求的完整主机地址
-	host := r.Host
-	nodeId := ""
-	// 使用 ":" 进行分割，提取出端口信息
-	parts := strings.Split(host, ":")
-	if len(parts) > 1 {
-		nodeId = parts[1]
-	} else {
-		fmt.Println("无法获取请求的端口")
-	}
 	contentType := r.Header.Get("Content-Type")
 	if contentType != "application/json" {
 		http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
@@ -822,11 +1314,11 @@ func (bc *Blockchain) getwallet(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	ws, err := wallet.NewWallets(nodeId)
-	if err != nil {
-		panic(err)
+	ws, walletName, ok := bc.requestWallet(w, r)
+	if !ok {
+		return
 	}
-	pub, addr := ws.LoadPrivate(priv.Privatekey, nodeId)
+	pub, addr := ws.LoadPrivate(priv.Privatekey, walletName)
 	result := Wal{
 		priv.Privatekey,
 		addr,
@@ -846,12 +1338,33 @@ type Tras struct {
 	Transactions []Transaction `json:"Transactions"`
 }
 
+//AccountHistoryResp 账户交易记录
+type AccountHistoryResp struct {
+	Address string         `json:"address"`
+	History []HistoryEntry `json:"history"`
+}
+
 //获取历史交易
+//带address参数时只返回该地址所属账户（包括找零地址）的交易记录，交易对方标注钱包中的标签与联系人名称
 func (bc *Blockchain) gethistory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if address := r.URL.Query().Get("address"); address != "" {
+		if !wallet.ValidateAddress(address) {
+			http.Error(w, "ERROR: 地址非法", http.StatusBadRequest)
+			return
+		}
+		wallets, ok := bc.accountWallet(w, r)
+		if !ok {
+			return
+		}
+		history := bc.AccountHistory(AccountPubKeyHashes(wallets, address))
+		AnnotateHistory(wallets, history)
+		writeJSON(w, AccountHistoryResp{Address: address, History: history})
+		return
+	}
 	result := Tras{
 		Transactions: bc.GetTransationHashes(),
 	}
@@ -864,16 +1377,68 @@ func (bc *Blockchain) gethistory(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+type SearchData struct {
+	Prefix string `json:"prefix"`
+}
+
+type Anchors struct {
+	Anchors []DataAnchor `json:"anchors"`
+}
+
+//按前缀查找上链的数据，前缀带0x时按hex解析
+func (bc *Blockchain) searchdata(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+		return
+	}
+	var search SearchData
+	err := json.NewDecoder(r.Body).Decode(&search)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	prefix, err := ParseData(search.Prefix)
+	if err != nil || len(prefix) == 0 {
+		http.Error(w, "ERROR: 前缀非法", http.StatusBadRequest)
+		return
+	}
+	UTXOSet := UTXOSet{bc}
+	result := Anchors{
+		Anchors: UTXOSet.SearchData(prefix),
+	}
+	jsonData, err := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json")
+
+	_, err = w.Write(jsonData)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
 type Wal struct {
 	Privatekey string
 	Address    string
 	Publickey  string
 }
 
-func Hello(w http.ResponseWriter, r *http.Request) {
-	wallets, _ := wallet.NewWallets(r.URL.Port()) //从钱包文件读取所有的钱包
-	address := wallets.CreateWallet()             //创建新钱包
-	wallets.SaveToFile(r.URL.Port())              //创建完成后，保存到本地，不参与网络共享，必须自己保管好！
+//Hello 在请求指定的钱包中创建新地址，返回私钥、地址与公钥
+func (bc *Blockchain) Hello(w http.ResponseWriter, r *http.Request) {
+	wallets, walletName, ok := bc.requestWallet(w, r) //从钱包文件读取所有的钱包
+	if !ok {
+		return
+	}
+	//加密的钱包需要先解锁才能加密保存新私钥
+	if wallets.IsLocked() {
+		http.Error(w, wallet.ErrWalletLocked.Error(), http.StatusForbidden)
+		return
+	}
+	address := wallets.CreateWallet() //创建新钱包
+	wallets.SaveToFile(walletName)    //创建完成后，保存到本地，不参与网络共享，必须自己保管好！
 
 	private := wallets.Wallets[address].PrivateKey.D.Bytes()
 	privateStr := hex.EncodeToString(private)
@@ -893,3 +1458,273 @@ func Hello(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 	}
 }
+
+//WalletUnlock 解锁钱包请求
+type WalletUnlock struct {
+	Passphrase string `json:"passphrase"`
+	Timeout    int    `json:"timeout"` //解锁时长，单位秒
+}
+
+//requestWallet 读取请求的查询参数wallet指定的钱包，必须指定且是节点已加载的钱包
+//出错时写入错误响应并返回false
+func (bc *Blockchain) requestWallet(w http.ResponseWriter, r *http.Request) (*wallet.Wallets, string, bool) {
+	name, err := bc.Wallets.Resolve(r.URL.Query().Get("wallet"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, "", false
+	}
+	wallets, err := wallet.NewWallets(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, "", false
+	}
+
+	return wallets, name, true
+}
+
+//accountWallet 查询余额与交易记录时，读取地址所属账户的钱包：请求指定了钱包时读取该钱包，
+//否则返回空钱包，只按地址本身查询
+func (bc *Blockchain) accountWallet(w http.ResponseWriter, r *http.Request) (*wallet.Wallets, bool) {
+	if r.URL.Query().Get("wallet") == "" {
+		return &wallet.Wallets{}, true
+	}
+	wallets, _, ok := bc.requestWallet(w, r)
+
+	return wallets, ok
+}
+
+//walletunlock 解锁节点的加密钱包，主密钥只保存在节点进程的内存中，timeout秒后自动锁定
+func (bc *Blockchain) walletunlock(w http.ResponseWriter, r *http.Request) {
+	var req WalletUnlock
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	wallets, _, ok := bc.requestWallet(w, r)
+	if !ok {
+		return
+	}
+	err := wallets.Unlock(req.Passphrase, time.Duration(req.Timeout)*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, Resp{Message: fmt.Sprintf("钱包已解锁，%d秒后自动锁定", req.Timeout)})
+}
+
+//walletlock 立即锁定节点的加密钱包
+func (bc *Blockchain) walletlock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	wallets, _, ok := bc.requestWallet(w, r)
+	if !ok {
+		return
+	}
+	if err := wallets.Lock(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, Resp{Message: "钱包已锁定"})
+}
+
+//WalletName 创建、加载或卸载钱包请求
+type WalletName struct {
+	Name string `json:"name"`
+}
+
+//WalletList 钱包数据目录中的全部钱包与节点已加载的钱包
+type WalletList struct {
+	Wallets []string `json:"wallets"`
+	Loaded  []string `json:"loaded"`
+}
+
+//createwallet 在钱包数据目录中创建空的命名钱包，并由节点加载
+func (bc *Blockchain) createwallet(w http.ResponseWriter, r *http.Request) {
+	var req WalletName
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	if _, err := wallet.CreateNamedWallet(req.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := bc.Wallets.Load(req.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, Resp{Message: fmt.Sprintf("钱包%s已创建并加载", req.Name)})
+}
+
+//loadwallet 加载钱包数据目录中的钱包，之后钱包相关的接口可以使用该钱包
+func (bc *Blockchain) loadwallet(w http.ResponseWriter, r *http.Request) {
+	var req WalletName
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	if err := bc.Wallets.Load(req.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, Resp{Message: fmt.Sprintf("钱包%s已加载", req.Name)})
+}
+
+//unloadwallet 卸载钱包，已解锁的加密钱包同时锁定
+func (bc *Blockchain) unloadwallet(w http.ResponseWriter, r *http.Request) {
+	var req WalletName
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	if err := bc.Wallets.Unload(req.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, Resp{Message: fmt.Sprintf("钱包%s已卸载", req.Name)})
+}
+
+//listwallets 列出钱包数据目录中的全部钱包与节点已加载的钱包
+func (bc *Blockchain) listwallets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	names, err := wallet.ListWallets()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, WalletList{Wallets: names, Loaded: bc.Wallets.Names()})
+}
+
+//ImportPrivKey 导入私钥请求，私钥为带版本号与校验码的Base58编码
+type ImportPrivKey struct {
+	PrivKey string `json:"privkey"`
+}
+
+//DumpPrivKey 导出私钥请求
+type DumpPrivKey struct {
+	Address string `json:"address"`
+}
+
+//PrivKeyResp 导出的私钥
+type PrivKeyResp struct {
+	Address string `json:"address"`
+	PrivKey string `json:"privkey"`
+}
+
+//importprivkey 将私钥加入节点的钱包文件，并扫描区块链找出该地址的交易与余额
+func (bc *Blockchain) importprivkey(w http.ResponseWriter, r *http.Request) {
+	var req ImportPrivKey
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	wallets, walletName, ok := bc.requestWallet(w, r)
+	if !ok {
+		return
+	}
+	address, err := wallets.ImportPrivateKey(req.PrivKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	wallets.SaveToFile(walletName)
+
+	writeJSON(w, bc.RescanAddress(address))
+}
+
+//dumpprivkey 返回节点钱包中地址的编码私钥
+func (bc *Blockchain) dumpprivkey(w http.ResponseWriter, r *http.Request) {
+	var req DumpPrivKey
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	wallets, _, ok := bc.requestWallet(w, r)
+	if !ok {
+		return
+	}
+	privKey, err := wallets.DumpPrivateKey(req.Address)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, PrivKeyResp{Address: req.Address, PrivKey: privKey})
+}
+
+//SignMessageReq 签名消息请求
+type SignMessageReq struct {
+	Address string `json:"address"`
+	Message string `json:"message"`
+}
+
+//MessageDigestReq 消息摘要请求
+type MessageDigestReq struct {
+	Message string `json:"message"`
+}
+
+//MessageDigest 消息的签名数据与摘要，与交易模板中的signing_data、signing_message相同：
+//客户端直接对digest签名，或用WebCrypto等签名前总会做哈希的库对signing_message签名
+//签名编码为base64的r||s||SEC1压缩公钥（secp256k1为65字节可恢复签名），可用/verifymessage验证
+type MessageDigest struct {
+	Message        string `json:"message"`
+	Digest         string `json:"digest"`
+	SigningMessage string `json:"signing_message"`
+}
+
+//VerifyMessageReq 验证消息签名请求
+type VerifyMessageReq struct {
+	Address   string `json:"address"`
+	Signature string `json:"signature"`
+	Message   string `json:"message"`
+}
+
+//MessageSignature 消息签名结果或验证结果
+type MessageSignature struct {
+	Address   string `json:"address"`
+	Signature string `json:"signature,omitempty"`
+	Valid     bool   `json:"valid"`
+}
+
+//signmessage 用节点钱包中地址的私钥签名消息，证明对地址的控制权
+func (bc *Blockchain) signmessage(w http.ResponseWriter, r *http.Request) {
+	var req SignMessageReq
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	wallets, _, ok := bc.requestWallet(w, r)
+	if !ok {
+		return
+	}
+	signature, err := wallets.SignMessage(req.Address, req.Message)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, MessageSignature{Address: req.Address, Signature: signature, Valid: true})
+}
+
+//messagedigest 返回域分隔的消息摘要，客户端自行签名，节点不接触私钥
+func (bc *Blockchain) messagedigest(w http.ResponseWriter, r *http.Request) {
+	var req MessageDigestReq
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	writeJSON(w, MessageDigest{
+		Message:        req.Message,
+		Digest:         hex.EncodeToString(wallet.MessageHash(req.Message)),
+		SigningMessage: hex.EncodeToString(wallet.MessageSigningData(req.Message)),
+	})
+}
+
+//verifymessage 验证消息签名，不需要钱包文件
+func (bc *Blockchain) verifymessage(w http.ResponseWriter, r *http.Request) {
+	var req VerifyMessageReq
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+	valid, err := wallet.VerifyMessage(req.Address, req.Signature, req.Message)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, MessageSignature{Address: req.Address, Valid: valid})
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"zzschain/wallet"
+)
+
+//ChainSpecFile 链参数文件，与创世链文件放在一起，同一条链的全部节点必须使用相同的链参数
+const ChainSpecFile = "./tmp/chainspec.json"
+
+//ChainSpec 链参数：新共识规则从哪个区块号开始生效
+//已经运行的链没有链参数文件时新规则都不生效，需要全部节点约定激活区块号后写入链参数文件；哈希签名例外，见activateSigHash
+type ChainSpec struct {
+	//Secp256k1Block 从该区块号开始接受secp256k1私钥签名的输入（可恢复签名，输入不带公钥），-1表示不激活
+	Secp256k1Block int64 `json:"secp256k1Block"`
+
+	//StealthBlock 从该区块号开始接受携带临时公钥的输出（付给隐身地址），-1表示不激活
+	StealthBlock int64 `json:"stealthBlock"`
+
+	//SigHashBlock 从该区块号开始P256签名必须是对签名数据的sha256哈希的签名，不再接受直接对签名数据的旧签名
+	//旧签名只覆盖签名数据的前32字节，可以被搬到同一公钥的任意交易上；激活之前两种签名都接受，新版本只生成哈希签名
+	//读到-1时打开区块链会强制激活，不允许一直接受旧签名
+	SigHashBlock int64 `json:"sigHashBlock"`
+}
+
+//DefaultChainSpec 没有链参数文件时使用的链参数：新规则均不激活
+var DefaultChainSpec = ChainSpec{Secp256k1Block: -1, StealthBlock: -1, SigHashBlock: -1}
+
+//GenesisChainSpec 新建的链使用的链参数：新规则从创世区块开始生效
+var GenesisChainSpec = ChainSpec{Secp256k1Block: 0, StealthBlock: 0, SigHashBlock: 0}
+
+//LoadChainSpec 读取链参数文件，文件不存在时返回DefaultChainSpec
+func LoadChainSpec() *ChainSpec {
+	spec := DefaultChainSpec
+
+	content, err := ioutil.ReadFile(filepath.Join(Root, ChainSpecFile))
+	if os.IsNotExist(err) {
+		return &spec
+	}
+	if err != nil {
+		log.Panic(err)
+	}
+	if err := json.Unmarshal(content, &spec); err != nil {
+		log.Panic(fmt.Errorf("ERROR: 链参数文件格式错误: %v", err))
+	}
+
+	return &spec
+}
+
+//SaveChainSpec 保存链参数文件
+func SaveChainSpec(spec ChainSpec) {
+	content, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(Root, ChainSpecFile), content, 0644)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+//Secp256k1Active 区块号为number的区块是否接受secp256k1私钥签名的输入
+func (s *ChainSpec) Secp256k1Active(number *big.Int) bool {
+	if s == nil {
+		s = &DefaultChainSpec
+	}
+	return s.Secp256k1Block >= 0 && number.Int64() >= s.Secp256k1Block
+}
+
+//StealthActive 区块号为number的区块是否接受付给隐身地址的输出
+func (s *ChainSpec) StealthActive(number *big.Int) bool {
+	if s == nil {
+		s = &DefaultChainSpec
+	}
+	return s.StealthBlock >= 0 && number.Int64() >= s.StealthBlock
+}
+
+//SigHashActive 区块号为number的区块是否只接受对签名数据的sha256哈希的P256签名
+func (s *ChainSpec) SigHashActive(number *big.Int) bool {
+	if s == nil {
+		s = &DefaultChainSpec
+	}
+	return s.SigHashBlock >= 0 && number.Int64() >= s.SigHashBlock
+}
+
+//activateSigHash 链参数没有哈希签名激活区块号时（升级之前的链），从下一个区块开始强制激活并写入链参数文件
+//旧签名对P256而言只签了固定的"--- Transaction "前缀，一个公钥公开过的任意签名都能花费它的全部UTXO，不能继续接受
+func (bc *Blockchain) activateSigHash() {
+	if bc.Spec.SigHashBlock >= 0 {
+		return
+	}
+
+	next := new(big.Int).Add(bc.GetBestNumber(), Big1)
+	bc.Spec.SigHashBlock = next.Int64()
+	SaveChainSpec(*bc.Spec)
+	log.Printf("警告: 链参数没有sigHashBlock，旧签名可以被重放到同一公钥的任意交易上！已从区块号%d开始强制只接受哈希签名，并写入%s；同一条链的全部节点必须使用相同的链参数文件", bc.Spec.SigHashBlock, ChainSpecFile)
+}
+
+//CheckAddressActive 检查地址的类型在下一个区块是否已激活，secp256k1地址在激活之前收到的币无法花费
+func (bc *Blockchain) CheckAddressActive(address string) error {
+	next := new(big.Int).Add(bc.GetBestNumber(), Big1)
+	if wallet.IsSecp256k1Address(address) && !bc.Spec.Secp256k1Active(next) {
+		return fmt.Errorf("ERROR: secp256k1地址%s尚未在链参数中激活", address)
+	}
+	if wallet.IsStealthAddress(address) && !bc.Spec.StealthActive(next) {
+		return fmt.Errorf("ERROR: 隐身地址%s尚未在链参数中激活", address)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//各种选币策略的名称
+const (
+	LargestFirst   = "largest"
+	SmallestFirst  = "smallest"
+	BranchAndBound = "bnb"
+	RandomSelect   = "random"
+)
+
+//bnbMaxTries 分支定界搜索的最大尝试次数，超过后放弃精确匹配
+const bnbMaxTries = 100000
+
+//Coin 钱包可以花费的一个未花费输出
+type Coin struct {
+	TxID       []byte
+	Vout       int
+	Value      int
+	PubKeyHash []byte //输出锁定的公钥哈希
+}
+
+//OutPoint 标识一个交易输出，用于手动指定交易的输入
+type OutPoint struct {
+	TxID []byte
+	Vout int
+}
+
+//String 按照txid:vout的格式输出
+func (op OutPoint) String() string {
+	return outpoint(op.TxID, op.Vout)
+}
+
+//ParseOutPoint 解析txid:vout格式的输出标识
+func ParseOutPoint(s string) (OutPoint, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 {
+		return OutPoint{}, fmt.Errorf("ERROR: 输入%q格式错误，应为txid:vout", s)
+	}
+	txID, err := hex.DecodeString(strings.TrimPrefix(parts[0], "0x")) //交易ID可带0x前缀
+	if err != nil || len(txID) == 0 {
+		return OutPoint{}, fmt.Errorf("ERROR: 输入%q的交易ID非法: %v", s, err)
+	}
+	vout, err := strconv.Atoi(parts[1])
+	if err != nil || vout < 0 {
+		return OutPoint{}, fmt.Errorf("ERROR: 输入%q的输出索引非法", s)
+	}
+
+	return OutPoint{TxID: txID, Vout: vout}, nil
+}
+
+//ParseOutPoints 解析以逗号分隔的多个txid:vout
+func ParseOutPoints(s string) ([]OutPoint, error) {
+	var ops []OutPoint
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		op, err := ParseOutPoint(part)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+
+	return ops, nil
+}
+
+//CoinSelector 选币策略：从钱包的未花费输出coins中选出合计不少于amount的一组输出
+type CoinSelector interface {
+	Select(coins []Coin, amount int) ([]Coin, error)
+}
+
+//NewCoinSelector 按名称返回选币策略，名称为空时使用默认的分支定界策略
+func NewCoinSelector(name string) (CoinSelector, error) {
+	switch strings.ToLower(name) {
+	case "", BranchAndBound:
+		return BranchAndBoundSelector{}, nil
+	case LargestFirst:
+		return LargestFirstSelector{}, nil
+	case SmallestFirst:
+		return SmallestFirstSelector{}, nil
+	case RandomSelect:
+		return RandomSelector{}, nil
+	}
+
+	return nil, fmt.Errorf("ERROR: 未知的选币策略%q，可选: %s, %s, %s, %s", name, LargestFirst, SmallestFirst, BranchAndBound, RandomSelect)
+}
+
+//errInsufficientFunds 可用的币不足
+var errInsufficientFunds = errors.New("ERROR:没有足够的钱。")
+
+//accumulate 按顺序累加coins，直到合计不少于amount
+func accumulate(coins []Coin, amount int) ([]Coin, error) {
+	var selected []Coin
+	total := 0
+	for _, coin := range coins {
+		if total >= amount {
+			break
+		}
+		selected = append(selected, coin)
+		total += coin.Value
+	}
+	if total < amount {
+		return nil, errInsufficientFunds
+	}
+
+	return selected, nil
+}
+
+//sortedCoins 返回按面值排序后的coins副本，desc为true时从大到小
+func sortedCoins(coins []Coin, desc bool) []Coin {
+	sorted := append([]Coin(nil), coins...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if desc {
+			return sorted[i].Value > sorted[j].Value
+		}
+		return sorted[i].Value < sorted[j].Value
+	})
+
+	return sorted
+}
+
+//LargestFirstSelector 优先使用面值最大的输出，输入数量最少
+type LargestFirstSelector struct{}
+
+//Select 实现CoinSelector
+func (LargestFirstSelector) Select(coins []Coin, amount int) ([]Coin, error) {
+	return accumulate(sortedCoins(coins, true), amount)
+}
+
+//SmallestFirstSelector 优先使用面值最小的输出，用于合并零碎的输出
+type SmallestFirstSelector struct{}
+
+//Select 实现CoinSelector
+func (SmallestFirstSelector) Select(coins []Coin, amount int) ([]Coin, error) {
+	return accumulate(sortedCoins(coins, false), amount)
+}
+
+//RandomSelector 随机顺序选取输出，使交易之间更难关联
+type RandomSelector struct{}
+
+//Select 实现CoinSelector
+func (RandomSelector) Select(coins []Coin, amount int) ([]Coin, error) {
+	shuffled := append([]Coin(nil), coins...)
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	r.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	return accumulate(shuffled, amount)
+}
+
+//BranchAndBoundSelector 分支定界搜索合计恰好等于amount的一组输出，这样交易不需要找零
+//找不到精确匹配时退回到LargestFirstSelector
+type BranchAndBoundSelector struct{}
+
+//Select 实现CoinSelector
+func (BranchAndBoundSelector) Select(coins []Coin, amount int) ([]Coin, error) {
+	sorted := sortedCoins(coins, true)
+
+	//remaining[i]为sorted[i:]的面值合计，用于剪枝
+	remaining := make([]int, len(sorted)+1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + sorted[i].Value
+	}
+	if remaining[0] < amount {
+		return nil, errInsufficientFunds
+	}
+
+	tries := 0
+	var picked []int
+	var search func(i, total int) bool
+	search = func(i, total int) bool {
+		tries++
+		if total == amount {
+			return true
+		}
+		if i == len(sorted) || total > amount || total+remaining[i] < amount || tries > bnbMaxTries {
+			return false
+		}
+
+		picked = append(picked, i) //先尝试包含第i个输出
+		if search(i+1, total+sorted[i].Value) {
+			return true
+		}
+		picked = picked[:len(picked)-1]
+
+		return search(i+1, total) //再尝试不包含
+	}
+
+	if search(0, 0) {
+		var selected []Coin
+		for _, i := range picked {
+			selected = append(selected, sorted[i])
+		}
+		return selected, nil
+	}
+
+	return LargestFirstSelector{}.Select(coins, amount)
+}
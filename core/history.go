@@ -0,0 +1,140 @@
+package core
+
+import (
+	"encoding/hex"
+	"zzschain/wallet"
+)
+
+//HistoryEntry 账户的一条交易记录，找零在账户内部流转，只体现在净额中
+type HistoryEntry struct {
+	TxID      string `json:"txid"`
+	Timestamp int64  `json:"timestamp"`
+	Received  int    `json:"received"` //转入账户各地址的金额（含找零）
+	Sent      int    `json:"sent"`     //账户各地址被花费的金额
+	Net       int    `json:"net"`      //账户余额的变化，Received-Sent
+
+	Counterparties []Counterparty `json:"counterparties,omitempty"` //转出时为收款人，转入时为付款人
+}
+
+//Counterparty 交易对方的地址及其在钱包中的标签
+type Counterparty struct {
+	Address string `json:"address"`
+	Label   string `json:"label,omitempty"`
+}
+
+//AccountHistory 遍历一次区块链，返回与公钥哈希pubKeyHashes（一个账户的全部地址）相关的交易，从旧到新排列
+func (bc *Blockchain) AccountHistory(pubKeyHashes [][]byte) []HistoryEntry {
+	owned := make(map[string]bool)
+	for _, hash := range pubKeyHashes {
+		owned[hex.EncodeToString(hash)] = true
+	}
+
+	var history []HistoryEntry
+	values := make(map[string]int) //账户收到的输出txid:vout对应的金额，用于计算花费
+	txs := bc.GetTransationHashes()
+	byID := make(map[string]Transaction, len(txs)) //secp256k1的输入不带公钥，付款地址由被花费的输出得到
+	for _, tx := range txs {
+		byID[hex.EncodeToString(tx.ID)] = tx
+	}
+	for _, tx := range txs {
+		entry := HistoryEntry{TxID: Encode(tx.ID), Timestamp: tx.Timestamp}
+
+		if !tx.IsCoinbase() {
+			for _, vin := range tx.Vin {
+				key := outpoint(vin.Txid, vin.Vout)
+				if value, ok := values[key]; ok {
+					entry.Sent += value
+					delete(values, key)
+				}
+			}
+		}
+		for i, out := range tx.Vout {
+			if out.IsHTLC() || out.IsDataCarrier() || !owned[hex.EncodeToString(out.PubKeyHash)] {
+				continue
+			}
+			entry.Received += out.Value
+			values[outpoint(tx.ID, i)] = out.Value
+		}
+
+		if entry.Received > 0 || entry.Sent > 0 {
+			entry.Net = entry.Received - entry.Sent
+			entry.Counterparties = counterparties(tx, byID, owned, entry.Sent > 0)
+			history = append(history, entry)
+		}
+	}
+
+	return history
+}
+
+//AccountPubKeyHashes 返回地址address所属账户全部地址的公钥哈希；address不在钱包文件ws中时只返回它自己
+func AccountPubKeyHashes(ws *wallet.Wallets, address string) [][]byte {
+	var hashes [][]byte
+	for _, addr := range ws.AccountAddresses(address) {
+		hashes = append(hashes, wallet.AddressToPubKeyHash([]byte(addr)))
+	}
+
+	return hashes
+}
+
+//counterparties 返回交易tx的对方地址：sent为true时是不属于账户的收款地址，否则是各输入的付款地址
+//不带公钥的输入从byID中找到被花费的输出，以输出锁定的公钥哈希作为付款地址
+func counterparties(tx Transaction, byID map[string]Transaction, owned map[string]bool, sent bool) []Counterparty {
+	var hashes [][]byte
+	secp := make(map[string]bool) //付款地址为secp256k1地址的公钥哈希
+	if sent {
+		for _, out := range tx.Vout {
+			if !out.IsHTLC() && !out.IsDataCarrier() {
+				hashes = append(hashes, out.PubKeyHash)
+			}
+		}
+	} else if !tx.IsCoinbase() {
+		for _, vin := range tx.Vin {
+			if len(vin.PubKey) > 0 {
+				hashes = append(hashes, wallet.HashPubKey(vin.PubKey))
+				continue
+			}
+			prevTx, ok := byID[hex.EncodeToString(vin.Txid)]
+			if !ok || vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+				continue
+			}
+			prevOut := prevTx.Vout[vin.Vout]
+			hash := prevOut.PubKeyHash
+			if prevOut.IsHTLC() && vin.Preimage != nil {
+				hash = prevOut.HTLC.RecipientPubKeyHash
+			} else if prevOut.IsHTLC() {
+				hash = prevOut.HTLC.RefundPubKeyHash
+			}
+			hashes = append(hashes, hash)
+			if vin.IsRecoverable() {
+				secp[hex.EncodeToString(hash)] = true
+			}
+		}
+	}
+
+	var parties []Counterparty
+	seen := make(map[string]bool)
+	for _, hash := range hashes {
+		key := hex.EncodeToString(hash)
+		if owned[key] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		address := wallet.PubKeyHashToAddress(hash)
+		if secp[key] {
+			address = wallet.Secp256k1PubKeyHashToAddress(hash)
+		}
+		parties = append(parties, Counterparty{Address: string(address)})
+	}
+
+	return parties
+}
+
+//AnnotateHistory 用钱包文件ws中的地址标签与通讯录标注交易对方
+func AnnotateHistory(ws *wallet.Wallets, history []HistoryEntry) {
+	for i := range history {
+		for j := range history[i].Counterparties {
+			party := &history[i].Counterparties[j]
+			party.Label = ws.LabelOf(party.Address)
+		}
+	}
+}
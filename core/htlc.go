@@ -0,0 +1,165 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+	"zzschain/wallet"
+)
+
+const (
+	//SecretLength HTLC原像（秘密）的长度
+	SecretLength = 32
+	//InitiatorLockTime 发起方合约的默认锁定区块数，必须大于参与方，保证发起方公开秘密后参与方仍有时间赎回
+	InitiatorLockTime = 48
+	//ParticipantLockTime 参与方合约的默认锁定区块数
+	ParticipantLockTime = 24
+)
+
+//HTLCLock 哈希时间锁（Hash Time-Locked Contract）
+//接收方公开SecretHash的原像即可花费；超过LockTime区块号后，发送方可以取回
+type HTLCLock struct {
+	SecretHash          []byte //秘密的sha256哈希
+	RecipientPubKeyHash []byte //接收方公钥哈希，凭原像赎回
+	RefundPubKeyHash    []byte //发送方公钥哈希，超时退款
+	LockTime            int64  //退款最早可被打包的区块号
+}
+
+// MatchesSecret 检查secret是否为SecretHash的原像
+func (h *HTLCLock) MatchesSecret(secret []byte) bool {
+	hash := sha256.Sum256(secret)
+
+	return bytes.Equal(hash[:], h.SecretHash)
+}
+
+// String 将HTLC转为人可读的信息
+func (h HTLCLock) String() string {
+	return fmt.Sprintf("hash=%x recipient=%x refund=%x locktime=%d", h.SecretHash, h.RecipientPubKeyHash, h.RefundPubKeyHash, h.LockTime)
+}
+
+// NewSecret 产生一个随机秘密及其哈希
+func NewSecret() ([]byte, []byte) {
+	secret := make([]byte, SecretLength)
+	_, err := rand.Read(secret)
+	Handle(err)
+	hash := sha256.Sum256(secret)
+
+	return secret, hash[:]
+}
+
+// NewHTLCOutput 创建一个HTLC输出，recipient与refund均为Base58地址
+func NewHTLCOutput(value int, recipient, refund []byte, secretHash []byte, lockTime int64) *TxOutput {
+	htlc := &HTLCLock{
+		SecretHash:          secretHash,
+		RecipientPubKeyHash: wallet.AddressToPubKeyHash(recipient),
+		RefundPubKeyHash:    wallet.AddressToPubKeyHash(refund),
+		LockTime:            lockTime,
+	}
+
+	return &TxOutput{Value: value, HTLC: htlc}
+}
+
+// NewHTLCTransaction 创建一笔HTLC合约交易：从钱包w向to锁定amount，找零退回w
+func NewHTLCTransaction(w *wallet.Wallet, to []byte, amount int, secretHash []byte, lockTime int64, UTXOSet *UTXOSet) (*Transaction, error) {
+	if err := w.CanSign(); err != nil {
+		return nil, err
+	}
+	if len(secretHash) != sha256.Size {
+		return nil, errors.New("ERROR: 秘密哈希长度不正确")
+	}
+
+	inputs, acc, err := spendableInputs(w, amount, UTXOSet, SendOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := []TxOutput{*NewHTLCOutput(amount, to, w.GetAddress(), secretHash, lockTime)}
+	if acc > amount {
+		outputs = append(outputs, *NewTxOutput(acc-amount, w.GetAddress())) //找零，退给sender
+	}
+
+	tx := Transaction{Vin: inputs, Vout: outputs, Timestamp: time.Now().Unix()}
+	tx.ID = tx.Hash()
+	UTXOSet.Blockchain.SignTransaction(&tx, w.PrivateKey)
+
+	return &tx, nil
+}
+
+// NewHTLCRedeemTransaction 接收方公开secret，赎回合约交易contract中的HTLC输出
+func NewHTLCRedeemTransaction(w *wallet.Wallet, contract *Transaction, secret []byte, UTXOSet *UTXOSet) (*Transaction, error) {
+	if err := w.CanSign(); err != nil {
+		return nil, err
+	}
+	vout, htlc, err := contract.FindHTLC()
+	if err != nil {
+		return nil, err
+	}
+	if !htlc.MatchesSecret(secret) {
+		return nil, errors.New("ERROR: 秘密与合约的哈希不匹配")
+	}
+	if !bytes.Equal(wallet.HashPubKey(w.PublicKey), htlc.RecipientPubKeyHash) {
+		return nil, errors.New("ERROR: 该钱包不是合约的接收方")
+	}
+
+	input := TxInput{Txid: contract.ID, Vout: vout, PubKey: w.PublicKey, Preimage: secret}
+	output := NewTxOutput(contract.Vout[vout].Value, w.GetAddress())
+
+	tx := Transaction{Vin: []TxInput{input}, Vout: []TxOutput{*output}, Timestamp: time.Now().Unix()}
+	tx.ID = tx.Hash()
+	UTXOSet.Blockchain.SignTransaction(&tx, w.PrivateKey)
+
+	return &tx, nil
+}
+
+// NewHTLCRefundTransaction 发送方在合约超时后取回合约交易contract中的HTLC输出
+//交易的LockTime设为合约的LockTime，在此之前的区块不会打包这笔交易
+func NewHTLCRefundTransaction(w *wallet.Wallet, contract *Transaction, UTXOSet *UTXOSet) (*Transaction, error) {
+	if err := w.CanSign(); err != nil {
+		return nil, err
+	}
+	vout, htlc, err := contract.FindHTLC()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(wallet.HashPubKey(w.PublicKey), htlc.RefundPubKeyHash) {
+		return nil, errors.New("ERROR: 该钱包不是合约的发送方")
+	}
+
+	input := TxInput{Txid: contract.ID, Vout: vout, PubKey: w.PublicKey}
+	output := NewTxOutput(contract.Vout[vout].Value, w.GetAddress())
+
+	tx := Transaction{Vin: []TxInput{input}, Vout: []TxOutput{*output}, Timestamp: time.Now().Unix(), LockTime: htlc.LockTime}
+	tx.ID = tx.Hash()
+	UTXOSet.Blockchain.SignTransaction(&tx, w.PrivateKey)
+
+	return &tx, nil
+}
+
+// FindHTLC 返回交易中HTLC输出的索引及其锁定条件
+func (tx *Transaction) FindHTLC() (int, *HTLCLock, error) {
+	for i, out := range tx.Vout {
+		if out.IsHTLC() {
+			return i, out.HTLC, nil
+		}
+	}
+
+	return -1, nil, errors.New("ERROR: 交易中没有HTLC输出")
+}
+
+// ExtractSecret 从赎回交易中提取与secretHash匹配的原像
+func ExtractSecret(redeem *Transaction, secretHash []byte) ([]byte, error) {
+	for _, vin := range redeem.Vin {
+		if vin.Preimage == nil {
+			continue
+		}
+		hash := sha256.Sum256(vin.Preimage)
+		if bytes.Equal(hash[:], secretHash) {
+			return vin.Preimage, nil
+		}
+	}
+
+	return nil, errors.New("ERROR: 交易中没有找到匹配的秘密")
+}
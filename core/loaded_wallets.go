@@ -0,0 +1,86 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"zzschain/wallet"
+)
+
+//LoadedWallets 节点已加载的钱包名称，HTTP接口只能使用已加载的钱包
+type LoadedWallets struct {
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+//NewLoadedWallets 创建没有加载任何钱包的集合
+func NewLoadedWallets() *LoadedWallets {
+	return &LoadedWallets{names: make(map[string]bool)}
+}
+
+//Load 加载钱包数据目录中的钱包name
+func (l *LoadedWallets) Load(name string) error {
+	if err := wallet.ValidateWalletName(name); err != nil {
+		return err
+	}
+	if !wallet.WalletExists(name) {
+		return fmt.Errorf("ERROR: 钱包%s不存在，请先用createwallet创建", name)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.names[name] {
+		return fmt.Errorf("ERROR: 钱包%s已经加载", name)
+	}
+	l.names[name] = true
+
+	return nil
+}
+
+//Unload 卸载钱包name，已解锁的加密钱包同时锁定
+func (l *LoadedWallets) Unload(name string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.names[name] {
+		return fmt.Errorf("ERROR: 钱包%s没有加载", name)
+	}
+	delete(l.names, name)
+
+	if ws, err := wallet.NewWallets(name); err == nil && ws.IsEncrypted() {
+		ws.Lock()
+	}
+
+	return nil
+}
+
+//Names 返回已加载的钱包名称，按名称排序
+func (l *LoadedWallets) Names() []string {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var names []string
+	for name := range l.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+//Resolve 返回请求使用的钱包名称，name必须指定且是已加载的钱包
+func (l *LoadedWallets) Resolve(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("ERROR: 请用参数wallet指定钱包名称")
+	}
+	for _, loaded := range l.Names() {
+		if loaded == name {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("ERROR: 钱包%s没有加载，请先用loadwallet加载", name)
+}
@@ -0,0 +1,409 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//MempoolEntry 交易池中的一笔交易及其手续费信息
+type MempoolEntry struct {
+	Tx   Transaction
+	Fee  int   //手续费
+	Size int   //交易序列化后的字节数
+	Time int64 //进入交易池的时间
+}
+
+// FeeRate 每字节手续费
+func (e *MempoolEntry) FeeRate() float64 {
+	return float64(e.Fee) / float64(e.Size)
+}
+
+//Mempool 待上链的交易池
+//除了交易本身，还记录每个输出被哪笔交易花费，用于发现双花与替换（RBF）
+type Mempool struct {
+	mu      sync.Mutex
+	entries map[string]*MempoolEntry //交易ID -> 交易
+	spends  map[string]string        //被花费的输出(txid:vout) -> 花费它的交易ID
+}
+
+// NewMempool 创建一个空的交易池
+func NewMempool() *Mempool {
+	return &Mempool{
+		entries: make(map[string]*MempoolEntry),
+		spends:  make(map[string]string),
+	}
+}
+
+// outpoint 输出的唯一标识
+func outpoint(txID []byte, vout int) string {
+	return fmt.Sprintf("%x:%d", txID, vout)
+}
+
+// Has 检查交易池中是否存在交易txID
+func (mp *Mempool) Has(txID string) bool {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	_, ok := mp.entries[txID]
+	return ok
+}
+
+// Get 返回交易池中的交易txID
+func (mp *Mempool) Get(txID string) (Transaction, bool) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	entry, ok := mp.entries[txID]
+	if !ok {
+		return Transaction{}, false
+	}
+	return entry.Tx, true
+}
+
+// Count 返回交易池中交易的数量
+func (mp *Mempool) Count() int {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	return len(mp.entries)
+}
+
+// Transactions 返回交易池中的全部交易
+func (mp *Mempool) Transactions() []Transaction {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	var txs []Transaction
+	for _, entry := range mp.entries {
+		txs = append(txs, entry.Tx)
+	}
+	return txs
+}
+
+// Add 校验交易并加入交易池
+//交易可以花费交易池中尚未上链的父交易的输出（子交易为父交易支付手续费，CPFP）
+//如果交易与池中的交易花费了相同的输出，只有当被冲突的交易都声明了可替换（RBF），
+//且新交易的手续费总额与每字节手续费都严格更高时，才替换掉冲突交易及其后代交易，否则拒绝
+//返回被替换掉的交易ID
+func (mp *Mempool) Add(tx Transaction, bc *Blockchain) ([]string, error) {
+	txID := hex.EncodeToString(tx.ID)
+	if tx.IsCoinbase() {
+		return nil, errors.New("ERROR: coinbase交易不能进入交易池")
+	}
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	if _, ok := mp.entries[txID]; ok {
+		return nil, nil
+	}
+
+	parents := mp.parentsOf(&tx)
+	if err := bc.CheckTransaction(&tx, parents); err != nil {
+		return nil, err
+	}
+
+	fee, err := mp.fee(&tx, parents, UTXOSet{bc})
+	if err != nil {
+		return nil, err
+	}
+	entry := &MempoolEntry{Tx: tx, Fee: fee, Size: len(tx.Serialize()), Time: time.Now().Unix()}
+
+	//找出与新交易花费了相同输出的交易
+	conflicts := make(map[string]bool)
+	for _, vin := range tx.Vin {
+		if spender, ok := mp.spends[outpoint(vin.Txid, vin.Vout)]; ok {
+			conflicts[spender] = true
+		}
+	}
+
+	var evicted []string
+	if len(conflicts) > 0 {
+		evicted, err = mp.checkReplacement(entry, conflicts)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range evicted {
+			if _, ok := parents[id]; ok {
+				return nil, fmt.Errorf("ERROR: 交易花费了将被它替换的交易%s的输出", id)
+			}
+		}
+		for _, id := range evicted {
+			mp.remove(id)
+		}
+	}
+
+	mp.entries[txID] = entry
+	for _, vin := range tx.Vin {
+		mp.spends[outpoint(vin.Txid, vin.Vout)] = txID
+	}
+
+	return evicted, nil
+}
+
+// parentsOf 返回tx在交易池中的父交易（tx花费了它们的输出），调用者需持有锁
+func (mp *Mempool) parentsOf(tx *Transaction) map[string]Transaction {
+	parents := make(map[string]Transaction)
+	for _, vin := range tx.Vin {
+		id := hex.EncodeToString(vin.Txid)
+		if entry, ok := mp.entries[id]; ok {
+			parents[id] = entry.Tx
+		}
+	}
+
+	return parents
+}
+
+// fee 计算交易的手续费，输入引用的输出可以来自交易池中的父交易parents，调用者需持有锁
+func (mp *Mempool) fee(tx *Transaction, parents map[string]Transaction, UTXOSet UTXOSet) (int, error) {
+	inputValue := 0
+	for _, vin := range tx.Vin {
+		if parent, ok := parents[hex.EncodeToString(vin.Txid)]; ok {
+			inputValue += parent.Vout[vin.Vout].Value
+			continue
+		}
+
+		out, ok := UTXOSet.FindOutput(vin.Txid, vin.Vout)
+		if !ok {
+			return 0, fmt.Errorf("ERROR: 输入引用的输出%x:%d不存在或已花费", vin.Txid, vin.Vout)
+		}
+		inputValue += out.Value
+	}
+
+	outputValue := 0
+	for _, out := range tx.Vout {
+		outputValue += out.Value
+	}
+
+	if inputValue < outputValue {
+		return 0, errors.New("ERROR: 交易输出大于输入")
+	}
+
+	return inputValue - outputValue, nil
+}
+
+// packageOf 返回交易txID及其在交易池中尚未被选中（selected）的全部祖先交易，父交易排在子交易前面
+//调用者需持有锁
+func (mp *Mempool) packageOf(txID string, selected map[string]bool) []string {
+	var pkg []string
+	visited := make(map[string]bool)
+
+	var visit func(id string)
+	visit = func(id string) {
+		if visited[id] || selected[id] {
+			return
+		}
+		visited[id] = true
+
+		entry, ok := mp.entries[id]
+		if !ok {
+			return
+		}
+		for _, vin := range entry.Tx.Vin {
+			visit(hex.EncodeToString(vin.Txid))
+		}
+		pkg = append(pkg, id) //祖先全部加入后才加入自身
+	}
+	visit(txID)
+
+	return pkg
+}
+
+// packageFee 合计一组交易的手续费与字节数，调用者需持有锁
+func (mp *Mempool) packageFee(pkg []string) (int, int) {
+	fee, size := 0, 0
+	for _, id := range pkg {
+		fee += mp.entries[id].Fee
+		size += mp.entries[id].Size
+	}
+
+	return fee, size
+}
+
+// SelectTransactions 为新区块选取最多max笔交易，返回按父交易在前排序的交易及其手续费总额
+//每次选取合计每字节手续费最高的交易包（交易及其尚未选中的祖先），
+//这样手续费较低的父交易可以借助手续费较高的子交易被打包（CPFP）
+//交易包整体加入区块：区块放不下或其中任何一笔校验失败时整个交易包都不加入
+func (mp *Mempool) SelectTransactions(bc *Blockchain, max int) ([]*Transaction, int) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	var txs []*Transaction
+	fees := 0
+	selected := make(map[string]bool)
+	skipped := make(map[string]bool)
+	inBlock := make(map[string]Transaction)
+
+	for len(txs) < max {
+		var best []string
+		var bestRate float64
+
+		for id := range mp.entries {
+			if selected[id] || skipped[id] {
+				continue
+			}
+			pkg := mp.packageOf(id, selected)
+			if len(txs)+len(pkg) > max || containsAny(pkg, skipped) {
+				skipped[id] = true
+				continue
+			}
+
+			fee, size := mp.packageFee(pkg)
+			rate := float64(fee) / float64(size)
+			if best == nil || rate > bestRate {
+				best, bestRate = pkg, rate
+			}
+		}
+		if best == nil {
+			break
+		}
+
+		//先校验整个交易包，父交易在前，子交易的输入可以引用包中的父交易
+		parents := make(map[string]Transaction, len(inBlock)+len(best))
+		for id, tx := range inBlock {
+			parents[id] = tx
+		}
+		valid := true
+		for _, id := range best {
+			tx := mp.entries[id].Tx
+			if !bc.VerifyTransactionWithParents(&tx, parents) { //例如锁定区块号未到，留在交易池中，其后代交易也不再选取
+				skipped[id] = true
+				valid = false
+				break
+			}
+			parents[id] = tx
+		}
+		if !valid {
+			continue
+		}
+
+		for _, id := range best {
+			tx := mp.entries[id].Tx
+			selected[id] = true
+			inBlock[id] = tx
+			txs = append(txs, &tx)
+			fees += mp.entries[id].Fee
+		}
+	}
+
+	return txs, fees
+}
+
+// containsAny 检查ids中是否有set中的元素
+func containsAny(ids []string, set map[string]bool) bool {
+	for _, id := range ids {
+		if set[id] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkReplacement 按照RBF规则检查entry能否替换冲突交易conflicts，返回需要移除的交易（含后代交易）
+func (mp *Mempool) checkReplacement(entry *MempoolEntry, conflicts map[string]bool) ([]string, error) {
+	evictedFee := 0
+	var evicted []string
+	seen := make(map[string]bool)
+
+	for id := range conflicts {
+		conflict := mp.entries[id]
+		if !conflict.Tx.Replaceable {
+			return nil, fmt.Errorf("ERROR: 与交易%s双花，且该交易未声明可替换", id)
+		}
+		if entry.FeeRate() <= conflict.FeeRate() {
+			return nil, fmt.Errorf("ERROR: 替换交易的每字节手续费%.4f不高于原交易%s的%.4f", entry.FeeRate(), id, conflict.FeeRate())
+		}
+
+		for _, d := range append([]string{id}, mp.descendants(id)...) {
+			if !seen[d] {
+				seen[d] = true
+				evicted = append(evicted, d)
+				evictedFee += mp.entries[d].Fee
+			}
+		}
+	}
+
+	if entry.Fee <= evictedFee {
+		return nil, fmt.Errorf("ERROR: 替换交易的手续费%d不高于被替换交易的手续费总额%d", entry.Fee, evictedFee)
+	}
+
+	return evicted, nil
+}
+
+// descendants 返回交易池中花费了交易txID输出的全部后代交易
+func (mp *Mempool) descendants(txID string) []string {
+	var result []string
+	queue := []string{txID}
+	seen := map[string]bool{txID: true}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		entry, ok := mp.entries[id]
+		if !ok {
+			continue
+		}
+		for vout := range entry.Tx.Vout {
+			child, ok := mp.spends[outpoint(entry.Tx.ID, vout)]
+			if ok && !seen[child] {
+				seen[child] = true
+				result = append(result, child)
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	return result
+}
+
+// remove 从交易池中删除一笔交易，调用者需持有锁
+func (mp *Mempool) remove(txID string) {
+	entry, ok := mp.entries[txID]
+	if !ok {
+		return
+	}
+	for _, vin := range entry.Tx.Vin {
+		key := outpoint(vin.Txid, vin.Vout)
+		if mp.spends[key] == txID {
+			delete(mp.spends, key)
+		}
+	}
+	delete(mp.entries, txID)
+}
+
+// Remove 从交易池中删除一笔交易（例如已经上链）
+func (mp *Mempool) Remove(txID string) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.remove(txID)
+}
+
+// RemoveBlock 区块上链后，从交易池中删除区块中的交易，以及与区块中交易双花的交易及其后代交易
+func (mp *Mempool) RemoveBlock(block *Block) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	for _, tx := range block.Transactions {
+		txID := hex.EncodeToString(tx.ID)
+		mp.remove(txID)
+
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, vin := range tx.Vin {
+			spender, ok := mp.spends[outpoint(vin.Txid, vin.Vout)]
+			if !ok {
+				continue
+			}
+			for _, id := range append(mp.descendants(spender), spender) {
+				mp.remove(id)
+			}
+		}
+	}
+}
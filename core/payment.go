@@ -0,0 +1,128 @@
+package core
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"zzschain/wallet"
+)
+
+//Payment 批量付款中的一笔：收款地址与金额
+type Payment struct {
+	Address string `json:"address"`
+	Amount  int    `json:"amount"`
+}
+
+//ValidatePayments 整体校验一批付款，返回付款总额
+//任何一笔的地址非法或金额不为正数都返回错误，错误中指明是第几笔；收款地址可以是隐身地址
+func ValidatePayments(payments []Payment) (int, error) {
+	if len(payments) == 0 {
+		return 0, errors.New("ERROR: 付款列表为空")
+	}
+
+	total := 0
+	for i, p := range payments {
+		if !wallet.ValidateRecipient(p.Address) {
+			return 0, fmt.Errorf("ERROR: 第%d笔付款的地址%q非法", i+1, p.Address)
+		}
+		if p.Amount <= 0 {
+			return 0, fmt.Errorf("ERROR: 第%d笔付款的金额%d必须为正数", i+1, p.Amount)
+		}
+		total += p.Amount
+	}
+
+	return total, nil
+}
+
+//ResolvePayments 将付款中的联系人名称替换为钱包文件ws通讯录中的地址，其他收款人保持不变
+func ResolvePayments(ws *wallet.Wallets, payments []Payment) {
+	for i := range payments {
+		if address, err := ws.ResolveAddress(payments[i].Address); err == nil {
+			payments[i].Address = address
+		}
+	}
+}
+
+//LoadPayments 从文件读取付款列表，扩展名为.json时按JSON数组解析，否则按CSV（地址,金额）解析
+func LoadPayments(path string) ([]Payment, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return ParsePaymentsJSON(content)
+	}
+	return ParsePaymentsCSV(content)
+}
+
+//ParsePaymentsJSON 解析[{"address": ..., "amount": ...}]格式的付款列表
+func ParsePaymentsJSON(content []byte) ([]Payment, error) {
+	var payments []Payment
+	if err := json.Unmarshal(content, &payments); err != nil {
+		return nil, fmt.Errorf("ERROR: 付款列表JSON格式错误: %v", err)
+	}
+
+	return payments, nil
+}
+
+//ParsePaymentsCSV 解析每行“地址,金额”格式的付款列表，第一行可以是表头
+func ParsePaymentsCSV(content []byte) ([]Payment, error) {
+	var payments []Payment
+
+	r := csv.NewReader(bytes.NewReader(content))
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ERROR: 付款列表CSV格式错误: %v", err)
+		}
+
+		amount, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil {
+			if first { //表头
+				continue
+			}
+			line, _ := r.FieldPos(1)
+			return nil, fmt.Errorf("ERROR: 付款列表第%d行的金额%q非法", line, record[1])
+		}
+		payments = append(payments, Payment{Address: strings.TrimSpace(record[0]), Amount: amount})
+	}
+
+	return payments, nil
+}
+
+//ParsePaymentList 解析以逗号分隔的“地址:金额”列表
+func ParsePaymentList(s string) ([]Payment, error) {
+	var payments []Payment
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.Split(part, ":")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("ERROR: 输出%q格式错误，应为地址:金额", part)
+		}
+		amount, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("ERROR: 输出%q的金额非法", part)
+		}
+		payments = append(payments, Payment{Address: fields[0], Amount: amount})
+	}
+
+	return payments, nil
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"zzschain/wallet"
+)
+
+//PSBTVersion 部分签名交易格式的版本号
+const PSBTVersion = 1
+
+//PSBT 部分签名交易（Partially Signed Transaction）
+//包含尚未签名完成的交易、每个输入所花费的输出，以及已经收集到的签名（在Tx.Vin的Signature与PubKey中）
+//签名只需要PSBT本身和私钥，不需要区块链数据，因此可以在离线的机器上完成；多个签名者分别签名后再合并
+type PSBT struct {
+	Version  int
+	Tx       Transaction
+	PrevOuts []TxOutput //PrevOuts[i]为Tx.Vin[i]所花费的输出
+}
+
+//NewPSBT 在只有地址（没有私钥）的节点上创建部分签名交易：从from的未花费输出中选币，向payments付款，找零退回from
+func NewPSBT(from []byte, payments []Payment, UTXOSet *UTXOSet, opts SendOptions) (*PSBT, error) {
+	tx, err := newBatchTransaction(from, nil, payments, UTXOSet, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	//从UTXO集中查找每个输入花费的输出，签名者据此签名并核对金额
+	return newPSBTFromTx(tx, UTXOSet)
+}
+
+//DeserializePSBT 反序列化部分签名交易，并检查其结构是否完整
+func DeserializePSBT(data []byte) (*PSBT, error) {
+	var psbt PSBT
+	if err := json.Unmarshal(data, &psbt); err != nil {
+		return nil, fmt.Errorf("ERROR: 部分签名交易格式错误: %v", err)
+	}
+	if psbt.Version != PSBTVersion {
+		return nil, fmt.Errorf("ERROR: 不支持的部分签名交易版本%d", psbt.Version)
+	}
+	if len(psbt.PrevOuts) != len(psbt.Tx.Vin) {
+		return nil, errors.New("ERROR: 部分签名交易缺少被花费的输出")
+	}
+	if !bytes.Equal(psbt.Tx.ID, psbt.unsignedHash()) {
+		return nil, errors.New("ERROR: 部分签名交易的ID与交易内容不符")
+	}
+
+	return &psbt, nil
+}
+
+//Serialize 序列化部分签名交易（JSON格式，便于在机器之间拷贝）
+func (p *PSBT) Serialize() []byte {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return data
+}
+
+//unsignedHash 计算去掉签名与公钥后的交易哈希，创建时交易ID即按此计算
+func (p *PSBT) unsignedHash() []byte {
+	txCopy := p.Tx.TrimmedCopy()
+	txCopy.Timestamp = p.Tx.Timestamp
+
+	return txCopy.Hash()
+}
+
+//Fee 返回交易的手续费：被花费的输出总额减去交易输出总额
+func (p *PSBT) Fee() int {
+	fee := 0
+	for _, out := range p.PrevOuts {
+		fee += out.Value
+	}
+	for _, out := range p.Tx.Vout {
+		fee -= out.Value
+	}
+
+	return fee
+}
+
+//Sign 使用钱包w对交易中属于w、且尚未签名的输入签名，返回新签名的输入个数
+//w已锁定或是只读地址时不签名
+func (p *PSBT) Sign(w *wallet.Wallet) int {
+	if w.CanSign() != nil {
+		return 0
+	}
+	pubKeyHash := wallet.HashPubKey(w.PublicKey)
+
+	signed := 0
+	for inID, prevOut := range p.PrevOuts {
+		if prevOut.IsHTLC() || !prevOut.IsLockedWithKey(pubKeyHash) || len(p.Tx.Vin[inID].Signature) > 0 {
+			continue
+		}
+
+		p.Tx.Vin[inID].PubKey = w.PublicKey
+		p.Tx.SignInput(w.PrivateKey, inID, prevOut)
+		signed++
+	}
+
+	return signed
+}
+
+//IsComplete 检查交易的全部输入是否都已签名
+func (p *PSBT) IsComplete() bool {
+	return p.Tx.IsSigned()
+}
+
+//CombinePSBT 合并同一笔交易的多个部分签名交易中收集到的签名
+func CombinePSBT(psbts []*PSBT) (*PSBT, error) {
+	if len(psbts) == 0 {
+		return nil, errors.New("ERROR: 没有需要合并的部分签名交易")
+	}
+
+	combined := *psbts[0]
+	combined.Tx.Vin = append([]TxInput(nil), psbts[0].Tx.Vin...)
+	for _, p := range psbts[1:] {
+		if !bytes.Equal(p.Tx.ID, combined.Tx.ID) {
+			return nil, fmt.Errorf("ERROR: 部分签名交易%x与%x不是同一笔交易", p.Tx.ID, combined.Tx.ID)
+		}
+
+		for inID, vin := range p.Tx.Vin {
+			if len(vin.Signature) > 0 && len(combined.Tx.Vin[inID].Signature) == 0 {
+				combined.Tx.Vin[inID].Signature = vin.Signature
+				combined.Tx.Vin[inID].PubKey = vin.PubKey
+			}
+		}
+	}
+
+	return &combined, nil
+}
+
+//Finalize 校验全部输入的签名，返回可以广播的完整交易
+func (p *PSBT) Finalize() (*Transaction, error) {
+	if !p.IsComplete() {
+		return nil, errors.New("ERROR: 交易尚有输入未签名")
+	}
+	for inID := range p.Tx.Vin {
+		if !p.Tx.VerifyInput(inID, p.PrevOuts[inID]) {
+			return nil, fmt.Errorf("ERROR: 第%d个输入的签名校验失败", inID)
+		}
+	}
+
+	tx := p.Tx
+	return &tx, nil
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"zzschain/wallet"
+)
+
+//RawInput 解码后的交易输入
+type RawInput struct {
+	TxID     string `json:"txid"`
+	Vout     int    `json:"vout"`
+	Address  string `json:"address,omitempty"` //由输入的公钥得到的地址，未签名时为空
+	PubKey   string `json:"pubkey,omitempty"`
+	Signed   bool   `json:"signed"`
+	Preimage string `json:"preimage,omitempty"`
+}
+
+//RawOutput 解码后的交易输出
+type RawOutput struct {
+	N       int    `json:"n"`
+	Value   int    `json:"value"`
+	Address string `json:"address,omitempty"`
+	HTLC    string `json:"htlc,omitempty"`
+	Data    string `json:"data,omitempty"`
+
+	Ephemeral string `json:"ephemeral,omitempty"` //付给隐身地址的输出携带的临时公钥
+}
+
+//RawTransaction 解码后人可读的交易
+type RawTransaction struct {
+	TxID        string      `json:"txid"`
+	Timestamp   int64       `json:"timestamp"`
+	LockTime    int64       `json:"locktime,omitempty"`
+	Replaceable bool        `json:"replaceable"`
+	Inputs      []RawInput  `json:"inputs"`
+	Outputs     []RawOutput `json:"outputs"`
+}
+
+//CreateRawTransaction 由明确给出的输入与输出创建尚未签名的交易，不查询区块链，也不选币、不找零
+//输入与输出的差额即为手续费
+func CreateRawTransaction(inputs []OutPoint, outputs []Payment, data []byte, lockTime int64, replaceable bool) (*Transaction, error) {
+	if len(inputs) == 0 {
+		return nil, errors.New("ERROR: 交易没有输入")
+	}
+	if _, err := ValidatePayments(outputs); err != nil {
+		return nil, err
+	}
+	if lockTime < 0 {
+		return nil, errors.New("ERROR: 锁定区块号不能为负数")
+	}
+
+	tx := Transaction{Timestamp: time.Now().Unix(), LockTime: lockTime, Replaceable: replaceable}
+	seen := make(map[string]bool)
+	for _, op := range inputs {
+		if seen[op.String()] {
+			return nil, fmt.Errorf("ERROR: 输入%s重复", op)
+		}
+		seen[op.String()] = true
+		tx.Vin = append(tx.Vin, TxInput{Txid: op.TxID, Vout: op.Vout})
+	}
+	for _, p := range outputs {
+		out, err := NewPaymentOutput(p)
+		if err != nil {
+			return nil, err
+		}
+		tx.Vout = append(tx.Vout, *out)
+	}
+	if data != nil {
+		dataOut, err := NewDataOutput(data)
+		if err != nil {
+			return nil, err
+		}
+		tx.Vout = append(tx.Vout, *dataOut)
+	}
+	tx.ID = tx.Hash() //输入的公钥由签名者填入，不参与交易ID的计算
+
+	return &tx, nil
+}
+
+//EncodeRawTransaction 将交易编码为hex字符串
+func EncodeRawTransaction(tx *Transaction) string {
+	return hex.EncodeToString(tx.Serialize())
+}
+
+//DecodeRawTransaction 从hex字符串解码交易，格式错误时返回错误而不是panic
+func DecodeRawTransaction(rawHex string) (*Transaction, error) {
+	data, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(rawHex), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: 交易hex格式错误: %v", err)
+	}
+
+	var tx Transaction
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&tx); err != nil {
+		return nil, fmt.Errorf("ERROR: 交易解码失败: %v", err)
+	}
+	if len(tx.ID) == 0 {
+		return nil, errors.New("ERROR: 交易缺少ID")
+	}
+
+	return &tx, nil
+}
+
+//NewRawTransaction 将交易转换为人可读的结构，地址以Base58显示
+func NewRawTransaction(tx *Transaction) RawTransaction {
+	raw := RawTransaction{
+		TxID:        hex.EncodeToString(tx.ID),
+		Timestamp:   tx.Timestamp,
+		LockTime:    tx.LockTime,
+		Replaceable: tx.Replaceable,
+	}
+
+	for _, vin := range tx.Vin {
+		input := RawInput{TxID: hex.EncodeToString(vin.Txid), Vout: vin.Vout, Signed: len(vin.Signature) > 0}
+		if len(vin.PubKey) > 0 {
+			input.PubKey = hex.EncodeToString(vin.PubKey)
+			input.Address = string(wallet.AddressFromPubKey(vin.PubKey))
+		}
+		if vin.Preimage != nil {
+			input.Preimage = hex.EncodeToString(vin.Preimage)
+		}
+		raw.Inputs = append(raw.Inputs, input)
+	}
+
+	for i, out := range tx.Vout {
+		output := RawOutput{N: i, Value: out.Value}
+		switch {
+		case out.IsDataCarrier():
+			output.Data = hex.EncodeToString(out.Data)
+		case out.IsHTLC():
+			output.HTLC = out.HTLC.String()
+		default:
+			output.Address = string(wallet.PubKeyHashToAddress(out.PubKeyHash))
+			if out.IsStealth() {
+				output.Ephemeral = hex.EncodeToString(out.Ephemeral)
+			}
+		}
+		raw.Outputs = append(raw.Outputs, output)
+	}
+
+	return raw
+}
+
+//SignRawTransaction 使用钱包w对交易中属于w的输入签名，被花费的输出从UTXO集中查找
+//返回新签名的输入个数
+func SignRawTransaction(tx *Transaction, w *wallet.Wallet, UTXOSet *UTXOSet) (int, error) {
+	if err := w.CanSign(); err != nil {
+		return 0, err
+	}
+	pubKeyHash := wallet.HashPubKey(w.PublicKey)
+
+	signed := 0
+	for inID, vin := range tx.Vin {
+		prevOut, ok := UTXOSet.FindOutput(vin.Txid, vin.Vout)
+		if !ok {
+			return 0, fmt.Errorf("ERROR: 输入%x:%d不存在或已花费", vin.Txid, vin.Vout)
+		}
+		if prevOut.IsHTLC() || !prevOut.IsLockedWithKey(pubKeyHash) {
+			continue
+		}
+
+		tx.Vin[inID].PubKey = w.PublicKey
+		tx.SignInput(w.PrivateKey, inID, prevOut)
+		signed++
+	}
+	if signed == 0 {
+		return 0, errors.New("ERROR: 私钥不能签名交易的任何输入")
+	}
+
+	return signed, nil
+}
+
+//IsSigned 检查交易的全部输入是否都已签名
+func (tx *Transaction) IsSigned() bool {
+	for _, vin := range tx.Vin {
+		if len(vin.Signature) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"zzschain/wallet"
+
+	log "github.com/sirupsen/logrus"
+)
+
+//RescanResult 重新扫描区块链得到的地址的交易记录与余额
+type RescanResult struct {
+	Address      string `json:"address"`
+	Transactions int    `json:"transactions"`
+	Balance      int    `json:"balance"`
+}
+
+//RescanAddress 扫描区块链，找出与地址address相关的交易，并由其收支计算余额
+//用于导入私钥之后，确认新加入的地址在链上的输出
+func (bc *Blockchain) RescanAddress(address string) RescanResult {
+	result := RescanResult{Address: address}
+	for _, entry := range bc.AccountHistory([][]byte{wallet.AddressToPubKeyHash([]byte(address))}) {
+		result.Transactions++
+		result.Balance += entry.Net
+	}
+
+	return result
+}
+
+//RescanOptions 扫描钱包的选项
+type RescanOptions struct {
+	From     int64                   //起始区块号，小于0时从缓存上次扫描到的位置继续
+	Progress func(height, tip int64) //每扫描完一个区块调用一次，可以在其中保存缓存作为检查点
+	Stop     <-chan struct{}         //关闭后在当前区块扫描完成时停止，之后可以继续扫描
+}
+
+//RescanWallet 从opts.From开始按区块号从小到大遍历一次区块链，为钱包的全部公钥哈希更新缓存cache
+//继续扫描时，钱包新增了地址或者区块链发生了重组，都改为从创世块重新扫描
+//指定opts.From时新增的地址只从opts.From开始扫描，之后继续扫描时仍然从创世块重新扫描
+//扫描完成返回true，被opts.Stop中断返回false，已扫描的区块保留在缓存中
+func (bc *Blockchain) RescanWallet(cache *WalletCache, pubKeyHashes [][]byte, opts RescanOptions) bool {
+	from := opts.From
+	resume := from < 0
+	if resume {
+		from = cache.Height + 1
+		if cache.MissingKeys(pubKeyHashes) > 0 {
+			from, resume = 0, false
+		}
+	}
+
+	owned := make(map[string]bool)
+	for _, hash := range pubKeyHashes {
+		owned[hex.EncodeToString(hash)] = true
+	}
+
+	hashes, tip, prev := bc.blockHashesFrom(from)
+	if resume && (tip < cache.Height || !bytes.Equal(prev, cache.Hash)) {
+		log.Info("区块链发生了重组，从创世块重新扫描钱包")
+		opts.From = 0
+		return bc.RescanWallet(cache, pubKeyHashes, opts)
+	}
+
+	//从创世块扫描时全部公钥哈希都已完整扫描；从中间的区块开始时，新增的公钥哈希缺少from之前的区块，不标记为已扫描
+	cache.Rollback(from)
+	if from == 0 {
+		cache.Keys = owned
+	}
+
+	for i := len(hashes) - 1; i >= 0; i-- {
+		block, err := bc.GetBlock(hashes[i])
+		if err != nil {
+			log.Panic(err)
+		}
+		cache.addBlock(&block, owned)
+		if opts.Progress != nil {
+			opts.Progress(cache.Height, tip)
+		}
+
+		select {
+		case <-opts.Stop:
+			return i == 0
+		default:
+		}
+	}
+
+	return true
+}
+
+//blockHashesFrom 从最新的区块向前，收集区块号不小于from的区块哈希，从新到旧排列，即hashes[i]的区块号为tip-i
+//prev为区块号from-1的区块哈希（from为0或大于tip+1时为nil），继续扫描时与上次扫描到的区块哈希比较，不同说明区块链发生了重组
+func (bc *Blockchain) blockHashesFrom(from int64) (hashes [][]byte, tip int64, prev []byte) {
+	tip = -1
+	bci := bc.Iterator()
+	for {
+		block := bci.Next()
+		number := block.Number.Int64()
+		if tip < 0 {
+			tip = number
+		}
+		if number < from {
+			if number == from-1 {
+				prev = block.Hash.Bytes()
+			}
+			break
+		}
+		hashes = append(hashes, block.Hash.Bytes())
+		if IsInitBlock(block.PrevHash.Bytes()) {
+			break
+		}
+	}
+
+	return hashes, tip, prev
+}
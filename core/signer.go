@@ -0,0 +1,99 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"zzschain/wallet"
+)
+
+//Signer 交易签名者：私钥可以在节点进程的钱包中，也可以在单独的签名程序（本地签名守护进程、硬件设备）中
+//签名者只看到部分签名交易，即交易与每个输入花费的输出，不需要区块链数据
+type Signer interface {
+	//SignPSBT 对部分签名交易中尚未签名的输入签名，返回签名后的部分签名交易；有输入无法签名或签名被拒绝时返回错误
+	SignPSBT(psbt *PSBT) (*PSBT, error)
+}
+
+//WalletSigner 使用本进程钱包中的私钥签名
+type WalletSigner struct {
+	Wallets []*wallet.Wallet
+}
+
+//NewWalletSigner 返回使用wallets中的私钥签名的签名者，每个输入由能解锁其花费的输出的第一个钱包签名
+func NewWalletSigner(wallets ...*wallet.Wallet) *WalletSigner {
+	return &WalletSigner{Wallets: wallets}
+}
+
+//SignPSBT 对部分签名交易中尚未签名的输入签名
+//secp256k1的输入不带公钥，因此按被花费输出锁定的公钥哈希查找签名的钱包
+func (s *WalletSigner) SignPSBT(psbt *PSBT) (*PSBT, error) {
+	signed := *psbt
+	signed.Tx.Vin = append([]TxInput(nil), psbt.Tx.Vin...)
+
+	for inID, vin := range signed.Tx.Vin {
+		if len(vin.Signature) > 0 {
+			continue
+		}
+		prevOut := signed.PrevOuts[inID]
+
+		var signer *wallet.Wallet
+		for _, w := range s.Wallets {
+			if len(w.PublicKey) > 0 && signed.Tx.canUnlock(vin, w.PublicKey, prevOut) {
+				signer = w
+				break
+			}
+		}
+		if signer == nil {
+			return nil, fmt.Errorf("ERROR: 钱包中没有第%d个输入的私钥", inID)
+		}
+		if err := signer.CanSign(); err != nil {
+			return nil, err
+		}
+
+		signed.Tx.Vin[inID].PubKey = signer.PublicKey
+		signed.Tx.SignInput(signer.PrivateKey, inID, prevOut)
+	}
+
+	return &signed, nil
+}
+
+//newPSBTFromTx 为尚未签名的交易tx构建部分签名交易，被花费的输出从UTXO集中查找
+func newPSBTFromTx(tx *Transaction, UTXOSet *UTXOSet) (*PSBT, error) {
+	psbt := &PSBT{Version: PSBTVersion, Tx: *tx}
+	psbt.Tx.Vin = append([]TxInput(nil), tx.Vin...)
+	for _, vin := range tx.Vin {
+		out, ok := UTXOSet.FindOutput(vin.Txid, vin.Vout)
+		if !ok {
+			return nil, fmt.Errorf("ERROR: 输入%x:%d不存在或已花费", vin.Txid, vin.Vout)
+		}
+		psbt.PrevOuts = append(psbt.PrevOuts, out)
+	}
+
+	return psbt, nil
+}
+
+//signTransaction 由signer对交易的每一个输入签名，被花费的输出从UTXO集中查找
+//签名者返回的部分签名交易只取回签名与公钥，且每个签名都要校验，外部签名者无法改动交易内容
+func signTransaction(tx *Transaction, signer Signer, UTXOSet *UTXOSet) error {
+	psbt, err := newPSBTFromTx(tx, UTXOSet)
+	if err != nil {
+		return err
+	}
+	signed, err := signer.SignPSBT(psbt)
+	if err != nil {
+		return err
+	}
+	if signed == nil || !bytes.Equal(signed.Tx.ID, tx.ID) || len(signed.Tx.Vin) != len(tx.Vin) {
+		return errors.New("ERROR: 签名者返回的交易与请求签名的交易不符")
+	}
+
+	for inID, vin := range signed.Tx.Vin {
+		tx.Vin[inID].Signature = vin.Signature
+		tx.Vin[inID].PubKey = vin.PubKey
+		if !tx.VerifyInput(inID, psbt.PrevOuts[inID]) {
+			return fmt.Errorf("ERROR: 签名者返回的第%d个输入的签名无效", inID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+//外部签名者的地址：unix:套接字路径，或exec:命令行（启动签名程序，通过其标准输入输出通信）
+const (
+	signerUnixPrefix = "unix:"
+	signerExecPrefix = "exec:"
+)
+
+//SignerRequest 发给外部签名程序的签名请求，每个请求、响应为一行JSON
+type SignerRequest struct {
+	ID   uint64 `json:"id"`
+	PSBT *PSBT  `json:"psbt"`
+}
+
+//SignerResponse 外部签名程序的响应，用户拒绝或无法签名时Approved为false，Error为原因
+type SignerResponse struct {
+	ID       uint64 `json:"id"`
+	Approved bool   `json:"approved"`
+	PSBT     *PSBT  `json:"psbt,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+//ExternalSigner 由单独的签名程序签名，私钥不在节点进程中
+//同一时间只发送一个请求，连接断开后下一个请求时重新连接
+type ExternalSigner struct {
+	endpoint string
+
+	mu     sync.Mutex
+	conn   io.ReadWriteCloser
+	cmd    *exec.Cmd
+	enc    *json.Encoder
+	dec    *json.Decoder
+	nextID uint64
+}
+
+//stdioConn 将签名程序的标准输入输出组合为一个连接
+type stdioConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+//DialSigner 连接外部签名者，endpoint为unix:套接字路径，或exec:启动签名程序的命令行
+func DialSigner(endpoint string) (*ExternalSigner, error) {
+	if !strings.HasPrefix(endpoint, signerUnixPrefix) && !strings.HasPrefix(endpoint, signerExecPrefix) {
+		return nil, fmt.Errorf("ERROR: 签名者地址%q必须以unix:或exec:开头", endpoint)
+	}
+
+	s := &ExternalSigner{endpoint: endpoint}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+//connect 连接unix套接字，或启动签名程序
+func (s *ExternalSigner) connect() error {
+	if path := strings.TrimPrefix(s.endpoint, signerUnixPrefix); path != s.endpoint {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			return fmt.Errorf("ERROR: 无法连接签名者%s: %v", path, err)
+		}
+		s.setConn(conn)
+		return nil
+	}
+
+	args := strings.Fields(strings.TrimPrefix(s.endpoint, signerExecPrefix))
+	if len(args) == 0 {
+		return errors.New("ERROR: 签名程序的命令行为空")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("ERROR: 无法启动签名程序%s: %v", args[0], err)
+	}
+	s.cmd = cmd
+	s.setConn(stdioConn{Reader: stdout, WriteCloser: stdin})
+
+	return nil
+}
+
+//setConn 在连接conn上收发每行一个的JSON
+func (s *ExternalSigner) setConn(conn io.ReadWriteCloser) {
+	s.conn = conn
+	s.enc = json.NewEncoder(conn)
+	s.dec = json.NewDecoder(conn)
+}
+
+//SignPSBT 将部分签名交易发给签名程序，等待用户批准或拒绝
+func (s *ExternalSigner) SignPSBT(psbt *PSBT) (*PSBT, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.nextID++
+	req := SignerRequest{ID: s.nextID, PSBT: psbt}
+	var resp SignerResponse
+	if err := s.enc.Encode(&req); err != nil {
+		s.close()
+		return nil, fmt.Errorf("ERROR: 发送签名请求失败: %v", err)
+	}
+	if err := s.dec.Decode(&resp); err != nil {
+		s.close()
+		return nil, fmt.Errorf("ERROR: 读取签名响应失败: %v", err)
+	}
+	if resp.ID != req.ID {
+		s.close()
+		return nil, fmt.Errorf("ERROR: 签名响应的id %d与请求的id %d不符", resp.ID, req.ID)
+	}
+
+	if !resp.Approved {
+		if resp.Error == "" {
+			resp.Error = "ERROR: 签名被拒绝"
+		}
+		return nil, errors.New(resp.Error)
+	}
+	if resp.PSBT == nil {
+		return nil, errors.New("ERROR: 签名响应中没有部分签名交易")
+	}
+
+	return resp.PSBT, nil
+}
+
+//Close 断开与签名者的连接，由本进程启动的签名程序随之退出
+func (s *ExternalSigner) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.close()
+}
+
+//close 断开连接并等待签名程序退出，调用者须持有s.mu
+func (s *ExternalSigner) close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	if s.cmd != nil {
+		s.cmd.Wait()
+		s.cmd = nil
+	}
+	s.conn, s.enc, s.dec = nil, nil, nil
+
+	return err
+}
+
+//ServeSigner 在连接conn上处理签名请求，直到对方断开：每个请求先由approve展示交易并询问用户，批准后由signer签名
+//签名程序的unix套接字的每个连接、或标准输入输出各调用一次
+func ServeSigner(conn io.ReadWriter, signer Signer, approve func(psbt *PSBT) bool) error {
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+
+	for {
+		var req SignerRequest
+		if err := dec.Decode(&req); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		resp := SignerResponse{ID: req.ID}
+		switch {
+		case req.PSBT == nil || len(req.PSBT.PrevOuts) != len(req.PSBT.Tx.Vin):
+			resp.Error = "ERROR: 签名请求缺少部分签名交易或被花费的输出"
+		case !approve(req.PSBT):
+			resp.Error = "ERROR: 签名被用户拒绝"
+		default:
+			signed, err := signer.SignPSBT(req.PSBT)
+			if err != nil {
+				resp.Error = err.Error()
+				break
+			}
+			resp.Approved, resp.PSBT = true, signed
+		}
+
+		if err := enc.Encode(&resp); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"bytes"
+	"zzschain/wallet"
+
+	log "github.com/sirupsen/logrus"
+)
+
+//StealthPayment 扫描找到的一笔付给隐身地址的输出
+type StealthPayment struct {
+	Stealth string //收款的隐身地址
+	Address string //输出锁定的一次性地址，其私钥已加入钱包文件
+	TxID    string
+	Vout    int
+	Value   int
+	Height  int64
+}
+
+//ScanStealth 从区块号from开始按区块号从小到大遍历区块链，找出付给钱包ws中隐身地址的输出，将一次性地址的私钥加入ws
+//from小于0时从全部隐身地址都已扫描完成的区块之后继续，区块链发生了重组时从创世块重新扫描；之前扫描时已经加入的一次性地址同样返回
+//扫描完成后为扫描覆盖了从创世块到最新区块的隐身地址记录已扫描到的区块，之后需要调用ws.SaveToFile保存
+//一次性地址加入钱包后与其他地址一样查询余额、花费，钱包缓存需要用rescanwallet重新扫描
+func (bc *Blockchain) ScanStealth(ws *wallet.Wallets, from int64) ([]StealthPayment, error) {
+	resume := from < 0
+	if resume {
+		height, err := ws.StealthHeight()
+		if err != nil {
+			return nil, err
+		}
+		from = height + 1
+	}
+	if ws.IsLocked() {
+		return nil, wallet.ErrWalletLocked
+	}
+
+	hashes, tip, prev := bc.blockHashesFrom(from)
+
+	//隐身地址已扫描到的区块仍在区块链上，且不早于from-1时，这次扫描之后就覆盖了从创世块到最新区块
+	covered := func(keys *wallet.StealthKeys) bool {
+		switch {
+		case keys.Height < from-1 || keys.Height > tip:
+			return false
+		case keys.Height == from-1:
+			return bytes.Equal(keys.Hash, prev)
+		default:
+			return bytes.Equal(keys.Hash, hashes[tip-keys.Height])
+		}
+	}
+	if resume {
+		for _, address := range ws.StealthAddresses() {
+			if keys := ws.Stealth[address]; keys.Height == from-1 && !covered(keys) {
+				log.Info("区块链发生了重组，从创世块重新扫描隐身地址")
+				return bc.ScanStealth(ws, 0)
+			}
+		}
+	}
+
+	//继续扫描时，已扫描到更后面区块的隐身地址不重复返回之前找到的付款
+	scanned := make(map[string]int64)
+	for address, keys := range ws.Stealth {
+		scanned[address] = keys.Height
+	}
+
+	var payments []StealthPayment
+	for i := len(hashes) - 1; i >= 0; i-- {
+		block, err := bc.GetBlock(hashes[i])
+		if err != nil {
+			log.Panic(err)
+		}
+		for _, tx := range block.Transactions {
+			for vout, out := range tx.Vout {
+				if !out.IsStealth() {
+					continue
+				}
+				address, err := ws.ScanStealthOutput(out.Ephemeral, out.PubKeyHash)
+				if err != nil {
+					return payments, err
+				}
+				if address == "" || resume && block.Number.Int64() <= scanned[ws.Wallets[address].Stealth] {
+					continue
+				}
+				payments = append(payments, StealthPayment{
+					Stealth: ws.Wallets[address].Stealth,
+					Address: address,
+					TxID:    Encode(tx.ID),
+					Vout:    vout,
+					Value:   out.Value,
+					Height:  block.Number.Int64(),
+				})
+			}
+		}
+	}
+
+	//继续扫描时from之前的区块已由每个隐身地址扫描过；指定from时，from之前尚未扫描完成的隐身地址不记录
+	tipHash := prev //没有新区块时from-1即为最新区块
+	if len(hashes) > 0 {
+		tipHash = hashes[0]
+	}
+	for _, address := range ws.StealthAddresses() {
+		if resume || covered(ws.Stealth[address]) {
+			ws.SetStealthHeight(address, tip, tipHash)
+		}
+	}
+
+	return payments, nil
+}
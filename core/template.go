@@ -0,0 +1,85 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"zzschain/wallet"
+)
+
+//TemplateInput 交易模板中的一个输入：被花费的输出，以及客户端需要签名的数据
+type TemplateInput struct {
+	TxID        string `json:"txid"`
+	Vout        int    `json:"vout"`
+	Value       int    `json:"value"`
+	Address     string `json:"address"`
+	SigningData string `json:"signing_data"` //hex编码的32字节sha256摘要，客户端直接对摘要做ECDSA签名，不再哈希
+
+	//SigningMessage hex编码的签名数据，signing_data即其sha256摘要；WebCrypto等签名前总会做哈希的库对它签名
+	SigningMessage string `json:"signing_message"`
+}
+
+//TxTemplate 未签名的交易模板，节点不接触私钥，由客户端签名后提交
+type TxTemplate struct {
+	Hex     string          `json:"hex"`
+	TxID    string          `json:"txid"`
+	Inputs  []TemplateInput `json:"inputs"`
+	Outputs []RawOutput     `json:"outputs"`
+	Fee     int             `json:"fee"`
+}
+
+//InputSignature 客户端对交易模板中一个输入的签名
+type InputSignature struct {
+	Signature string `json:"signature"` //hex编码的对signing_data的DER签名（也接受定长的r||s），secp256k1为65字节可恢复签名
+	PubKey    string `json:"pubkey"`    //hex编码的公钥，SEC1压缩格式，可恢复签名不需要公钥
+}
+
+//NewTxTemplate 由部分签名交易生成交易模板，包含每个输入花费的输出与需要签名的数据
+func NewTxTemplate(p *PSBT) TxTemplate {
+	template := TxTemplate{
+		Hex:     EncodeRawTransaction(&p.Tx),
+		TxID:    hex.EncodeToString(p.Tx.ID),
+		Outputs: NewRawTransaction(&p.Tx).Outputs,
+		Fee:     p.Fee(),
+	}
+
+	for inID, vin := range p.Tx.Vin {
+		prevOut := p.PrevOuts[inID]
+		template.Inputs = append(template.Inputs, TemplateInput{
+			TxID:           hex.EncodeToString(vin.Txid),
+			Vout:           vin.Vout,
+			Value:          prevOut.Value,
+			Address:        string(wallet.PubKeyHashToAddress(prevOut.PubKeyHash)),
+			SigningData:    hex.EncodeToString(p.Tx.SigningHash(inID, prevOut)),
+			SigningMessage: hex.EncodeToString(p.Tx.SigningData(inID, prevOut)),
+		})
+	}
+
+	return template
+}
+
+//ApplySignatures 将客户端对每个输入的签名与公钥填入交易并校验，sigs[i]对应tx.Vin[i]，prevOuts[i]为其花费的输出
+//secp256k1的可恢复签名不带公钥，由签名恢复
+func ApplySignatures(tx *Transaction, prevOuts []TxOutput, sigs []InputSignature) error {
+	if len(sigs) != len(tx.Vin) {
+		return fmt.Errorf("ERROR: 交易有%d个输入，但提交了%d个签名", len(tx.Vin), len(sigs))
+	}
+
+	for inID, sig := range sigs {
+		signature, err := hex.DecodeString(strings.TrimPrefix(sig.Signature, "0x"))
+		if err != nil || len(signature) == 0 {
+			return fmt.Errorf("ERROR: 第%d个输入的签名格式错误", inID)
+		}
+		pubKey, err := hex.DecodeString(strings.TrimPrefix(sig.PubKey, "0x"))
+		if err != nil || len(pubKey) == 0 && len(signature) != wallet.RecoverableSignatureLen {
+			return fmt.Errorf("ERROR: 第%d个输入的公钥格式错误", inID)
+		}
+		tx.Vin[inID].Signature = signature
+		tx.Vin[inID].PubKey = pubKey
+		if !tx.VerifyInput(inID, prevOuts[inID]) {
+			return fmt.Errorf("ERROR: 第%d个输入的签名不是对signing_data的有效签名", inID)
+		}
+	}
+
+	return nil
+}
@@ -3,11 +3,10 @@ package core
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/gob"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
@@ -22,6 +21,10 @@ type Transaction struct {
 	Vin       []TxInput  `json:"Vin"`       //交易输入，由上次交易输入（可能多个）
 	Vout      []TxOutput `json:"Vout"`      //交易输出，由本次交易产生（可能多个）
 	Timestamp int64      `json:"Timestamp"` //时间戳，确保每一笔交易的ID完全不同
+	LockTime  int64      `json:"LockTime"`  //锁定区块号，交易只能被打包进区块号不小于LockTime的区块，为0表示不锁定
+
+	//Replaceable 交易声明可被替换（RBF），上链前允许手续费更高的冲突交易替换它
+	Replaceable bool `json:"Replaceable"`
 }
 
 //IsCoinbase 检查交易是否是创始区块交易
@@ -83,38 +86,47 @@ func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transac
 		}
 	}
 
-	//将会被签署的是修剪后的当前交易的交易副本，而不是一个完整交易
-	//txCopy拥有当前交易的全部输出数据和部分输入数据
-	txCopy := tx.TrimmedCopy()
-
-	//迭代副本中的每一个输入，分别进行签名
-	for inID, vin := range txCopy.Vin {
+	for inID, vin := range tx.Vin {
 		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		//在每个输入中，`Signature`被设置为`nil`(Signature仅仅是一个双重检验，所以没有必要放进来)
-		//实际上，在构建交易时候，计算交易ID时，Signature也是nil（设置交易ID是在对交易进行签名前完成）
-		//这里也是为计算交易副本的ID，所以Signature也设置为nil
-		txCopy.Vin[inID].Signature = nil
-
-		//输入中的`pubKey`被设置为所引用输出的`PubKeyHash`（注意，不是原生态公钥）
-		//虽然在我们的例子中，每一个输入的prevTx.Vout[vin.Vout].PubKeyHash都会相同(自己挖矿，包含的交易都是自己发起的）
-		//，但是比特币允许交易包含引用了不同地址的输入（即来自不同地址发起的交易），所以这里仍然这么做（每一个输入分开签名）
-		//实际上，是将输入的PubKey从自己钱包的PubKey替换为该输入引用输出索引对应的交易的PubKeyHash
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-		dataToSign := fmt.Sprintf("%x\n", txCopy)
-
-		///签名的是交易副本数据
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, []byte(dataToSign)) //对副本进行签名
-		if err != nil {
-			log.Panic(err)
-		}
-		//一个 ECDSA 签名就是一对数字。连接切片，构建签名
-		signature := append(r.Bytes(), s.Bytes()...)
+		tx.SignInput(privKey, inID, prevTx.Vout[vin.Vout])
+	}
+}
+
+// SignInput 对交易的第inID个输入进行签名，prevOut为该输入引用的输出
+//只需要被花费的输出而不需要区块链数据，因此可以在离线的机器上签名
+func (tx *Transaction) SignInput(privKey ecdsa.PrivateKey, inID int, prevOut TxOutput) {
+	dataToSign := tx.SigningData(inID, prevOut)
 
-		//**副本中每一个输入是被分开签名的**
-		//尽管这对于我们的应用并不十分紧要，但是比特币允许交易包含引用了不同地址的输入
-		tx.Vin[inID].Signature = signature
-		txCopy.Vin[inID].PubKey = nil //重置pubkey为nil
+	///签名的是交易副本数据的sha256哈希
+	//一个 ECDSA 签名就是一对数字，按DER编码，不会因为数字有前导零而无法拆分
+	//secp256k1私钥的签名为可恢复签名，验证时由签名恢复公钥，输入不再带公钥
+	signature, err := wallet.Sign(&privKey, dataToSign)
+	if err != nil {
+		log.Panic(err)
 	}
+
+	tx.Vin[inID].Signature = signature
+	if wallet.IsSecp256k1(&privKey.PublicKey) {
+		tx.Vin[inID].PubKey = nil
+	}
+}
+
+// SigningData 返回交易第inID个输入需要签名的数据，prevOut为该输入引用的输出
+//签名的是修剪后的交易副本，而不是一个完整交易：副本拥有当前交易的全部输出数据和部分输入数据，其中Signature与PubKey均为nil，
+//只有第inID个输入的PubKey被设置为所引用输出的PubKeyHash（注意，不是原生态公钥）
+//比特币允许交易包含引用了不同地址的输入（即来自不同地址发起的交易），所以每一个输入分开签名
+func (tx *Transaction) SigningData(inID int, prevOut TxOutput) []byte {
+	txCopy := tx.TrimmedCopy()
+	txCopy.Vin[inID].PubKey = prevOut.PubKeyHash
+
+	return []byte(fmt.Sprintf("%x\n", txCopy))
+}
+
+// SigningHash 返回签名数据的sha256哈希，即私钥实际签名的32字节摘要
+func (tx *Transaction) SigningHash(inID int, prevOut TxOutput) []byte {
+	hash := sha256.Sum256(tx.SigningData(inID, prevOut))
+
+	return hash[:]
 }
 
 // String 将交易转为人可读的信息
@@ -130,12 +142,31 @@ func (tx Transaction) String() string {
 		lines = append(lines, fmt.Sprintf("       Out:       %d", input.Vout))
 		lines = append(lines, fmt.Sprintf("       Signature: %x", input.Signature))
 		lines = append(lines, fmt.Sprintf("       PubKey:    %x", input.PubKey))
+		if input.Preimage != nil {
+			lines = append(lines, fmt.Sprintf("       Preimage:  %x", input.Preimage))
+		}
 	}
 
 	for i, output := range tx.Vout {
 		lines = append(lines, fmt.Sprintf("     Output %d:", i))
 		lines = append(lines, fmt.Sprintf("       Value:  %d", output.Value))
 		lines = append(lines, fmt.Sprintf("       PubKeyHash: %x", output.PubKeyHash))
+		if output.IsHTLC() {
+			lines = append(lines, fmt.Sprintf("       HTLC:       %s", output.HTLC))
+		}
+		if output.IsDataCarrier() {
+			lines = append(lines, fmt.Sprintf("       Data:       %x", output.Data))
+		}
+		if output.IsStealth() {
+			lines = append(lines, fmt.Sprintf("       Ephemeral:  %x", output.Ephemeral))
+		}
+	}
+
+	if tx.LockTime != 0 {
+		lines = append(lines, fmt.Sprintf("     LockTime: %d", tx.LockTime))
+	}
+	if tx.Replaceable {
+		lines = append(lines, "     Replaceable: true")
 	}
 
 	return strings.Join(lines, "\n")
@@ -150,14 +181,14 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 	for _, vin := range tx.Vin {
 		//包含了所有的输入和输出，但是`TXInput.Signature`和`TXIput.PubKey`被设置为`nil`
 		//在调用这个方法后，会用引用前一个交易的输出的PubKeyHash，取代这里的PubKey
-		inputs = append(inputs, TxInput{vin.Txid, vin.Vout, nil, nil})
+		inputs = append(inputs, TxInput{Txid: vin.Txid, Vout: vin.Vout, Preimage: vin.Preimage})
 	}
 
 	for _, vout := range tx.Vout {
-		outputs = append(outputs, TxOutput{vout.Value, vout.PubKeyHash})
+		outputs = append(outputs, vout)
 	}
 
-	txCopy := Transaction{tx.ID, inputs, outputs, time.Now().Unix()}
+	txCopy := Transaction{ID: tx.ID, Vin: inputs, Vout: outputs, Timestamp: time.Now().Unix(), LockTime: tx.LockTime, Replaceable: tx.Replaceable}
 
 	return txCopy
 }
@@ -175,45 +206,107 @@ func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 		}
 	}
 
-	txCopy := tx.TrimmedCopy() //同一笔交易的副本
-	curve := elliptic.P256()   //生成密钥对的椭圆曲线
-
 	//迭代每个输入
 	for inID, vin := range tx.Vin {
-		//以下代码跟签名一样，因为在验证阶段，我们需要的是与签名相同的数据
 		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-
-		//解包存储在`TXInput.Signature`和`TXInput.PubKey`中的值
+		if !tx.VerifyInput(inID, prevTx.Vout[vin.Vout]) {
+			return false
+		}
+	}
 
-		//一个签名就是一对长度相同的数字。
-		r := big.Int{}
-		s := big.Int{}
-		sigLen := len(vin.Signature)
-		r.SetBytes(vin.Signature[:(sigLen / 2)])
-		s.SetBytes(vin.Signature[(sigLen / 2):])
+	return true
+}
 
-		//从输入中直接取出公钥数组，解析为一对长度相同的坐标
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(vin.PubKey) //vin.PubKey为原生态公钥数组
-		x.SetBytes(vin.PubKey[:(keyLen / 2)])
-		y.SetBytes(vin.PubKey[(keyLen / 2):])
+// VerifyInput 校验交易第inID个输入的签名，prevOut为该输入引用的输出
+func (tx *Transaction) VerifyInput(inID int, prevOut TxOutput) bool {
+	return tx.verifyInput(inID, prevOut, false)
+}
 
-		dataToVerify := fmt.Sprintf("%x\n", txCopy)
+// verifyInput 校验交易第inID个输入的签名，unhashed为true时也接受旧版本直接对签名数据（不先哈希）的P256签名
+//旧版本的签名只覆盖签名数据的前32字节，即"--- Transaction "，可以被搬到任意交易上，只在哈希签名激活之前的区块中接受
+func (tx *Transaction) verifyInput(inID int, prevOut TxOutput, unhashed bool) bool {
+	vin := tx.Vin[inID]
+	if len(vin.Signature) == 0 {
+		return false
+	}
 
-		//从解析的坐标创建一个rawPubKey（原生态公钥）
-		rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
+	//在验证阶段，我们需要的是与签名相同的数据
+	dataToVerify := tx.SigningData(inID, prevOut)
 
-		//使用公钥验证副本的签名，是否私钥签名档结果一致（&r和&s是私钥签名txCopy.ID的结果）
-		if ecdsa.Verify(&rawPubKey, []byte(dataToVerify), &r, &s) == false {
+	//secp256k1的输入不带公钥，由可恢复签名恢复出公钥，公钥能解锁所引用的输出则签名有效
+	if vin.IsRecoverable() {
+		pubKey, err := wallet.RecoverPubKey(dataToVerify, vin.Signature)
+		if err != nil {
 			return false
 		}
-		txCopy.Vin[inID].PubKey = nil
+		return tx.canUnlock(vin, pubKey, prevOut)
 	}
 
-	return true
+	//检查输入的公钥能否解锁所引用的输出
+	if len(vin.PubKey) == 0 || !tx.canUnlock(vin, vin.PubKey, prevOut) {
+		return false
+	}
+
+	//vin.PubKey为SEC1编码或旧版本编码的公钥，签名为DER编码或旧版本的r||s，均由wallet包解析
+	if wallet.VerifySignature(vin.PubKey, dataToVerify, vin.Signature) {
+		return true
+	}
+	return unhashed && wallet.VerifyUnhashedSignature(vin.PubKey, dataToVerify, vin.Signature)
+}
+
+// canUnlock 检查公钥为pubKey的输入vin是否满足所引用输出prevOut的锁定条件
+//普通输出要求公钥哈希一致；HTLC输出要求接收方提供正确的原像，或发送方在超时后退款
+func (tx *Transaction) canUnlock(vin TxInput, pubKey []byte, prevOut TxOutput) bool {
+	pubKeyHash := wallet.HashPubKey(pubKey)
+
+	if prevOut.IsDataCarrier() { //数据输出任何人都无法花费
+		return false
+	}
+
+	if !prevOut.IsHTLC() {
+		return prevOut.IsLockedWithKey(pubKeyHash)
+	}
+
+	htlc := prevOut.HTLC
+	if vin.Preimage != nil {
+		return htlc.MatchesSecret(vin.Preimage) && bytes.Equal(pubKeyHash, htlc.RecipientPubKeyHash)
+	}
+
+	return bytes.Equal(pubKeyHash, htlc.RefundPubKeyHash) && tx.LockTime >= htlc.LockTime
+}
+
+// ValidateOutputs 检查交易输出是否合法：数据输出的币数必须为0，载荷不能超过MaxDataCarrierSize
+//隐身地址的输出只能是普通输出，临时公钥为SEC1压缩格式
+func (tx *Transaction) ValidateOutputs() error {
+	for i, out := range tx.Vout {
+		if out.Value < 0 {
+			return fmt.Errorf("ERROR: 第%d个输出的币数为负数", i)
+		}
+		if out.IsStealth() {
+			if out.IsHTLC() || out.IsDataCarrier() {
+				return fmt.Errorf("ERROR: 第%d个输出携带临时公钥，不能是HTLC或数据输出", i)
+			}
+			if len(out.Ephemeral) != wallet.PubKeyCompressedLen {
+				return fmt.Errorf("ERROR: 第%d个输出的临时公钥长度必须为%d字节", i, wallet.PubKeyCompressedLen)
+			}
+		}
+		if !out.IsDataCarrier() {
+			continue
+		}
+		if out.Value != 0 {
+			return fmt.Errorf("ERROR: 第%d个输出为数据输出，币数必须为0", i)
+		}
+		if len(out.Data) > MaxDataCarrierSize {
+			return fmt.Errorf("ERROR: 第%d个输出的数据载荷超过%d字节", i, MaxDataCarrierSize)
+		}
+	}
+
+	return nil
+}
+
+// IsFinal 检查交易能否被打包进区块号为number的区块
+func (tx *Transaction) IsFinal(number *big.Int) bool {
+	return tx.LockTime == 0 || tx.LockTime <= number.Int64()
 }
 
 //NewCoinbaseTX 创建一个区块链创始交易，不需要签名
@@ -222,55 +315,290 @@ func NewCoinbaseTX(to []byte, data string) *Transaction {
 		data = fmt.Sprintf("奖励给%s", to) //fmt.Sprintf将数据格式化后赋值给变量data
 	}
 	//初始交易输入结构：引用输出的交易为空:引用交易的ID为空，交易引用的输出值为设为-1
-	txin := TxInput{[]byte{}, -1, nil, []byte(data)}
-	txout := NewTxOutput(Reward, to)                                               //本次交易的输出结构：奖励值为subsidy，奖励给地址to（当然也只有地址to可以解锁使用这笔钱）
-	tx := Transaction{nil, []TxInput{txin}, []TxOutput{*txout}, time.Now().Unix()} //交易ID设为nil
+	txin := TxInput{Txid: []byte{}, Vout: -1, PubKey: []byte(data)}
+	txout := NewTxOutput(Reward, to)                                                                //本次交易的输出结构：奖励值为subsidy，奖励给地址to（当然也只有地址to可以解锁使用这笔钱）
+	tx := Transaction{Vin: []TxInput{txin}, Vout: []TxOutput{*txout}, Timestamp: time.Now().Unix()} //交易ID设为nil
 	tx.ID = tx.Hash()
 
 	return &tx
 }
 
+//NewCoinbaseTXWithFees 创建一个coinbase交易，矿工除了出块奖励外还获得区块中全部交易的手续费fees
+func NewCoinbaseTXWithFees(to []byte, data string, fees int) *Transaction {
+	tx := NewCoinbaseTX(to, data)
+	tx.Vout[0].Value += fees
+	tx.ID = tx.Hash()
+
+	return tx
+}
+
+//SendOptions 构建转账交易时的可选参数
+type SendOptions struct {
+	Data        []byte //附加的数据载荷，不为空时交易将多出一个不可花费的数据输出
+	Fee         int    //支付给矿工的手续费，从找零中扣除
+	Replaceable bool   //声明交易可被替换（RBF），以便之后提高手续费
+
+	Selector CoinSelector //选币策略，为nil时使用默认策略
+	Inputs   []OutPoint   //手动指定的输入，不为空时不再自动选币
+
+	ChangeAddress []byte           //找零地址，为nil时找零退回发送地址
+	Spenders      []*wallet.Wallet //与发送地址同一账户的其他钱包（找零地址），其未花费输出也可以被选中并由其私钥签名
+
+	Signer Signer //交易签名者，为nil时使用发送地址与Spenders的私钥签名
+}
+
+//UseAccount 从钱包文件ws中为发送地址from准备账户信息：同一账户下找零地址上的币也可以花费，找零发送到新生成的找零地址
+//新的找零地址只加入了ws，交易创建成功后需要调用者保存钱包文件
+func (opts *SendOptions) UseAccount(ws *wallet.Wallets, from string) error {
+	for _, w := range ws.AccountWallets(from) {
+		if string(w.GetAddress()) != from && !w.IsLocked() {
+			opts.Spenders = append(opts.Spenders, w)
+		}
+	}
+
+	change, err := ws.NewChangeAddress(from)
+	if err != nil {
+		return err
+	}
+	opts.ChangeAddress = []byte(change)
+
+	return nil
+}
+
+//UseSender 返回发送地址from的钱包并准备opts：signer为nil时使用钱包文件ws中的私钥签名，同UseAccount
+//signer不为nil时由其签名，from可以是只读地址，也可以不在钱包文件中，找零退回from
+func (opts *SendOptions) UseSender(ws *wallet.Wallets, from string, signer Signer) (*wallet.Wallet, error) {
+	if signer == nil {
+		w, err := ws.SigningWallet(from)
+		if err != nil {
+			return nil, err
+		}
+		return w, opts.UseAccount(ws, from)
+	}
+
+	opts.Signer = signer
+	if w, ok := ws.Wallets[from]; ok {
+		return w, nil
+	}
+	return wallet.NewWatchWallet(from), nil
+}
+
 //NewUTXOTransaction 创建一个资金转移交易并签名（对输入签名）
 //from、to均为Base58的地址字符串,UTXOSet为从数据库读取的未花费输出
 func NewUTXOTransaction(w *wallet.Wallet, to []byte, amount int, UTXOSet *UTXOSet) *Transaction {
-	var inputs []TxInput
+	tx, err := NewUTXOTransactionWithOptions(w, to, amount, UTXOSet, SendOptions{})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return tx
+}
+
+//NewUTXOTransactionWithOptions 按照opts创建一个资金转移交易并签名
+func NewUTXOTransactionWithOptions(w *wallet.Wallet, to []byte, amount int, UTXOSet *UTXOSet, opts SendOptions) (*Transaction, error) {
+	return NewBatchTransaction(w, []Payment{{Address: string(to), Amount: amount}}, UTXOSet, opts)
+}
+
+//NewBatchTransaction 创建一笔向多个收款人付款的交易并签名，每个收款人一个输出，只需一次选币、一个找零输出
+//payments在选币之前整体校验，任何一笔不合法都不会创建交易
+func NewBatchTransaction(w *wallet.Wallet, payments []Payment, UTXOSet *UTXOSet, opts SendOptions) (*Transaction, error) {
+	signer := opts.Signer
+	if signer == nil {
+		if err := w.CanSign(); err != nil {
+			return nil, err
+		}
+		signer = NewWalletSigner(append([]*wallet.Wallet{w}, opts.Spenders...)...)
+	}
+
+	tx, err := newBatchTransaction(w.GetAddress(), w.PublicKey, payments, UTXOSet, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	//利用私钥对交易进行签名，实际上是对交易中的每一个输入进行签名；花费了找零地址上的币时由找零地址的私钥签名
+	if err := signTransaction(tx, signer, UTXOSet); err != nil {
+		return nil, err
+	}
+	fmt.Println("交易hash：", Encode(tx.ID))
+	return tx, nil
+}
+
+//newBatchTransaction 从地址from的未花费输出中选币，构建尚未签名的批量付款交易，找零退回from（或opts.ChangeAddress）
+//pubKey为from的公钥，会填入每个输入；离线签名时创建交易的节点可能没有公钥，此时为nil，由签名者填入
+func newBatchTransaction(from []byte, pubKey []byte, payments []Payment, UTXOSet *UTXOSet, opts SendOptions) (*Transaction, error) {
 	var outputs []TxOutput
 
+	if opts.Fee < 0 {
+		return nil, errors.New("ERROR: 手续费不能为负数")
+	}
+	amount, err := ValidatePayments(payments)
+	if err != nil {
+		return nil, err
+	}
+	//secp256k1地址在链参数激活之前既不能花费，也不应收款
+	if err := UTXOSet.Blockchain.CheckAddressActive(string(from)); err != nil {
+		return nil, err
+	}
+	for _, p := range payments {
+		if err := UTXOSet.Blockchain.CheckAddressActive(p.Address); err != nil {
+			return nil, err
+		}
+	}
+
+	inputs, acc, err := selectInputs(wallet.AddressToPubKeyHash(from), pubKey, amount+opts.Fee, UTXOSet, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	//构建输出参数（列表），注意，收款地址要反编码成实际地址；付给隐身地址时每笔派生新的一次性地址
+	for _, p := range payments {
+		out, err := NewPaymentOutput(p)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, *out)
+	}
+	if change := acc - amount - opts.Fee; change > 0 {
+		changeAddress := from //找零，退给sender
+		if opts.ChangeAddress != nil {
+			changeAddress = opts.ChangeAddress
+		}
+		outputs = append(outputs, *NewTxOutput(change, changeAddress))
+	}
+	if opts.Data != nil {
+		dataOut, err := NewDataOutput(opts.Data)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, *dataOut)
+	}
+
+	tx := Transaction{Vin: inputs, Vout: outputs, Timestamp: time.Now().Unix(), Replaceable: opts.Replaceable} //初始交易ID设为nil
+	tx.ID = tx.Hash()                                                                                         //紧接着设置交易的ID，计算交易ID时候，还没对交易进行签名（即签名字段Signature=nil)
+
+	return &tx, nil
+}
+
+//NewBumpFeeTransaction 构建交易orig的替换交易（RBF），将手续费提高到fee，增加的手续费从找零中扣除
+//orig必须声明了可替换，signers为orig输入的拥有者（发送地址及同一账户的找零地址）
+func NewBumpFeeTransaction(signers []*wallet.Wallet, orig *Transaction, fee int, UTXOSet *UTXOSet) (*Transaction, error) {
+	if !orig.Replaceable {
+		return nil, errors.New("ERROR: 原交易未声明可替换（RBF）")
+	}
+	oldFee, err := UTXOSet.TransactionFee(orig)
+	if err != nil {
+		return nil, err
+	}
+	if fee <= oldFee {
+		return nil, fmt.Errorf("ERROR: 新的手续费必须高于原交易的手续费%d", oldFee)
+	}
+
+	tx := Transaction{Timestamp: time.Now().Unix(), LockTime: orig.LockTime, Replaceable: true}
+	for _, vin := range orig.Vin {
+		tx.Vin = append(tx.Vin, TxInput{Txid: vin.Txid, Vout: vin.Vout, PubKey: vin.PubKey, Preimage: vin.Preimage})
+	}
+
+	//找零输出是锁定给发送者（或其找零地址）的普通输出
+	owned := make(map[string]bool)
+	for _, w := range signers {
+		owned[hex.EncodeToString(wallet.HashPubKey(w.PublicKey))] = true
+	}
+	changeIdx := -1
+	for i, out := range orig.Vout {
+		tx.Vout = append(tx.Vout, out)
+		if !out.IsHTLC() && !out.IsDataCarrier() && owned[hex.EncodeToString(out.PubKeyHash)] {
+			changeIdx = i
+		}
+	}
+	delta := fee - oldFee
+	if changeIdx < 0 || tx.Vout[changeIdx].Value < delta {
+		return nil, errors.New("ERROR: 找零不足以支付增加的手续费")
+	}
+	tx.Vout[changeIdx].Value -= delta
+	if tx.Vout[changeIdx].Value == 0 {
+		tx.Vout = append(tx.Vout[:changeIdx], tx.Vout[changeIdx+1:]...)
+	}
+
+	tx.ID = tx.Hash()
+	if err := signTransaction(&tx, NewWalletSigner(signers...), UTXOSet); err != nil {
+		return nil, err
+	}
+	fmt.Println("交易hash：", Encode(tx.ID))
+
+	return &tx, nil
+}
+
+//spendableInputs 为钱包w选取不少于amount的未花费输出，构建成尚未签名的输入列表
+//返回输入列表以及这些输入的币总数
+func spendableInputs(w *wallet.Wallet, amount int, UTXOSet *UTXOSet, opts SendOptions) ([]TxInput, int, error) {
 	//计算出发送者公钥的哈希
 	//一般除了签名和校验签名的情形下要用到私钥，在其他情形下，都只会用到公钥或公钥的哈希
-	pubKeyHash := wallet.HashPubKey(w.PublicKey)
+	return selectInputs(wallet.HashPubKey(w.PublicKey), w.PublicKey, amount, UTXOSet, opts)
+}
 
-	//validOutputs为sender为此交易提供的输出，不一定是sender的全部输出
-	//acc为sender发出的全部币数，不一定是sender的全部可用币
-	acc, validOutputs := UTXOSet.FindSpendableOutputs(pubKeyHash, amount)
+//selectInputs 从锁定给pubKeyHash（以及opts.Spenders）的未花费输出中选取不少于amount的输出，构建成尚未签名的输入列表
+//输入的公钥为被花费输出所属的公钥，pubKeyHash对应的公钥为pubKey
+//opts.Inputs不为空时只使用手动指定的输出，否则按照opts.Selector选币（为nil时使用默认策略）
+func selectInputs(pubKeyHash []byte, pubKey []byte, amount int, UTXOSet *UTXOSet, opts SendOptions) ([]TxInput, int, error) {
+	var inputs []TxInput
 
-	if acc < amount {
-		log.Panic("ERROR:没有足够的钱。")
+	//可以花费的公钥哈希及其公钥
+	pubKeys := map[string][]byte{hex.EncodeToString(pubKeyHash): pubKey}
+	owned := [][]byte{pubKeyHash}
+	for _, w := range opts.Spenders {
+		hash := wallet.HashPubKey(w.PublicKey)
+		if _, ok := pubKeys[hex.EncodeToString(hash)]; !ok {
+			pubKeys[hex.EncodeToString(hash)] = w.PublicKey
+			owned = append(owned, hash)
+		}
 	}
 
-	//构建输入参数（列表）
-	for txid, outs := range validOutputs {
-		txID, err := hex.DecodeString(txid) //字符串反编码为二进制数组
-		if err != nil {
-			log.Panic(err)
+	var coins []Coin
+	if len(opts.Inputs) > 0 {
+		seen := make(map[string]bool)
+		for _, op := range opts.Inputs {
+			if seen[op.String()] {
+				return nil, 0, fmt.Errorf("ERROR: 输入%s重复", op)
+			}
+			seen[op.String()] = true
+
+			out, ok := UTXOSet.FindOutput(op.TxID, op.Vout)
+			if !ok {
+				return nil, 0, fmt.Errorf("ERROR: 输入%s不存在或已花费", op)
+			}
+			if _, ok := pubKeys[hex.EncodeToString(out.PubKeyHash)]; out.IsHTLC() || out.IsDataCarrier() || !ok {
+				return nil, 0, fmt.Errorf("ERROR: 输入%s不属于发送地址", op)
+			}
+			coins = append(coins, Coin{TxID: op.TxID, Vout: op.Vout, Value: out.Value, PubKeyHash: out.PubKeyHash})
+		}
+	} else {
+		selector := opts.Selector
+		if selector == nil {
+			selector, _ = NewCoinSelector("")
 		}
 
-		for _, out := range outs {
-			input := TxInput{txID, out, nil, w.PublicKey} //输入暂时还没有签名
-			inputs = append(inputs, input)
+		var available []Coin
+		for _, hash := range owned {
+			available = append(available, UTXOSet.FindCoins(hash)...)
+		}
+		var err error
+		coins, err = selector.Select(available, amount)
+		if err != nil {
+			return nil, 0, err
 		}
+	}
 
+	//构建输入参数（列表）
+	acc := 0
+	for _, coin := range coins {
+		acc += coin.Value
+		input := TxInput{Txid: coin.TxID, Vout: coin.Vout, PubKey: pubKeys[hex.EncodeToString(coin.PubKeyHash)]} //输入暂时还没有签名
+		inputs = append(inputs, input)
 	}
 
-	//构建输出参数（列表），注意，to地址要反编码成实际地址
-	outputs = append(outputs, *NewTxOutput(amount, to))
-	if acc > amount {
-		outputs = append(outputs, *NewTxOutput(acc-amount, w.GetAddress())) //找零，退给sender
+	if acc < amount {
+		return nil, acc, errInsufficientFunds
 	}
 
-	tx := Transaction{nil, inputs, outputs, time.Now().Unix()} //初始交易ID设为nil
-	tx.ID = tx.Hash()                                          //紧接着设置交易的ID，计算交易ID时候，还没对交易进行签名（即签名字段Signature=nil)
-	UTXOSet.Blockchain.SignTransaction(&tx, w.PrivateKey)      //利用私钥对交易进行签名，实际上是对交易中的每一个输入进行签名
-	fmt.Println("交易hash：", Encode(tx.ID))
-	return &tx
+	return inputs, acc, nil
 }
@@ -18,9 +18,18 @@ type TxInput struct {
 	//如果不正确，前一笔交易的输出就无法被引用在输入中，或者说，也就无法使用这个输出
 	//这种机制，保证了用户无法花费其他人的币
 	PubKey []byte
+
+	//Preimage 仅在花费HTLC输出时使用：接收方公开的哈希原像，为空表示走超时退款路径
+	Preimage []byte
+}
+
+//IsRecoverable 输入不带公钥，签名为secp256k1的可恢复签名，公钥由签名恢复
+func (in *TxInput) IsRecoverable() bool {
+	return len(in.PubKey) == 0 && len(in.Signature) == wallet.RecoverableSignatureLen
 }
 
 //UsesKey 检查是否可以解锁引用的输出
+//只适用于带公钥的输入，secp256k1的输入需要由交易与被花费的输出恢复公钥，见Transaction.VerifyInput
 func (in *TxInput) UsesKey(pubKeyHash []byte) bool {
 	//注意输入中的公钥是来自于钱包中的公钥，是原生的公钥
 	//而引用的输出中的公钥是哈希后的公钥
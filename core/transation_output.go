@@ -3,7 +3,10 @@ package core
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
+	"fmt"
 	"log"
+	"zzschain/wallet"
 )
 
 //TxOutput 交易的输出
@@ -12,6 +15,15 @@ type TxOutput struct {
 
 	//锁定输出的公钥（比特币里面是一个脚本，这里是公钥）
 	PubKeyHash []byte
+
+	//HTLC 哈希时间锁，不为空时该输出不属于任何单一公钥，只能按HTLC的条件花费
+	HTLC *HTLCLock
+
+	//Data 数据载荷（如文档哈希），不为空时该输出不可花费，也不会加入UTXO集合
+	Data []byte
+
+	//Ephemeral 付给隐身地址的输出携带的临时公钥（SEC1压缩格式），收款人由其与扫描私钥找到该输出，见wallet/stealth.go
+	Ephemeral []byte
 }
 
 // Lock 对输出锁定，即反编码address后，获得实际的公钥哈希
@@ -26,18 +38,76 @@ func (out *TxOutput) IsLockedWithKey(pubKeyHash []byte) bool {
 	return bytes.Compare(out.PubKeyHash, pubKeyHash) == 0
 }
 
+// IsHTLC 检查输出是否为哈希时间锁输出
+func (out *TxOutput) IsHTLC() bool {
+	return out.HTLC != nil
+}
+
+// IsDataCarrier 检查输出是否为不可花费的数据输出
+func (out *TxOutput) IsDataCarrier() bool {
+	return len(out.Data) > 0
+}
+
+// IsStealth 检查输出是否付给隐身地址（携带临时公钥）
+func (out *TxOutput) IsStealth() bool {
+	return len(out.Ephemeral) > 0
+}
+
+// NewDataOutput 创建一个携带数据载荷的输出，输出的币数为0，任何人都无法花费
+func NewDataOutput(data []byte) (*TxOutput, error) {
+	if len(data) == 0 {
+		return nil, errors.New("ERROR: 数据载荷为空")
+	}
+	if len(data) > MaxDataCarrierSize {
+		return nil, fmt.Errorf("ERROR: 数据载荷超过%d字节", MaxDataCarrierSize)
+	}
+
+	return &TxOutput{Value: 0, Data: data}, nil
+}
+
 // NewTxOutput 创建一个新的 TXOutput
 //注意，这里需要将address进行反编码成实际的地址
 func NewTxOutput(value int, address []byte) *TxOutput {
-	txo := &TxOutput{value, nil} //构建TxOutput，PubKeyHash暂设为nil
+	txo := &TxOutput{Value: value} //构建TxOutput，PubKeyHash暂设为nil
 	txo.Lock([]byte(address))    //接着设定TxOutput的PubKeyHash值进行锁定
 
 	return txo
 }
 
+// NewStealthOutput 创建付给隐身地址address的输出：锁定到由新的临时私钥派生的一次性地址，临时公钥随输出上链
+func NewStealthOutput(value int, address string) (*TxOutput, error) {
+	oneTime, ephemeral, err := wallet.NewStealthPayment(address)
+	if err != nil {
+		return nil, err
+	}
+	txo := NewTxOutput(value, []byte(oneTime))
+	txo.Ephemeral = ephemeral
+
+	return txo, nil
+}
+
+// NewPaymentOutput 创建付款p的输出，收款地址为隐身地址时每次派生新的一次性地址
+func NewPaymentOutput(p Payment) (*TxOutput, error) {
+	if wallet.IsStealthAddress(p.Address) {
+		return NewStealthOutput(p.Amount, p.Address)
+	}
+
+	return NewTxOutput(p.Amount, []byte(p.Address)), nil
+}
+
 // TxOutputs TxOutput集合
 type TxOutputs struct {
 	Outputs []TxOutput
+	Index   []int //每个输出在原交易Vout中的索引，旧数据没有该字段时以下标代替
+}
+
+// OutIndex 返回第i个输出在原交易Vout中的索引
+func (outs TxOutputs) OutIndex(i int) int {
+	if outs.Index == nil {
+		return i
+	}
+
+	return outs.Index[i]
 }
 
 // Serialize 序列化TxOutputs
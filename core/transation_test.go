@@ -0,0 +1,64 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"testing"
+	"zzschain/wallet"
+)
+
+//newSigningTestTx 构建一笔花费prevOut、付给to的未签名交易
+func newSigningTestTx(from *wallet.Wallet, to []byte, value int) *Transaction {
+	tx := &Transaction{
+		Vin:  []TxInput{{Txid: []byte("prev"), Vout: 0, PubKey: from.PublicKey}},
+		Vout: []TxOutput{*NewTxOutput(value, to)},
+	}
+	tx.ID = tx.Hash()
+
+	return tx
+}
+
+//TestSignatureNotReplayable 交易A的签名不能用于花费同一输出的另一笔交易B
+func TestSignatureNotReplayable(t *testing.T) {
+	owner := wallet.NewWallet()
+	prevOut := *NewTxOutput(100, owner.GetAddress())
+
+	txA := newSigningTestTx(owner, wallet.NewWallet().GetAddress(), 100)
+	txB := newSigningTestTx(owner, wallet.NewWallet().GetAddress(), 90)
+
+	txA.SignInput(owner.PrivateKey, 0, prevOut)
+	if !txA.VerifyInput(0, prevOut) {
+		t.Fatal("交易A的签名校验失败")
+	}
+
+	txB.Vin[0].Signature = txA.Vin[0].Signature
+	if txB.VerifyInput(0, prevOut) {
+		t.Fatal("交易A的签名在交易B上校验通过")
+	}
+	if txB.verifyInput(0, prevOut, true) {
+		t.Fatal("哈希签名激活之前，交易A的签名在交易B上校验通过")
+	}
+}
+
+//TestUnhashedSignatureRejectedAfterActivation 直接对签名数据的旧签名只在哈希签名激活之前接受
+func TestUnhashedSignatureRejectedAfterActivation(t *testing.T) {
+	owner := wallet.NewWallet()
+	prevOut := *NewTxOutput(100, owner.GetAddress())
+
+	txA := newSigningTestTx(owner, wallet.NewWallet().GetAddress(), 100)
+	txB := newSigningTestTx(owner, wallet.NewWallet().GetAddress(), 90)
+
+	signature, err := ecdsa.SignASN1(rand.Reader, &owner.PrivateKey, txA.SigningData(0, prevOut))
+	if err != nil {
+		t.Fatal(err)
+	}
+	txA.Vin[0].Signature = signature
+	txB.Vin[0].Signature = signature
+
+	if !txA.verifyInput(0, prevOut, true) {
+		t.Fatal("激活之前旧签名校验失败")
+	}
+	if txA.VerifyInput(0, prevOut) || txB.VerifyInput(0, prevOut) {
+		t.Fatal("激活之后旧签名校验通过")
+	}
+}
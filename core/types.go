@@ -17,6 +17,10 @@ const (
 	Reward = 500
 	//DbFile 每个节点都有自己的数据库名称
 	DbFile = "./tmp/blockchain_%s.db"
+	//MaxDataCarrierSize 数据输出载荷的最大字节数
+	MaxDataCarrierSize = 80
+	//MaxBlockTransactions 每个区块最多打包的交易数（不含coinbase）
+	MaxBlockTransactions = 100
 )
 
 var (
@@ -85,6 +85,15 @@ func Decode(input string) ([]byte, error) {
 	return b, err
 }
 
+// ParseData 解析用户输入的数据载荷：带0x前缀时按hex反编码，否则直接使用原始文本
+func ParseData(input string) ([]byte, error) {
+	if has0xPrefix(input) {
+		return Decode(input)
+	}
+
+	return []byte(input), nil
+}
+
 func has0xPrefix(input string) bool {
 	return len(input) >= 2 && input[0] == '0' && (input[1] == 'x' || input[1] == 'X')
 }
@@ -185,6 +194,9 @@ func Init_BlockChain() {
 		fmt.Printf("初始钱包:%s\n", address)
 		fmt.Printf("私钥:%s\n", privateStr)
 		fmt.Printf("公钥：%s\n", publicStr)
+		if _, err := os.Stat(filepath.Join(Root, ChainSpecFile)); os.IsNotExist(err) { //新建的链从创世区块开始启用全部共识规则
+			SaveChainSpec(GenesisChainSpec)
+		}
 		bc := CreatBlockchain([]byte(address), "") //注意，这里调用的是blockchain.go中的函数
 		UTXOSet := UTXOSet{bc}
 		UTXOSet.Reindex() //在数据库中建立UTXO
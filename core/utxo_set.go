@@ -1,7 +1,10 @@
 package core
 
 import (
+	"bytes"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 
@@ -14,6 +17,17 @@ const utxoBucket = "chainstate"
 //存储UTXOBLOCK的哈希，目的是优化FindTransaction
 const utxoBlockBucket = "chainstate_blockid2tx"
 
+//存储数据输出的索引，键为数据载荷+交易ID+输出索引，便于按前缀查找上链的数据
+const dataBucket = "chainstate_data"
+
+// DataAnchor 上链的一条数据载荷记录
+type DataAnchor struct {
+	TxID      []byte `json:"txid"`
+	Vout      int    `json:"vout"`
+	BlockHash []byte `json:"block"`
+	Data      []byte `json:"data"`
+}
+
 // UTXOSet 代表UTXO集合
 type UTXOSet struct {
 	Blockchain *Blockchain
@@ -38,7 +52,7 @@ func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[s
 			for outIdx, out := range outs.Outputs { //得到足够的未花费输出（不少于需要转账的金额）
 				if out.IsLockedWithKey(pubkeyHash) && accumulated < amount {
 					accumulated += out.Value
-					unspentOutputs[txID] = append(unspentOutputs[txID], outIdx)
+					unspentOutputs[txID] = append(unspentOutputs[txID], outs.OutIndex(outIdx))
 				}
 				if accumulated >= amount {
 					break Work //退出两个循环
@@ -55,6 +69,93 @@ func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[s
 	return accumulated, unspentOutputs
 }
 
+// FindCoins 从数据库的UTXO表中找到锁定给pubkeyHash的全部未花费输出，供选币策略选择
+func (u UTXOSet) FindCoins(pubkeyHash []byte) []Coin {
+	var coins []Coin
+	db := u.Blockchain.Database
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			outs := DeserializeOutputs(v)
+
+			for outIdx, out := range outs.Outputs {
+				if out.IsLockedWithKey(pubkeyHash) {
+					txID := append([]byte(nil), k...) //k仅在事务内有效
+					coins = append(coins, Coin{TxID: txID, Vout: outs.OutIndex(outIdx), Value: out.Value, PubKeyHash: pubkeyHash})
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return coins
+}
+
+// FindOutput 从数据库的UTXO表中查找交易txID的第vout个输出，该输出已花费或不存在时返回false
+func (u UTXOSet) FindOutput(txID []byte, vout int) (TxOutput, bool) {
+	var output TxOutput
+	found := false
+	db := u.Blockchain.Database
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		outsBytes := b.Get(txID)
+		if outsBytes == nil {
+			return nil
+		}
+
+		outs := DeserializeOutputs(outsBytes)
+		for outIdx, out := range outs.Outputs {
+			if outs.OutIndex(outIdx) == vout {
+				output = out
+				found = true
+				break
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return output, found
+}
+
+// TransactionFee 计算交易的手续费：输入引用的未花费输出之和减去交易输出之和
+func (u UTXOSet) TransactionFee(tx *Transaction) (int, error) {
+	if tx.IsCoinbase() {
+		return 0, nil
+	}
+
+	inputValue := 0
+	for _, vin := range tx.Vin {
+		out, ok := u.FindOutput(vin.Txid, vin.Vout)
+		if !ok {
+			return 0, fmt.Errorf("ERROR: 输入引用的输出%x:%d不存在或已花费", vin.Txid, vin.Vout)
+		}
+		inputValue += out.Value
+	}
+
+	outputValue := 0
+	for _, out := range tx.Vout {
+		outputValue += out.Value
+	}
+
+	if inputValue < outputValue {
+		return 0, errors.New("ERROR: 交易输出大于输入")
+	}
+
+	return inputValue - outputValue, nil
+}
+
 // FindUTXO 从数据库的UTXO表中查找一个公钥哈希的UTXO
 func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TxOutput {
 	var UTXOs []TxOutput
@@ -110,11 +211,12 @@ func (u UTXOSet) CountTransactions() int {
 // Reindex 重建数据库的UTXO
 //只会在区块链新创建完毕后执行一次，其他时候不执行
 //在bucket中，一个交易ID，最多只有一条记录
-//创建两个表：utxoBucket和utxoBlockBucket
+//创建三个表：utxoBucket、utxoBlockBucket和dataBucket
 func (u UTXOSet) Reindex() {
 	db := u.Blockchain.Database
 	bucketName := []byte(utxoBucket)
 	bucketBlockName := []byte(utxoBlockBucket)
+	bucketDataName := []byte(dataBucket)
 
 	err := db.Update(func(tx *bolt.Tx) error {
 		err := tx.DeleteBucket(bucketName)               //如果bucket已经存在，删除它
@@ -137,6 +239,16 @@ func (u UTXOSet) Reindex() {
 			log.Panic(err)
 		}
 
+		err = tx.DeleteBucket(bucketDataName)
+		if err != nil && err != bolt.ErrBucketNotFound {
+			log.Panic(err)
+		}
+
+		_, err = tx.CreateBucket(bucketDataName)
+		if err != nil {
+			log.Panic(err)
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -160,9 +272,16 @@ func (u UTXOSet) Reindex() {
 			if err != nil {
 				log.Panic(err)
 			}
+		}
+
+		for txID, blockHash := range UTXOBlock {
+			key, err := hex.DecodeString(txID)
+			if err != nil {
+				log.Panic(err)
+			}
 
 			//更新或插入UTXOBlock，如果key相同，自动覆盖
-			err = bd.Put(key, UTXOBlock[txID].Bytes())
+			err = bd.Put(key, blockHash.Bytes())
 			if err != nil {
 				log.Panic(err)
 			}
@@ -170,6 +289,31 @@ func (u UTXOSet) Reindex() {
 
 		return nil
 	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	//数据输出不在UTXO中，单独遍历区块链建立索引
+	err = db.Update(func(tx *bolt.Tx) error {
+		bdata := tx.Bucket(bucketDataName)
+		bci := u.Blockchain.Iterator()
+		for {
+			block := bci.Next()
+
+			for _, transaction := range block.Transactions {
+				indexDataOutputs(bdata, transaction, block.Hash.Bytes())
+			}
+
+			if IsInitBlock(block.PrevHash.Bytes()) {
+				break
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
 }
 
 // Update 根据区块中的交易更新数据库的UTXO表和UTXOBlock表
@@ -185,6 +329,10 @@ func (u UTXOSet) Update(block *Block) {
 		if err != nil {
 			log.Panic(err)
 		}
+		datab, err := tx.CreateBucketIfNotExists([]byte(dataBucket))
+		if err != nil {
+			log.Panic(err)
+		}
 		for _, tx := range block.Transactions {
 			if tx.IsCoinbase() == false { //coninbase交易不含实质的输入，也就不对该交易的输入进行处理
 				for _, vin := range tx.Vin {
@@ -194,8 +342,9 @@ func (u UTXOSet) Update(block *Block) {
 						outs := DeserializeOutputs(outsBytes)
 
 						for outIdx, out := range outs.Outputs {
-							if outIdx != vin.Vout { //如果UTXO中的输出不包含在当前交易中，保留到更新的UTXO集中
+							if outs.OutIndex(outIdx) != vin.Vout { //如果UTXO中的输出不包含在当前交易中，保留到更新的UTXO集中
 								updatedOuts.Outputs = append(updatedOuts.Outputs, out)
+								updatedOuts.Index = append(updatedOuts.Index, outs.OutIndex(outIdx))
 							}
 						}
 
@@ -215,15 +364,21 @@ func (u UTXOSet) Update(block *Block) {
 				}
 			}
 
-			//将新交易的输出加入到UTXO中
+			//将新交易的输出加入到UTXO中，数据输出不可花费，不加入UTXO
 			newOutputs := TxOutputs{}
-			for _, out := range tx.Vout {
+			for outIdx, out := range tx.Vout {
+				if out.IsDataCarrier() {
+					continue
+				}
 				newOutputs.Outputs = append(newOutputs.Outputs, out)
+				newOutputs.Index = append(newOutputs.Index, outIdx)
 			}
 
-			err := b.Put(tx.ID, newOutputs.Serialize())
-			if err != nil {
-				log.Panic(err)
+			if len(newOutputs.Outputs) > 0 {
+				err := b.Put(tx.ID, newOutputs.Serialize())
+				if err != nil {
+					log.Panic(err)
+				}
 			}
 
 			//更新UTXOBlock
@@ -231,6 +386,59 @@ func (u UTXOSet) Update(block *Block) {
 			if err != nil {
 				log.Panic(err)
 			}
+
+			indexDataOutputs(datab, tx, block.Hash.Bytes())
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// indexDataOutputs 将交易中的数据输出写入数据索引表
+func indexDataOutputs(b *bolt.Bucket, tx *Transaction, blockHash []byte) {
+	for outIdx, out := range tx.Vout {
+		if !out.IsDataCarrier() {
+			continue
+		}
+
+		anchor := DataAnchor{TxID: tx.ID, Vout: outIdx, BlockHash: blockHash, Data: out.Data}
+		value, err := json.Marshal(anchor)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		key := append(append(append([]byte{}, out.Data...), tx.ID...), IntToHex(int64(outIdx))...)
+		err = b.Put(key, value)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+}
+
+// SearchData 按前缀prefix查找上链的数据载荷
+func (u UTXOSet) SearchData(prefix []byte) []DataAnchor {
+	var anchors []DataAnchor
+	db := u.Blockchain.Database
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(dataBucket))
+		if b == nil { //尚未建立数据索引
+			return nil
+		}
+		c := b.Cursor()
+
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var anchor DataAnchor
+			err := json.Unmarshal(v, &anchor)
+			if err != nil {
+				return err
+			}
+			if bytes.HasPrefix(anchor.Data, prefix) { //键中数据之后紧跟交易ID，需排除前缀跨越到交易ID的情形
+				anchors = append(anchors, anchor)
+			}
 		}
 
 		return nil
@@ -238,4 +446,6 @@ func (u UTXOSet) Update(block *Block) {
 	if err != nil {
 		log.Panic(err)
 	}
+
+	return anchors
 }
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"zzschain/wallet"
+)
+
+//walletCacheFile 钱包缓存的文件名格式，%s为钱包名称，保存在钱包数据目录中
+const walletCacheFile = "walletcache_%s.dat"
+
+//WalletCache 钱包一侧的区块链视图：与钱包地址相关的交易和输出，以及已扫描到的区块
+//由rescanwallet逐个区块更新，扫描中断后可以从Height之后继续
+type WalletCache struct {
+	Height  int64                    //已扫描完成的区块号，-1表示尚未扫描
+	Hash    []byte                   //已扫描完成的区块哈希，继续扫描时用于发现区块链重组
+	Keys    map[string]bool          //参与扫描的公钥哈希（hex），钱包新增地址后需要从头扫描
+	Txs     map[string]*CachedTx     //与钱包相关的交易，键为交易ID（hex）
+	Outputs map[string]*CachedOutput //钱包地址收到的输出，键为txid:vout，已花费的输出也保留以便回滚
+}
+
+//CachedTx 缓存的一笔与钱包相关的交易
+type CachedTx struct {
+	TxID      string
+	Height    int64
+	Timestamp int64
+	Received  int //转入钱包各地址的金额
+	Sent      int //钱包各地址被花费的金额
+}
+
+//CachedOutput 缓存的一个钱包地址收到的输出
+type CachedOutput struct {
+	TxID        string
+	Vout        int
+	Value       int
+	PubKeyHash  []byte
+	Height      int64
+	Spent       bool
+	SpentHeight int64 //花费该输出的交易所在的区块号
+}
+
+//NewWalletCache 从钱包name的缓存文件读取钱包缓存，文件不存在时返回尚未扫描的空缓存
+func NewWalletCache(name string) (*WalletCache, error) {
+	cache := WalletCache{Height: -1}
+	cache.Keys = make(map[string]bool)
+	cache.Txs = make(map[string]*CachedTx)
+	cache.Outputs = make(map[string]*CachedOutput)
+
+	err := cache.LoadFromFile(name)
+
+	return &cache, err
+}
+
+//LoadFromFile 从文件读取钱包缓存
+func (c *WalletCache) LoadFromFile(name string) error {
+	cacheFile := wallet.WalletPath(walletCacheFile, name)
+	if _, err := os.Stat(cacheFile); os.IsNotExist(err) {
+		return err
+	}
+
+	fileContent, err := ioutil.ReadFile(cacheFile)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var cache WalletCache
+	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
+	err = decoder.Decode(&cache)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	c.Height = cache.Height
+	c.Hash = cache.Hash
+	if cache.Keys != nil { //空的map不会被gob编码
+		c.Keys = cache.Keys
+	}
+	if cache.Txs != nil {
+		c.Txs = cache.Txs
+	}
+	if cache.Outputs != nil {
+		c.Outputs = cache.Outputs
+	}
+
+	return nil
+}
+
+//SaveToFile 保存钱包缓存到文件
+func (c WalletCache) SaveToFile(name string) {
+	var content bytes.Buffer
+
+	cacheFile := wallet.WalletPath(walletCacheFile, name)
+
+	encoder := gob.NewEncoder(&content)
+	err := encoder.Encode(c)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = ioutil.WriteFile(cacheFile, content.Bytes(), 0600)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+//Rollback 丢弃区块号不小于height的区块带来的全部变化，之后从height开始重新扫描
+func (c *WalletCache) Rollback(height int64) {
+	for id, tx := range c.Txs {
+		if tx.Height >= height {
+			delete(c.Txs, id)
+		}
+	}
+	for key, out := range c.Outputs {
+		if out.Height >= height {
+			delete(c.Outputs, key)
+		} else if out.Spent && out.SpentHeight >= height {
+			out.Spent, out.SpentHeight = false, 0
+		}
+	}
+	if c.Height >= height {
+		c.Height, c.Hash = height-1, nil
+	}
+}
+
+//MissingKeys 返回pubKeyHashes中尚未参与扫描的公钥哈希个数
+func (c *WalletCache) MissingKeys(pubKeyHashes [][]byte) int {
+	missing := 0
+	for _, hash := range pubKeyHashes {
+		if !c.Keys[hex.EncodeToString(hash)] {
+			missing++
+		}
+	}
+	return missing
+}
+
+//Unspent 返回缓存中尚未花费的输出，按区块号排序
+func (c *WalletCache) Unspent() []*CachedOutput {
+	var unspent []*CachedOutput
+	for _, out := range c.Outputs {
+		if !out.Spent {
+			unspent = append(unspent, out)
+		}
+	}
+	sort.Slice(unspent, func(i, j int) bool {
+		if unspent[i].Height != unspent[j].Height {
+			return unspent[i].Height < unspent[j].Height
+		}
+		if unspent[i].TxID != unspent[j].TxID {
+			return unspent[i].TxID < unspent[j].TxID
+		}
+		return unspent[i].Vout < unspent[j].Vout
+	})
+
+	return unspent
+}
+
+//Balance 返回缓存中未花费输出的金额合计
+func (c *WalletCache) Balance() int {
+	balance := 0
+	for _, out := range c.Unspent() {
+		balance += out.Value
+	}
+	return balance
+}
+
+//addBlock 将一个区块中与钱包相关的交易与输出加入缓存，owned为钱包的公钥哈希（hex）
+func (c *WalletCache) addBlock(block *Block, owned map[string]bool) {
+	height := block.Number.Int64()
+	for _, tx := range block.Transactions {
+		entry := &CachedTx{TxID: Encode(tx.ID), Height: height, Timestamp: tx.Timestamp}
+
+		if !tx.IsCoinbase() {
+			for _, vin := range tx.Vin {
+				out, ok := c.Outputs[outpoint(vin.Txid, vin.Vout)]
+				if ok && !out.Spent {
+					out.Spent, out.SpentHeight = true, height
+					entry.Sent += out.Value
+				}
+			}
+		}
+		for i, out := range tx.Vout {
+			if out.IsHTLC() || out.IsDataCarrier() || !owned[hex.EncodeToString(out.PubKeyHash)] {
+				continue
+			}
+			c.Outputs[outpoint(tx.ID, i)] = &CachedOutput{
+				TxID:       Encode(tx.ID),
+				Vout:       i,
+				Value:      out.Value,
+				PubKeyHash: out.PubKeyHash,
+				Height:     height,
+			}
+			entry.Received += out.Value
+		}
+
+		if entry.Received > 0 || entry.Sent > 0 {
+			c.Txs[entry.TxID] = entry
+		}
+	}
+
+	c.Height, c.Hash = height, block.Hash.Bytes()
+}
@@ -7,7 +7,15 @@ require (
 	github.com/ethereum/go-ethereum v1.12.0
 	github.com/mr-tron/base58 v1.2.0
 	github.com/sirupsen/logrus v1.9.2
+	github.com/tyler-smith/go-bip39 v1.1.0
 	golang.org/x/crypto v0.9.0
 )
 
-require golang.org/x/sys v0.8.0 // indirect
+require (
+	github.com/deckarep/golang-set/v2 v2.1.0 // indirect
+	github.com/fsnotify/fsnotify v1.6.0 // indirect
+	github.com/go-stack/stack v1.8.1 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/holiman/uint256 v1.2.2-0.20230321075855-87b91420868c // indirect
+	golang.org/x/sys v0.8.0 // indirect
+)
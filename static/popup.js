@@ -1,44 +1,31 @@
 $(function () {
-  $("#loadPK").click(function () {
-    var privatekeyvalue = $("#inputPrivateKey").val()
-    console.log(privatekeyvalue)
-    let data = {
-      privatekey: privatekeyvalue
+  // 私钥只在扩展内解析与生成，不发送给节点
+  $("#loadPK").click(async function () {
+    try {
+      var d = await decodePrivateKey($("#inputPrivateKey").val())
+      showAccount(await newAccount(d))
+    } catch (error) {
+      alert(error.message)
     }
-    $.ajax({
-      url: "http://127.0.0.1:3000/getwallet",
-      type: "POST",
-      contentType: "application/json",
-      data: JSON.stringify(data),
-      success: function (response) {
-        $("#inputPrivateKey").val(response["Privatekey"]);
-        $("#inputPublic").val(response["Publickey"]);
-        $("#inputAddress").val(response["Address"]);
-        saveUserInfo()
-        getUserAmount()
-        console.info(response);
-      },
-      error: function (error) {
-        console.error(error);
-      },
-    });
   })
-  $("#randGe").click(function () {
-    var privatekeyvalue = $("#inputPrivateKey").val()
-    console.log(privatekeyvalue)
+  $("#randGe").click(async function () {
+    showAccount(await newAccount(generatePrivateKey()))
+    alert("请抄写并妥善保管私钥，丢失后无法恢复")
+  })
+  // 证明所有权：节点只返回消息摘要，签名在扩展内完成，再由节点的/verifymessage确认签名有效
+  $("#proveOwnership").click(function () {
+    let message = $("#inputMessage").val()
     $.ajax({
-      url: "http://127.0.0.1:3000/addwallet",
+      url: "http://127.0.0.1:3000/messagedigest",
       type: "POST",
-      success: function (response) {
-        $("#inputPrivateKey").val(response["Privatekey"]);
-        $("#inputPublic").val(response["Publickey"]);
-        $("#inputAddress").val(response["Address"]);
-        saveUserInfo()
-        getUserAmount()
-        console.info(response);
+      contentType: "application/json",
+      data: JSON.stringify({ message: message }),
+      success: function (digest) {
+        signAndVerifyMessage(message, digest)
       },
-      error: function (error) {
-        console.error(error);
+      error: function (response) {
+        console.error(response);
+        alert("获取消息摘要失败: " + response.responseText);
       },
     });
   })
@@ -72,26 +59,25 @@ $(function () {
       alert("取消");
       return;
     }
+    // 节点返回未签名的交易模板，扩展核对每个输入后签名，再提交签名后的交易
     let transaction_data = {
       sender_blockchain_address: $("#inputAddress").val(),
-      recipient_blockchain_address: $("#inputReceiveAddress").val(),
-      value: $("#inputAmount").val(),
+      payments: [{
+        address: $("#inputReceiveAddress").val(),
+        amount: parseInt($("#inputAmount").val(), 10),
+      }],
     };
     $.ajax({
-      url: "http://localhost:3000/sendtransation",
+      url: "http://localhost:3000/createtransaction",
       type: "POST",
       contentType: "application/json",
       data: JSON.stringify(transaction_data),
-      success: function (response) {
-        console.info("response.message:", response.message);
-        if (response.message === "fail") {
-          alert("交易失败");
-          return;
-        }
+      success: function (template) {
+        signAndSubmit(template)
       },
       error: function (response) {
         console.error(response);
-        alert("发送失败");
+        alert("创建交易失败: " + response.responseText);
       },
     });
   });
@@ -121,7 +107,91 @@ $(function () {
 })
 
 
-var storage = chrome.storage.sync;
+// 私钥只保存在本机，不同步到浏览器账户
+var storage = chrome.storage.local;
+
+// 显示并保存扩展生成或导入的账户
+function showAccount(account) {
+  $("#inputPrivateKey").val(account.privateKey);
+  $("#inputPublic").val(account.publicKey);
+  $("#inputAddress").val(account.address);
+  saveUserInfo()
+  getUserAmount()
+}
+
+// 用保存的私钥签名交易模板，并提交给节点
+function signAndSubmit(template) {
+  storage.get('data', async function (result) {
+    if (!result.data) {
+      alert("请先加载或生成私钥");
+      return;
+    }
+    let signatures;
+    try {
+      let d = await decodePrivateKey(result.data.privateKey)
+      signatures = await signTemplate(d, result.data, template)
+    } catch (error) {
+      alert("签名失败: " + error.message);
+      return;
+    }
+    $.ajax({
+      url: "http://localhost:3000/submittransaction",
+      type: "POST",
+      contentType: "application/json",
+      data: JSON.stringify({ hex: template.hex, signatures: signatures }),
+      success: function (response) {
+        console.info("txid:", response.txid);
+        alert("交易已提交，手续费: " + template.fee);
+        getUserAmount()
+      },
+      error: function (response) {
+        console.error(response);
+        alert("交易失败: " + response.responseText);
+      },
+    });
+  });
+}
+
+// 用保存的私钥签名消息摘要，并由节点验证签名
+function signAndVerifyMessage(message, digest) {
+  storage.get('data', async function (result) {
+    if (!result.data) {
+      alert("请先加载或生成私钥");
+      return;
+    }
+    let signature;
+    try {
+      let d = await decodePrivateKey(result.data.privateKey)
+      signature = await signMessageDigest(d, result.data, message, digest)
+    } catch (error) {
+      alert("签名失败: " + error.message);
+      return;
+    }
+    let verify_data = {
+      address: result.data.address,
+      signature: signature,
+      message: message,
+    };
+    $.ajax({
+      url: "http://127.0.0.1:3000/verifymessage",
+      type: "POST",
+      contentType: "application/json",
+      data: JSON.stringify(verify_data),
+      success: function (response) {
+        if (!response.valid) {
+          alert("签名验证失败");
+          return;
+        }
+        $("#inputSignature").val(signature);
+      },
+      error: function (response) {
+        console.error(response);
+        alert("签名验证失败: " + response.responseText);
+      },
+    });
+  });
+}
+
 function saveUserInfo() {
   // 获取用户输入的数据
   var inputData = document.getElementById('inputPrivateKey').value;
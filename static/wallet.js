@@ -0,0 +1,338 @@
+// 扩展内的钱包：私钥只保存在扩展的本地存储中，节点只提供未签名的交易模板，签名在扩展内完成
+// 地址与私钥的编码与节点的wallet包一致：地址为Base58(0x23||RIPEMD160(SHA256(压缩公钥))||校验码)，
+// 私钥为Base58(0x80||32字节私钥||0x01||校验码)
+
+// P256曲线参数
+var P256 = {
+  p: BigInt("0xffffffff00000001000000000000000000000000ffffffffffffffffffffffff"),
+  n: BigInt("0xffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551"),
+  gx: BigInt("0x6b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c296"),
+  gy: BigInt("0x4fe342e2fe1a7f9b8ee7eb4a7c0f9e162bce33576b315ececbb6406837bf51f5"),
+};
+
+var addressVersion = 0x23;    // SEC1压缩公钥的地址版本号，地址以F开头
+var privateKeyVersion = 0x80; // 私钥编码的版本号
+var compressedFlag = 0x01;    // 私钥之后的压缩标志
+var b58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz";
+
+function mod(a, m) {
+  var r = a % m;
+  return r < 0n ? r + m : r;
+}
+
+// 模逆，m为素数
+function modInv(a, m) {
+  var result = 1n;
+  var base = mod(a, m);
+  var e = m - 2n;
+  while (e > 0n) {
+    if (e & 1n) {
+      result = result * base % m;
+    }
+    base = base * base % m;
+    e >>= 1n;
+  }
+  return result;
+}
+
+// 仿射坐标下的点加，null表示无穷远点
+function pointAdd(P, Q) {
+  var p = P256.p;
+  if (P === null) return Q;
+  if (Q === null) return P;
+  var lambda;
+  if (P.x === Q.x) {
+    if (mod(P.y + Q.y, p) === 0n) return null;
+    lambda = mod(3n * (P.x * P.x - 1n) * modInv(2n * P.y, p), p); // a = -3
+  } else {
+    lambda = mod((Q.y - P.y) * modInv(Q.x - P.x, p), p);
+  }
+  var x = mod(lambda * lambda - P.x - Q.x, p);
+  var y = mod(lambda * (P.x - x) - P.y, p);
+  return { x: x, y: y };
+}
+
+// 由私钥计算公钥点d*G，只在导入或生成私钥时调用一次
+function publicPoint(d) {
+  var R = null;
+  var Q = { x: P256.gx, y: P256.gy };
+  while (d > 0n) {
+    if (d & 1n) {
+      R = pointAdd(R, Q);
+    }
+    Q = pointAdd(Q, Q);
+    d >>= 1n;
+  }
+  return R;
+}
+
+function bytesToHex(bytes) {
+  return Array.from(bytes, function (b) { return b.toString(16).padStart(2, "0"); }).join("");
+}
+
+function hexToBytes(hex) {
+  hex = hex.replace(/^0x/, "");
+  if (hex.length % 2 !== 0 || /[^0-9a-fA-F]/.test(hex)) {
+    throw new Error("hex格式错误");
+  }
+  var bytes = new Uint8Array(hex.length / 2);
+  for (var i = 0; i < bytes.length; i++) {
+    bytes[i] = parseInt(hex.substr(i * 2, 2), 16);
+  }
+  return bytes;
+}
+
+function bigToBytes(n, size) {
+  return hexToBytes(n.toString(16).padStart(size * 2, "0"));
+}
+
+function bytesToBig(bytes) {
+  return bytes.length === 0 ? 0n : BigInt("0x" + bytesToHex(bytes));
+}
+
+function concatBytes() {
+  var total = 0;
+  for (var i = 0; i < arguments.length; i++) total += arguments[i].length;
+  var out = new Uint8Array(total);
+  var offset = 0;
+  for (var j = 0; j < arguments.length; j++) {
+    out.set(arguments[j], offset);
+    offset += arguments[j].length;
+  }
+  return out;
+}
+
+function base64url(bytes) {
+  return btoa(String.fromCharCode.apply(null, bytes)).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+async function sha256(bytes) {
+  return new Uint8Array(await crypto.subtle.digest("SHA-256", bytes));
+}
+
+// RIPEMD-160，WebCrypto不提供，按规范实现
+function ripemd160(msg) {
+  var rl = [0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 7, 4, 13, 1, 10, 6, 15, 3, 12, 0, 9, 5, 2, 14, 11, 8,
+    3, 10, 14, 4, 9, 15, 8, 1, 2, 7, 0, 6, 13, 11, 5, 12, 1, 9, 11, 10, 0, 8, 12, 4, 13, 3, 7, 15, 14, 5, 6, 2,
+    4, 0, 5, 9, 7, 12, 2, 10, 14, 1, 3, 8, 11, 6, 15, 13];
+  var rr = [5, 14, 7, 0, 9, 2, 11, 4, 13, 6, 15, 8, 1, 10, 3, 12, 6, 11, 3, 7, 0, 13, 5, 10, 14, 15, 8, 12, 4, 9, 1, 2,
+    15, 5, 1, 3, 7, 14, 6, 9, 11, 8, 12, 2, 10, 0, 4, 13, 8, 6, 4, 1, 3, 11, 15, 0, 5, 12, 2, 13, 9, 7, 10, 14,
+    12, 15, 10, 4, 1, 5, 8, 7, 6, 2, 13, 14, 0, 3, 9, 11];
+  var sl = [11, 14, 15, 12, 5, 8, 7, 9, 11, 13, 14, 15, 6, 7, 9, 8, 7, 6, 8, 13, 11, 9, 7, 15, 7, 12, 15, 9, 11, 7, 13, 12,
+    11, 13, 6, 7, 14, 9, 13, 15, 14, 8, 13, 6, 5, 12, 7, 5, 11, 12, 14, 15, 14, 15, 9, 8, 9, 14, 5, 6, 8, 6, 5, 12,
+    9, 15, 5, 11, 6, 8, 13, 12, 5, 12, 13, 14, 11, 8, 5, 6];
+  var sr = [8, 9, 9, 11, 13, 15, 15, 5, 7, 7, 8, 11, 14, 14, 12, 6, 9, 13, 15, 7, 12, 8, 9, 11, 7, 7, 12, 7, 6, 15, 13, 11,
+    9, 7, 15, 11, 8, 6, 6, 14, 12, 13, 5, 14, 13, 13, 7, 5, 15, 5, 8, 11, 14, 14, 6, 14, 6, 9, 12, 9, 12, 5, 15, 8,
+    8, 5, 12, 9, 12, 5, 14, 6, 8, 13, 6, 5, 15, 13, 11, 11];
+  var kl = [0x00000000, 0x5a827999, 0x6ed9eba1, 0x8f1bbcdc, 0xa953fd4e];
+  var kr = [0x50a28be6, 0x5c4dd124, 0x6d703ef3, 0x7a6d76e9, 0x00000000];
+
+  function f(j, x, y, z) {
+    if (j < 16) return x ^ y ^ z;
+    if (j < 32) return (x & y) | (~x & z);
+    if (j < 48) return (x | ~y) ^ z;
+    if (j < 64) return (x & z) | (y & ~z);
+    return x ^ (y | ~z);
+  }
+  function rotl(x, n) {
+    return (x << n) | (x >>> (32 - n));
+  }
+
+  // 填充：0x80，补零到56字节（模64），再加8字节小端序的位长度
+  var length = msg.length;
+  var padded = new Uint8Array(((length + 8) >> 6 << 6) + 64);
+  padded.set(msg);
+  padded[length] = 0x80;
+  var bits = length * 8;
+  for (var i = 0; i < 4; i++) {
+    padded[padded.length - 8 + i] = (bits >>> (8 * i)) & 0xff;
+  }
+
+  var h = [0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476, 0xc3d2e1f0];
+  for (var off = 0; off < padded.length; off += 64) {
+    var x = [];
+    for (var w = 0; w < 16; w++) {
+      var o = off + w * 4;
+      x[w] = padded[o] | (padded[o + 1] << 8) | (padded[o + 2] << 16) | (padded[o + 3] << 24);
+    }
+    var al = h[0], bl = h[1], cl = h[2], dl = h[3], el = h[4];
+    var ar = h[0], br = h[1], cr = h[2], dr = h[3], er = h[4];
+    for (var j = 0; j < 80; j++) {
+      var round = j >> 4;
+      var t = (rotl((al + f(j, bl, cl, dl) + x[rl[j]] + kl[round]) | 0, sl[j]) + el) | 0;
+      al = el; el = dl; dl = rotl(cl, 10); cl = bl; bl = t;
+      t = (rotl((ar + f(79 - j, br, cr, dr) + x[rr[j]] + kr[round]) | 0, sr[j]) + er) | 0;
+      ar = er; er = dr; dr = rotl(cr, 10); cr = br; br = t;
+    }
+    var tmp = (h[1] + cl + dr) | 0;
+    h[1] = (h[2] + dl + er) | 0;
+    h[2] = (h[3] + el + ar) | 0;
+    h[3] = (h[4] + al + br) | 0;
+    h[4] = (h[0] + bl + cr) | 0;
+    h[0] = tmp;
+  }
+
+  var out = new Uint8Array(20);
+  for (var k = 0; k < 5; k++) {
+    for (var b = 0; b < 4; b++) {
+      out[k * 4 + b] = (h[k] >>> (8 * b)) & 0xff;
+    }
+  }
+  return out;
+}
+
+function base58Encode(bytes) {
+  var n = bytesToBig(bytes);
+  var result = "";
+  while (n > 0n) {
+    result = b58Alphabet[Number(n % 58n)] + result;
+    n /= 58n;
+  }
+  for (var i = 0; i < bytes.length && bytes[i] === 0; i++) {
+    result = b58Alphabet[0] + result;
+  }
+  return result;
+}
+
+function base58Decode(str) {
+  var n = 0n;
+  for (var i = 0; i < str.length; i++) {
+    var index = b58Alphabet.indexOf(str[i]);
+    if (index < 0) {
+      throw new Error("Base58编码含有非法字符");
+    }
+    n = n * 58n + BigInt(index);
+  }
+  var zeros = 0;
+  while (zeros < str.length && str[zeros] === b58Alphabet[0]) zeros++;
+  var body = n === 0n ? new Uint8Array(0) : hexToBytes(n.toString(16).padStart(Math.ceil(n.toString(16).length / 2) * 2, "0"));
+  return concatBytes(new Uint8Array(zeros), body);
+}
+
+// 双重sha256的前4字节校验码
+async function checksum(payload) {
+  return (await sha256(await sha256(payload))).slice(0, 4);
+}
+
+// 带版本号与校验码的Base58编码
+async function base58Check(payload) {
+  return base58Encode(concatBytes(payload, await checksum(payload)));
+}
+
+// SEC1压缩公钥
+function compressPoint(P) {
+  return concatBytes(new Uint8Array([P.y & 1n ? 0x03 : 0x02]), bigToBytes(P.x, 32));
+}
+
+// 由压缩公钥计算地址
+async function pubKeyToAddress(pubKey) {
+  var pubKeyHash = ripemd160(await sha256(pubKey));
+  return base58Check(concatBytes(new Uint8Array([addressVersion]), pubKeyHash));
+}
+
+// 将私钥编码为与节点dumpprivkey相同的格式
+async function encodePrivateKey(d) {
+  return base58Check(concatBytes(new Uint8Array([privateKeyVersion]), bigToBytes(d, 32), new Uint8Array([compressedFlag])));
+}
+
+// 解析私钥：节点dumpprivkey导出的Base58私钥，或64位hex
+async function decodePrivateKey(encoded) {
+  encoded = encoded.trim();
+  var d;
+  if (/^(0x)?[0-9a-fA-F]{64}$/.test(encoded)) {
+    d = bytesToBig(hexToBytes(encoded));
+  } else {
+    var payload = base58Decode(encoded);
+    if (payload.length !== 1 + 32 + 1 + 4) {
+      throw new Error("私钥编码长度错误，旧版本编码的私钥请先用convertkeys转换");
+    }
+    var body = payload.slice(0, payload.length - 4);
+    if (bytesToHex(await checksum(body)) !== bytesToHex(payload.slice(body.length))) {
+      throw new Error("私钥校验码错误，请检查是否抄写有误");
+    }
+    if (body[0] !== privateKeyVersion || body[33] !== compressedFlag) {
+      throw new Error("扩展只支持P256私钥");
+    }
+    d = bytesToBig(body.slice(1, 33));
+  }
+  if (d <= 0n || d >= P256.n) {
+    throw new Error("私钥超出范围");
+  }
+  return d;
+}
+
+// 生成新私钥
+function generatePrivateKey() {
+  for (;;) {
+    var d = bytesToBig(crypto.getRandomValues(new Uint8Array(32)));
+    if (d > 0n && d < P256.n) {
+      return d;
+    }
+  }
+}
+
+// 由私钥得到扩展保存的账户：私钥编码、压缩公钥与地址
+async function newAccount(d) {
+  var P = publicPoint(d);
+  var pubKey = compressPoint(P);
+  return {
+    privateKey: await encodePrivateKey(d),
+    publicKey: bytesToHex(pubKey),
+    address: await pubKeyToAddress(pubKey),
+  };
+}
+
+// 用私钥签名：WebCrypto对message做sha256后签名，得到64字节的r||s
+async function signMessageBytes(d, message) {
+  var P = publicPoint(d);
+  var key = await crypto.subtle.importKey("jwk", {
+    kty: "EC",
+    crv: "P-256",
+    d: base64url(bigToBytes(d, 32)),
+    x: base64url(bigToBytes(P.x, 32)),
+    y: base64url(bigToBytes(P.y, 32)),
+  }, { name: "ECDSA", namedCurve: "P-256" }, false, ["sign"]);
+  return new Uint8Array(await crypto.subtle.sign({ name: "ECDSA", hash: "SHA-256" }, key, message));
+}
+
+// 签名交易模板的每个输入：先核对signing_message的sha256等于signing_data，且输入属于本账户
+async function signTemplate(d, account, template) {
+  var signatures = [];
+  for (var i = 0; i < template.inputs.length; i++) {
+    var input = template.inputs[i];
+    if (input.address !== account.address) {
+      throw new Error("第" + i + "个输入不属于当前账户");
+    }
+    var message = hexToBytes(input.signing_message);
+    if (bytesToHex(await sha256(message)) !== input.signing_data) {
+      throw new Error("第" + i + "个输入的签名数据与摘要不符");
+    }
+    signatures.push({
+      signature: bytesToHex(await signMessageBytes(d, message)),
+      pubkey: account.publicKey,
+    });
+  }
+  return signatures;
+}
+
+
+// 消息签名的域分隔前缀，与节点wallet包相同，保证消息签名不能被当作交易签名使用
+var messagePrefix = "fishmanchain Signed Message:\n";
+
+// 消息的签名数据：前缀||8字节大端序的消息长度||消息
+function messageSigningData(message) {
+  var body = new TextEncoder().encode(message);
+  var length = bigToBytes(BigInt(body.length), 8);
+  return concatBytes(new TextEncoder().encode(messagePrefix), length, body);
+}
+
+// 签名消息，证明对地址的控制权：digest为节点/messagedigest的返回，必须与本地计算的签名数据一致
+// 返回与节点signmessage相同格式的base64签名：r||s||压缩公钥
+async function signMessageDigest(d, account, message, digest) {
+  var data = messageSigningData(message);
+  if (bytesToHex(data) !== digest.signing_message || bytesToHex(await sha256(data)) !== digest.digest) {
+    throw new Error("节点返回的消息摘要与本地计算的不符");
+  }
+  var signature = concatBytes(await signMessageBytes(d, data), hexToBytes(account.publicKey));
+  return btoa(String.fromCharCode.apply(null, signature));
+}
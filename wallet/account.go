@@ -0,0 +1,107 @@
+package wallet
+
+import (
+	"bytes"
+	"sort"
+)
+
+//NewChangeAddress 为账户account生成一个新的找零地址：分层确定性钱包从找零链派生，否则随机生成私钥
+//找零地址标记为找零，并记录所属的账户，余额与交易记录按账户汇总
+func (ws *Wallets) NewChangeAddress(account string) (string, error) {
+	account = ws.AccountOf(account)
+
+	var address string
+	if ws.IsHD() {
+		var err error
+		address, err = ws.NewAddress(ChangeChain)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		w := NewWallet()
+		if owner, ok := ws.Wallets[account]; ok && owner.Secp256k1 { //找零地址与账户使用同一种曲线
+			w = NewSecp256k1Wallet()
+		}
+		if err := ws.addWallet(w); err != nil {
+			return "", err
+		}
+		address = string(w.GetAddress())
+	}
+	ws.Wallets[address].Change = true
+	ws.Wallets[address].Account = account
+
+	return address, nil
+}
+
+//AccountOf 返回地址address所属的账户：找零地址属于生成它的账户，其他地址自身就是一个账户
+func (ws *Wallets) AccountOf(address string) string {
+	if w, ok := ws.Wallets[address]; ok && w.Change && w.Account != "" {
+		return w.Account
+	}
+	return address
+}
+
+//hdAccount 返回分层确定性钱包收款链上第一个地址，不在钱包文件中时返回空字符串
+//由助记词恢复的找零地址无法得知生成它的账户，归入这个账户
+func (ws *Wallets) hdAccount() string {
+	first := ""
+	for address, w := range ws.Wallets {
+		if w.HDPath != HDPath(ReceiveChain, 0) {
+			continue
+		}
+		if first == "" || !w.IsLegacy() && ws.Wallets[first].IsLegacy() {
+			first = address
+		}
+	}
+	return first
+}
+
+//setHDChangeAccounts 为不知道所属账户的派生找零地址设置账户为hdAccount
+func (ws *Wallets) setHDChangeAccounts() {
+	account := ws.hdAccount()
+	if account == "" {
+		return
+	}
+	for _, w := range ws.Wallets {
+		if w.Change && w.HDPath != "" && w.Account == "" {
+			w.Account = account
+		}
+	}
+}
+
+//AccountAddresses 返回账户account的全部地址：账户地址本身在前，之后是按地址排序的找零地址
+func (ws *Wallets) AccountAddresses(account string) []string {
+	account = ws.AccountOf(account)
+
+	var change []string
+	for address, w := range ws.Wallets {
+		if w.Change && w.Account == account {
+			change = append(change, address)
+		}
+	}
+	sort.Strings(change)
+
+	return append([]string{account}, change...)
+}
+
+//AccountWallets 返回账户account在钱包文件中的全部钱包，顺序同AccountAddresses
+func (ws *Wallets) AccountWallets(account string) []*Wallet {
+	var wallets []*Wallet
+	for _, address := range ws.AccountAddresses(account) {
+		if w, ok := ws.Wallets[address]; ok {
+			wallets = append(wallets, w)
+		}
+	}
+
+	return wallets
+}
+
+//FindByPubKeyHash 返回公钥哈希为pubKeyHash的钱包，不在钱包文件中时返回nil
+func (ws *Wallets) FindByPubKeyHash(pubKeyHash []byte) *Wallet {
+	for _, w := range ws.Wallets {
+		if bytes.Equal(w.GetPubKeyHash(), pubKeyHash) {
+			return w
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//SetLabel 为钱包文件中的地址address设置标签，label为空时删除标签，之后需要调用SaveToFile保存
+func (ws *Wallets) SetLabel(address, label string) error {
+	w, ok := ws.Wallets[address]
+	if !ok {
+		return fmt.Errorf("ERROR: 钱包文件中没有地址%s", address)
+	}
+	w.Label = strings.TrimSpace(label)
+
+	return nil
+}
+
+//AddContact 将收款地址address以名称name加入通讯录，同名联系人的地址被替换，之后需要调用SaveToFile保存
+func (ws *Wallets) AddContact(name, address string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("ERROR: 联系人名称不能为空")
+	}
+	if ValidateRecipient(name) { //否则转账时无法区分名称与地址
+		return errors.New("ERROR: 联系人名称不能是一个地址")
+	}
+	if !ValidateRecipient(address) { //可以是隐身地址
+		return errors.New("ERROR: 地址非法")
+	}
+	ws.Contacts[name] = address
+
+	return nil
+}
+
+//RemoveContact 从通讯录中删除联系人name，之后需要调用SaveToFile保存
+func (ws *Wallets) RemoveContact(name string) error {
+	if _, ok := ws.Contacts[name]; !ok {
+		return fmt.Errorf("ERROR: 通讯录中没有联系人%s", name)
+	}
+	delete(ws.Contacts, name)
+
+	return nil
+}
+
+//ContactNames 返回按名称排序的联系人
+func (ws *Wallets) ContactNames() []string {
+	var names []string
+	for name := range ws.Contacts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+//ResolveAddress 将收款人解析为地址：合法的地址（包括隐身地址）原样返回，否则在通讯录中按联系人名称查找
+func (ws *Wallets) ResolveAddress(recipient string) (string, error) {
+	if ValidateRecipient(recipient) {
+		return recipient, nil
+	}
+	if address, ok := ws.Contacts[strings.TrimSpace(recipient)]; ok {
+		return address, nil
+	}
+
+	return "", fmt.Errorf("ERROR: %s既不是合法的地址，也不在通讯录中", recipient)
+}
+
+//LabelOf 返回地址address的标签：钱包自己的地址返回其标签，找零地址返回所属账户的标签，
+//通讯录中的地址返回联系人名称，都没有时返回空字符串；按公钥哈希比较，不区分地址的版本号
+func (ws *Wallets) LabelOf(address string) string {
+	pubKeyHash := AddressToPubKeyHash([]byte(address))
+	if w := ws.FindByPubKeyHash(pubKeyHash); w != nil {
+		if w.Label != "" {
+			return w.Label
+		}
+		own := string(w.GetAddress())
+		if account := ws.AccountOf(own); account != own {
+			return ws.LabelOf(account)
+		}
+		return ""
+	}
+	for _, name := range ws.ContactNames() {
+		if bytes.Equal(AddressToPubKeyHash([]byte(ws.Contacts[name])), pubKeyHash) {
+			return name
+		}
+	}
+
+	return ""
+}
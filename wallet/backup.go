@@ -0,0 +1,358 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//legacyFlag 备份中旧版本编码公钥的私钥的标志，与编码私钥时不带标志相对应
+const legacyFlag = byte(0x00)
+
+//backupKeyLen 备份中每个私钥的长度：标志||32字节私钥
+const backupKeyLen = 1 + privateKeyLen
+
+//accountsMarker 备份中找零地址所属账户一段的标志，在私钥之后：标志||个数||（找零地址||账户），地址均为长度||地址
+const accountsMarker = byte(0xfe)
+
+//stealthMarker 备份中隐身地址一段的标志，在私钥之后：标志||个数||（扫描地址||花费地址）||个数||（一次性地址||隐身地址）
+//隐身地址的私钥与一次性地址的私钥都作为随机私钥备份，这一段只记录它们的关系
+const stealthMarker = byte(0xfd)
+
+//BackupSecret 返回钱包文件需要备份的秘密：种子长度||种子||收款链、找零链的下一个索引||无法由种子派生的私钥（标志||私钥）||找零地址所属的账户||隐身地址
+//由种子派生、公钥为SEC1压缩格式的地址只备份派生索引，随机生成、导入的私钥与旧版本编码公钥的私钥逐个备份；钱包已锁定时返回错误
+func (ws *Wallets) BackupSecret() ([]byte, error) {
+	var secret []byte
+	if ws.IsHD() {
+		if ws.HD.Seed == nil {
+			return nil, ErrWalletLocked
+		}
+		secret = append(secret, byte(len(ws.HD.Seed)))
+		secret = append(secret, ws.HD.Seed...)
+		secret = append(secret, ser32(ws.HD.NextReceive)...)
+		secret = append(secret, ser32(ws.HD.NextChange)...)
+	} else {
+		secret = append(secret, 0)
+	}
+
+	var addresses []string
+	for address, w := range ws.Wallets {
+		if !w.WatchOnly && (w.HDPath == "" || w.IsLegacy()) {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		w := ws.Wallets[address]
+		if w.IsLocked() {
+			wipe(secret)
+			return nil, ErrWalletLocked
+		}
+		flag := compressedFlag
+		switch {
+		case w.Secp256k1:
+			flag = secp256k1Flag
+		case w.IsLegacy():
+			flag = legacyFlag
+		}
+		secret = append(secret, flag)
+		secret = append(secret, w.PrivateKey.D.FillBytes(make([]byte, privateKeyLen))...)
+	}
+
+	if len(secret) == 1 {
+		return nil, errors.New("ERROR: 钱包中没有需要备份的种子或私钥")
+	}
+
+	var change []string
+	for address, w := range ws.Wallets {
+		if !w.WatchOnly && w.Change && w.Account != "" {
+			change = append(change, address)
+		}
+	}
+	sort.Strings(change)
+	if len(change) > 0 {
+		secret = append(secret, accountsMarker)
+		secret = append(secret, ser32(uint32(len(change)))...)
+		for _, address := range change {
+			secret = appendBackupString(secret, address)
+			secret = appendBackupString(secret, ws.Wallets[address].Account)
+		}
+	}
+
+	if len(ws.Stealth) > 0 {
+		secret = append(secret, stealthMarker)
+		secret = append(secret, ser32(uint32(len(ws.Stealth)))...)
+		for _, address := range ws.StealthAddresses() {
+			secret = appendBackupString(secret, ws.Stealth[address].ScanAddress)
+			secret = appendBackupString(secret, ws.Stealth[address].SpendAddress)
+		}
+		var received []string
+		for address, w := range ws.Wallets {
+			if w.Stealth != "" {
+				received = append(received, address)
+			}
+		}
+		sort.Strings(received)
+		secret = append(secret, ser32(uint32(len(received)))...)
+		for _, address := range received {
+			secret = appendBackupString(secret, address)
+			secret = appendBackupString(secret, ws.Wallets[address].Stealth)
+		}
+	}
+
+	return secret, nil
+}
+
+//appendBackupString 将长度||字符串追加到备份中
+func appendBackupString(b []byte, s string) []byte {
+	return append(append(b, byte(len(s))), s...)
+}
+
+//readBackupString 读取备份中的长度||字符串，返回字符串与剩余的数据
+func readBackupString(b []byte) (string, []byte, error) {
+	if len(b) == 0 || len(b) < 1+int(b[0]) {
+		return "", nil, errors.New("ERROR: 备份的秘密长度错误")
+	}
+	return string(b[1 : 1+int(b[0])]), b[1+int(b[0]):], nil
+}
+
+//RestoreSecret 由BackupSecret返回的秘密恢复种子与私钥，加入钱包文件，之后需要调用SaveToFile保存
+//由种子派生的地址按备份时的索引重新派生，找零地址恢复所属的账户；旧版本的备份没有账户，派生的找零地址归入hdAccount
+//隐身地址恢复后尚未扫描，需要用scanstealth从头扫描区块链
+func (ws *Wallets) RestoreSecret(secret []byte) error {
+	if len(secret) == 0 {
+		return errors.New("ERROR: 备份的秘密为空")
+	}
+	seedLen := int(secret[0])
+	rest := secret[1:]
+	if seedLen > 0 {
+		if len(rest) < seedLen+8 {
+			return errors.New("ERROR: 备份的秘密长度错误")
+		}
+		if err := ws.SetSeed(rest[:seedLen]); err != nil {
+			return err
+		}
+		nextReceive := binary.BigEndian.Uint32(rest[seedLen:])
+		nextChange := binary.BigEndian.Uint32(rest[seedLen+4:])
+		for _, chain := range []uint32{ReceiveChain, ChangeChain} {
+			next := nextReceive
+			if chain == ChangeChain {
+				next = nextChange
+			}
+			for ws.HD.Next(chain) < next {
+				if _, err := ws.NewAddress(chain); err != nil {
+					return err
+				}
+			}
+		}
+		rest = rest[seedLen+8:]
+	}
+
+	for ; len(rest) > 0 && rest[0] != accountsMarker && rest[0] != stealthMarker; rest = rest[backupKeyLen:] {
+		if len(rest) < backupKeyLen {
+			return errors.New("ERROR: 备份的秘密长度错误")
+		}
+		d := rest[1:backupKeyLen]
+		var w *Wallet
+		var err error
+		switch rest[0] {
+		case secp256k1Flag:
+			w, err = newSecp256k1WalletFromKey(d)
+		case compressedFlag, legacyFlag:
+			w = newWalletFromKey(d)
+			if rest[0] == legacyFlag {
+				w.PublicKey = LegacyPubKey(&w.PrivateKey.PublicKey)
+			}
+		default:
+			err = fmt.Errorf("ERROR: 备份中私钥的标志%d非法", rest[0])
+		}
+		if err != nil {
+			return err
+		}
+		if old, ok := ws.Wallets[string(w.GetAddress())]; ok && !old.WatchOnly {
+			continue //由种子派生的地址已经加入
+		}
+		if _, err := ws.importKey(w); err != nil {
+			return err
+		}
+	}
+
+	for len(rest) > 0 {
+		var err error
+		switch rest[0] {
+		case accountsMarker:
+			rest, err = ws.restoreAccounts(rest[1:])
+		case stealthMarker:
+			rest, err = ws.restoreStealth(rest[1:])
+		default:
+			err = errors.New("ERROR: 备份的秘密长度错误")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	ws.setHDChangeAccounts()
+
+	return nil
+}
+
+//restoreAccounts 由备份中找零地址所属账户一段（不含标志）恢复找零地址的账户，返回之后的数据
+func (ws *Wallets) restoreAccounts(rest []byte) ([]byte, error) {
+	pairs, rest, err := readBackupPairs(rest)
+	if err != nil {
+		return nil, err
+	}
+	for _, pair := range pairs {
+		if w, ok := ws.Wallets[pair[0]]; ok {
+			w.Change = true
+			w.Account = pair[1]
+		}
+	}
+
+	return rest, nil
+}
+
+//restoreStealth 由备份中隐身地址一段（不含标志）恢复隐身地址与一次性地址所属的隐身地址，返回之后的数据
+func (ws *Wallets) restoreStealth(rest []byte) ([]byte, error) {
+	keys, rest, err := readBackupPairs(rest)
+	if err != nil {
+		return nil, err
+	}
+	received, rest, err := readBackupPairs(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pair := range keys {
+		scan, spend := ws.Wallets[pair[0]], ws.Wallets[pair[1]]
+		if scan == nil || spend == nil {
+			return nil, errors.New("ERROR: 备份中缺少隐身地址的私钥")
+		}
+		scan.StealthKey, spend.StealthKey = true, true
+		if ws.Stealth == nil {
+			ws.Stealth = make(map[string]*StealthKeys)
+		}
+		address := EncodeStealthAddress(scan.PublicKey, spend.PublicKey)
+		ws.Stealth[address] = &StealthKeys{ScanAddress: pair[0], SpendAddress: pair[1], Height: -1}
+	}
+	for _, pair := range received {
+		if w, ok := ws.Wallets[pair[0]]; ok {
+			w.Stealth = pair[1]
+		}
+	}
+
+	return rest, nil
+}
+
+//readBackupPairs 读取备份中的个数||（地址||地址），返回地址对与之后的数据
+func readBackupPairs(b []byte) ([][2]string, []byte, error) {
+	if len(b) < 4 {
+		return nil, nil, errors.New("ERROR: 备份的秘密长度错误")
+	}
+	count := binary.BigEndian.Uint32(b)
+	b = b[4:]
+
+	var pairs [][2]string
+	for i := uint32(0); i < count; i++ {
+		var pair [2]string
+		var err error
+		if pair[0], b, err = readBackupString(b); err != nil {
+			return nil, nil, err
+		}
+		if pair[1], b, err = readBackupString(b); err != nil {
+			return nil, nil, err
+		}
+		pairs = append(pairs, pair)
+	}
+
+	return pairs, b, nil
+}
+
+//AddressFingerprint 返回钱包文件中全部带私钥地址的指纹：排序后的地址以换行连接，双重sha256的前4字节
+func (ws *Wallets) AddressFingerprint() []byte {
+	var addresses []string
+	for address, w := range ws.Wallets {
+		if !w.WatchOnly {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+
+	return checksum([]byte(strings.Join(addresses, "\n")))
+}
+
+//BackupShares 将钱包文件的种子与私钥分为n个分片，任意threshold个分片可以恢复，钱包已锁定时返回错误
+//分片中记录恢复后全部地址的指纹；备份前先在空钱包中试恢复，确认钱包中的每个私钥都能恢复
+func (ws *Wallets) BackupShares(n, threshold int) ([]*Share, error) {
+	secret, err := ws.BackupSecret()
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(secret)
+
+	restored, err := restoreToEmpty(secret)
+	if err != nil {
+		return nil, err
+	}
+	for address, w := range ws.Wallets {
+		if _, ok := restored.Wallets[address]; !ok && !w.WatchOnly {
+			return nil, fmt.Errorf("ERROR: 地址%s无法由备份恢复", address)
+		}
+	}
+	for address := range ws.Stealth {
+		if _, ok := restored.Stealth[address]; !ok {
+			return nil, fmt.Errorf("ERROR: 隐身地址%s无法由备份恢复", address)
+		}
+	}
+
+	return SplitSecret(secret, n, threshold, restored.AddressFingerprint())
+}
+
+//RestoreShares 由不少于门限个分片恢复种子与私钥，校验恢复出的地址与分片中记录的指纹一致后加入钱包文件，返回恢复的地址个数
+//钱包文件中不能已有种子或私钥；之后需要调用SaveToFile保存
+func (ws *Wallets) RestoreShares(shares []*Share) (int, error) {
+	if ws.IsHD() {
+		return 0, errors.New("ERROR: 钱包文件已经有种子，请用createwallet创建新钱包后恢复")
+	}
+	for address, w := range ws.Wallets {
+		if !w.WatchOnly {
+			return 0, fmt.Errorf("ERROR: 钱包文件中已有地址%s的私钥，请用createwallet创建新钱包后恢复", address)
+		}
+	}
+	for _, share := range shares {
+		if !bytes.Equal(share.Fingerprint, shares[0].Fingerprint) {
+			return 0, errors.New("ERROR: 分片记录的地址指纹不一致，分片不属于同一次备份")
+		}
+	}
+
+	secret, err := CombineShares(shares)
+	if err != nil {
+		return 0, err
+	}
+	defer wipe(secret)
+	restored, err := restoreToEmpty(secret)
+	if err != nil {
+		return 0, fmt.Errorf("ERROR: 恢复失败，分片可能有误: %v", err)
+	}
+	if !bytes.Equal(restored.AddressFingerprint(), shares[0].Fingerprint) {
+		return 0, errors.New("ERROR: 恢复出的地址与备份时的地址指纹不符，分片可能有误")
+	}
+
+	if err := ws.RestoreSecret(secret); err != nil {
+		return 0, err
+	}
+	return len(restored.Wallets), nil
+}
+
+//restoreToEmpty 在内存中的空钱包文件中恢复秘密
+func restoreToEmpty(secret []byte) (*Wallets, error) {
+	ws := &Wallets{Wallets: make(map[string]*Wallet), Contacts: make(map[string]string)}
+	if err := ws.RestoreSecret(secret); err != nil {
+		return nil, err
+	}
+
+	return ws, nil
+}
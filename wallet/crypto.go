@@ -0,0 +1,426 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+//ErrWalletLocked 钱包已加密且未解锁，不能读取私钥
+var ErrWalletLocked = errors.New("ERROR: wallet locked，请先执行walletunlock解锁钱包")
+
+//scrypt参数，N=2^15、r=8时派生一次密钥约需32MB内存
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = chacha20poly1305.KeySize
+	saltLen      = 32
+)
+
+//passphraseCheck 用主密钥加密的固定内容，用于校验密码是否正确（钱包中没有私钥时也能校验）
+var passphraseCheck = []byte("fishmanchain wallet")
+
+//WalletCrypto 钱包文件的加密参数：主密钥由密码经scrypt派生，每个私钥用XChaCha20-Poly1305加密
+type WalletCrypto struct {
+	Salt  []byte
+	N     int
+	R     int
+	P     int
+	Check []byte //nonce||密文，密文为passphraseCheck
+}
+
+//unlockedKey 已解锁钱包的主密钥，到期后由timer删除
+type unlockedKey struct {
+	key         []byte
+	keystoreKey []byte //目录形式的钱包由密码派生的keystore文件密钥，用于加密新的keystore文件，其他钱包为nil
+	timer       *time.Timer
+}
+
+//unlocked 进程内已解锁钱包的主密钥，键为钱包文件名
+//节点进程中walletunlock解锁后，之后每次读取钱包文件都自动解密私钥，直到超时或walletlock
+var unlocked = struct {
+	sync.Mutex
+	keys map[string]*unlockedKey
+}{keys: make(map[string]*unlockedKey)}
+
+//lookupKey 返回钱包文件file已解锁的主密钥，未解锁时返回nil
+func lookupKey(file string) []byte {
+	unlocked.Lock()
+	defer unlocked.Unlock()
+
+	if k, ok := unlocked.keys[file]; ok {
+		return append([]byte(nil), k.key...)
+	}
+	return nil
+}
+
+//storeKey 保存钱包文件file的主密钥，timeout后自动删除
+func storeKey(file string, key []byte, timeout time.Duration) {
+	unlocked.Lock()
+	defer unlocked.Unlock()
+
+	if k, ok := unlocked.keys[file]; ok {
+		k.timer.Stop()
+	}
+	k := &unlockedKey{key: key}
+	k.timer = time.AfterFunc(timeout, func() {
+		unlocked.Lock()
+		defer unlocked.Unlock()
+		if unlocked.keys[file] == k {
+			wipe(k.key)
+			wipe(k.keystoreKey)
+			delete(unlocked.keys, file)
+		}
+	})
+	unlocked.keys[file] = k
+}
+
+//dropKey 删除钱包文件file的主密钥
+func dropKey(file string) {
+	unlocked.Lock()
+	defer unlocked.Unlock()
+
+	if k, ok := unlocked.keys[file]; ok {
+		k.timer.Stop()
+		wipe(k.key)
+		wipe(k.keystoreKey)
+		delete(unlocked.keys, file)
+	}
+}
+
+//storeKeystoreKey 为已解锁的钱包file保存keystore文件密钥，随主密钥一起到期，钱包未解锁时返回false
+func storeKeystoreKey(file string, key []byte) bool {
+	unlocked.Lock()
+	defer unlocked.Unlock()
+
+	k, ok := unlocked.keys[file]
+	if ok {
+		wipe(k.keystoreKey)
+		k.keystoreKey = append([]byte(nil), key...)
+	}
+	return ok
+}
+
+//lookupKeystoreKey 返回已解锁的钱包file保存的keystore文件密钥，未解锁或没有保存时返回nil
+func lookupKeystoreKey(file string) []byte {
+	unlocked.Lock()
+	defer unlocked.Unlock()
+
+	if k, ok := unlocked.keys[file]; ok && k.keystoreKey != nil {
+		return append([]byte(nil), k.keystoreKey...)
+	}
+	return nil
+}
+
+//replaceKey 修改密码后替换已解锁钱包文件file的主密钥，钱包未解锁时返回false
+func replaceKey(file string, key []byte) bool {
+	unlocked.Lock()
+	defer unlocked.Unlock()
+
+	k, ok := unlocked.keys[file]
+	if ok {
+		wipe(k.key)
+		k.key = key
+	}
+	return ok
+}
+
+//wipe 清零内存中的密钥
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+//newWalletCrypto 生成新的随机盐，由passphrase派生主密钥
+func newWalletCrypto(passphrase string) (*WalletCrypto, []byte, error) {
+	if passphrase == "" {
+		return nil, nil, errors.New("ERROR: 密码不能为空")
+	}
+
+	c := &WalletCrypto{Salt: make([]byte, saltLen), N: scryptN, R: scryptR, P: scryptP}
+	if _, err := rand.Read(c.Salt); err != nil {
+		return nil, nil, err
+	}
+	key, err := c.deriveKey(passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.Check, err = seal(key, passphraseCheck, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c, key, nil
+}
+
+//deriveKey 由密码派生主密钥
+func (c *WalletCrypto) deriveKey(passphrase string) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), c.Salt, c.N, c.R, c.P, scryptKeyLen)
+}
+
+//keystoreSalt 目录形式的钱包写keystore文件使用的scrypt盐，由钱包的盐派生，与主密钥不同
+func (c *WalletCrypto) keystoreSalt() []byte {
+	salt := sha256.Sum256(append([]byte("keystore"), c.Salt...))
+	return salt[:]
+}
+
+//deriveKeystoreKey 由密码派生keystore文件密钥，scrypt参数与主密钥相同，盐为keystoreSalt
+func (c *WalletCrypto) deriveKeystoreKey(passphrase string) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), c.keystoreSalt(), c.N, c.R, c.P, keystoreKeyLen)
+}
+
+//verify 由密码派生主密钥并校验，密码错误时返回错误
+func (c *WalletCrypto) verify(passphrase string) ([]byte, error) {
+	key, err := c.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := open(key, c.Check, nil); err != nil {
+		return nil, errors.New("ERROR: 钱包密码错误")
+	}
+
+	return key, nil
+}
+
+//seal 用key加密plaintext，ad为附加认证数据，返回nonce||密文
+func seal(key, plaintext, ad []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+//open 解密seal的结果，密钥错误或数据被篡改时返回错误
+func open(key, sealed, ad []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ERROR: 密文长度不足")
+	}
+
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], ad)
+}
+
+//encryptKey 用主密钥加密钱包的私钥，公钥作为附加认证数据，防止私钥被换到别的钱包下
+func (w *Wallet) encryptKey(key []byte) error {
+	if w.PrivateKey.D == nil {
+		return ErrWalletLocked
+	}
+
+	d := w.PrivateKey.D.FillBytes(make([]byte, 32))
+	sealed, err := seal(key, d, w.PublicKey)
+	wipe(d)
+	if err != nil {
+		return err
+	}
+	w.EncryptedKey = sealed
+
+	return nil
+}
+
+//decryptKey 用主密钥解密钱包的私钥
+func (w *Wallet) decryptKey(key []byte) error {
+	d, err := open(key, w.EncryptedKey, w.PublicKey)
+	if err != nil {
+		return fmt.Errorf("ERROR: 地址%s的私钥解密失败", w.GetAddress())
+	}
+	w.PrivateKey.D = new(big.Int).SetBytes(d)
+	wipe(d)
+
+	return nil
+}
+
+//IsLocked 钱包的私钥是否不可用（钱包已加密且未解锁）
+func (w Wallet) IsLocked() bool {
+	return w.PrivateKey.D == nil
+}
+
+//IsEncrypted 钱包文件是否已加密
+func (ws *Wallets) IsEncrypted() bool {
+	return ws.Crypto != nil
+}
+
+//IsLocked 钱包文件已加密且未解锁
+func (ws *Wallets) IsLocked() bool {
+	return ws.IsEncrypted() && lookupKey(ws.file) == nil
+}
+
+//Encrypt 用passphrase加密钱包文件中的全部私钥，之后需要调用SaveToFile保存
+func (ws *Wallets) Encrypt(passphrase string) error {
+	if ws.IsEncrypted() {
+		return errors.New("ERROR: 钱包已经加密，修改密码请使用walletpassphrasechange")
+	}
+
+	c, key, err := newWalletCrypto(passphrase)
+	if err != nil {
+		return err
+	}
+	defer wipe(key)
+	if err := ws.encryptAll(key); err != nil {
+		return err
+	}
+	ws.Crypto = c
+
+	return nil
+}
+
+//Unlock 用passphrase解锁钱包，timeout后自动重新锁定
+func (ws *Wallets) Unlock(passphrase string, timeout time.Duration) error {
+	if !ws.IsEncrypted() {
+		return errors.New("ERROR: 钱包未加密")
+	}
+	if timeout <= 0 {
+		return errors.New("ERROR: 解锁时长必须大于0")
+	}
+
+	key, err := ws.Crypto.verify(passphrase)
+	if err != nil {
+		return err
+	}
+	if err := ws.decryptAll(key); err != nil {
+		return err
+	}
+	storeKey(ws.file, key, timeout)
+
+	//目录形式的钱包保存由密码派生的keystore文件密钥用于加密新的keystore文件，并加入放入目录的keystore文件
+	if ws.IsKeystoreDir() {
+		keystoreKey, err := ws.Crypto.deriveKeystoreKey(passphrase)
+		if err != nil {
+			return err
+		}
+		storeKeystoreKey(ws.file, keystoreKey)
+		wipe(keystoreKey)
+		ws.adoptKeyFiles(passphrase)
+		ws.upgradeKeyFiles()
+	}
+
+	return nil
+}
+
+//Lock 立即锁定钱包，清除内存中的主密钥与私钥
+func (ws *Wallets) Lock() error {
+	if !ws.IsEncrypted() {
+		return errors.New("ERROR: 钱包未加密")
+	}
+
+	dropKey(ws.file)
+	wipe(ws.keystoreKey)
+	ws.keystoreKey = nil
+	for _, w := range ws.Wallets {
+		w.PrivateKey.D = nil
+	}
+	if ws.IsHD() {
+		wipe(ws.HD.Seed)
+		ws.HD.Seed = nil
+	}
+
+	return nil
+}
+
+//ChangePassphrase 修改钱包密码，全部私钥用新的主密钥重新加密，之后需要调用SaveToFile保存
+func (ws *Wallets) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	if !ws.IsEncrypted() {
+		return errors.New("ERROR: 钱包未加密")
+	}
+
+	oldKey, err := ws.Crypto.verify(oldPassphrase)
+	if err != nil {
+		return err
+	}
+	defer wipe(oldKey)
+	c, newKey, err := newWalletCrypto(newPassphrase)
+	if err != nil {
+		return err
+	}
+
+	if err := ws.decryptAll(oldKey); err != nil {
+		return err
+	}
+	if err := ws.encryptAll(newKey); err != nil {
+		return err
+	}
+	ws.Crypto = c
+
+	//已解锁的钱包改用新的主密钥，否则之后新建的私钥无法用新密码解密
+	if !replaceKey(ws.file, newKey) {
+		wipe(newKey)
+	}
+	//目录形式的钱包保存时用新密码重写全部keystore文件
+	if ws.IsKeystoreDir() {
+		keystoreKey, err := c.deriveKeystoreKey(newPassphrase)
+		if err != nil {
+			return err
+		}
+		ws.keystoreKey, ws.rewrite = keystoreKey, true
+		storeKeystoreKey(ws.file, keystoreKey)
+	}
+
+	return nil
+}
+
+//encryptAll 用主密钥加密全部私钥与种子
+func (ws *Wallets) encryptAll(key []byte) error {
+	for _, w := range ws.Wallets {
+		if w.WatchOnly { //只读地址没有私钥
+			continue
+		}
+		if err := w.encryptKey(key); err != nil {
+			return err
+		}
+	}
+	if ws.IsHD() {
+		return ws.HD.encryptSeed(key)
+	}
+
+	return nil
+}
+
+//decryptAll 用主密钥解密全部私钥与种子
+func (ws *Wallets) decryptAll(key []byte) error {
+	for _, w := range ws.Wallets {
+		if w.WatchOnly { //只读地址没有私钥
+			continue
+		}
+		if err := w.decryptKey(key); err != nil {
+			return err
+		}
+	}
+	if ws.IsHD() {
+		return ws.HD.decryptSeed(key)
+	}
+
+	return nil
+}
+
+//SigningWallet 返回地址address的钱包用于签名，地址不在钱包文件中、是只读地址或钱包已锁定时返回错误
+func (ws *Wallets) SigningWallet(address string) (*Wallet, error) {
+	w, ok := ws.Wallets[address]
+	if !ok {
+		return nil, fmt.Errorf("ERROR: 钱包文件中没有地址%s", address)
+	}
+	if w.WatchOnly {
+		return nil, fmt.Errorf("ERROR: 地址%s是只读地址，钱包中没有私钥，不能签名", address)
+	}
+	if w.IsLocked() {
+		return nil, ErrWalletLocked
+	}
+
+	return w, nil
+}
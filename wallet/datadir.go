@@ -0,0 +1,110 @@
+package wallet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//DataDir 钱包数据目录：钱包文件、目录形式的钱包、未确认的交易与扫描缓存都按钱包名称保存在其中
+//命令行与节点由环境变量WALLET_DIR修改，未设置钱包名称时使用节点ID，与旧版本的钱包文件兼容
+var DataDir = "./tmp/wallet"
+
+//walletNamePattern 钱包名称是文件名的一部分，只能由字母、数字、-和_组成
+var walletNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+//SetDataDir 修改钱包数据目录，目录不存在时创建
+func SetDataDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	DataDir = dir
+
+	return nil
+}
+
+//WalletPath 返回数据目录中钱包name的文件路径，format为文件名格式，%s替换为钱包名称
+func WalletPath(format, name string) string {
+	return filepath.Join(DataDir, fmt.Sprintf(format, name))
+}
+
+//ValidateWalletName 校验钱包名称
+func ValidateWalletName(name string) error {
+	if !walletNamePattern.MatchString(name) {
+		return fmt.Errorf("ERROR: 钱包名称%q非法，只能由字母、数字、-和_组成", name)
+	}
+
+	return nil
+}
+
+//WalletExists 数据目录中是否有钱包name的钱包文件或目录形式的钱包
+func WalletExists(name string) bool {
+	if isDir(WalletPath(keystoreDir, name)) {
+		return true
+	}
+	_, err := os.Stat(WalletPath(walletFile, name))
+
+	return err == nil
+}
+
+//CreateNamedWallet 在数据目录中创建空的钱包文件name，同名钱包已存在时返回错误
+func CreateNamedWallet(name string) (*Wallets, error) {
+	if err := ValidateWalletName(name); err != nil {
+		return nil, err
+	}
+	if WalletExists(name) {
+		return nil, fmt.Errorf("ERROR: 钱包%s已存在", name)
+	}
+	if err := os.MkdirAll(DataDir, 0700); err != nil {
+		return nil, err
+	}
+
+	ws := &Wallets{Wallets: make(map[string]*Wallet), Contacts: make(map[string]string)}
+	ws.file = WalletPath(walletFile, name)
+	ws.SaveToFile(name)
+
+	return ws, nil
+}
+
+//ListWallets 返回数据目录中全部钱包的名称，按名称排序
+func ListWallets() ([]string, error) {
+	files, err := ioutil.ReadDir(DataDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, suffix := splitFormat(walletFile)
+	dirPrefix, _ := splitFormat(keystoreDir)
+	seen := make(map[string]bool)
+	var names []string
+	for _, file := range files {
+		var name string
+		switch {
+		case file.IsDir() && strings.HasPrefix(file.Name(), dirPrefix):
+			name = strings.TrimPrefix(file.Name(), dirPrefix)
+		case !file.IsDir() && strings.HasPrefix(file.Name(), prefix) && strings.HasSuffix(file.Name(), suffix):
+			name = strings.TrimSuffix(strings.TrimPrefix(file.Name(), prefix), suffix)
+		}
+		if ValidateWalletName(name) == nil && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+//splitFormat 将文件名格式按%s分为前缀与后缀
+func splitFormat(format string) (string, string) {
+	parts := strings.SplitN(format, "%s", 2)
+
+	return parts[0], parts[len(parts)-1]
+}
@@ -0,0 +1,357 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+//HardenedOffset 索引不小于该值的子密钥为强化派生，只能由父私钥派生
+const HardenedOffset uint32 = 0x80000000
+
+//分层确定性钱包的两条派生链：收款地址与找零地址
+const (
+	ReceiveChain uint32 = 0
+	ChangeChain  uint32 = 1
+)
+
+//AccountPath 钱包账户的派生路径，地址路径为AccountPath/链/索引
+const AccountPath = "m/44'/0'/0'"
+
+//DefaultGapLimit 恢复钱包时，连续这么多个地址没有交易记录就停止派生
+const DefaultGapLimit = 20
+
+//masterKeySeed 按SLIP-0010，P256曲线主密钥的HMAC密钥
+var masterKeySeed = []byte("Nist256p1 seed")
+
+//HDChain 分层确定性钱包的种子与每条链下一个未使用的索引
+type HDChain struct {
+	Seed          []byte //钱包加密后为nil，解锁后由EncryptedSeed解密
+	EncryptedSeed []byte
+	NextReceive   uint32
+	NextChange    uint32
+}
+
+//Next 返回派生链chain上下一个未使用的索引
+func (hd *HDChain) Next(chain uint32) uint32 {
+	if chain == ChangeChain {
+		return hd.NextChange
+	}
+	return hd.NextReceive
+}
+
+//ExtendedKey 扩展私钥：私钥与链码
+type ExtendedKey struct {
+	Key       []byte
+	ChainCode []byte
+}
+
+//NewMnemonic 生成新的助记词，words为12或24
+func NewMnemonic(words int) (string, error) {
+	var bits int
+	switch words {
+	case 12:
+		bits = 128
+	case 24:
+		bits = 256
+	default:
+		return "", fmt.Errorf("ERROR: 助记词只能为12或24个单词，不支持%d", words)
+	}
+	entropy, err := bip39.NewEntropy(bits)
+	if err != nil {
+		return "", err
+	}
+
+	return bip39.NewMnemonic(entropy)
+}
+
+//MnemonicToSeed 校验助记词并生成种子，passphrase为可选的助记词密码
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, errors.New("ERROR: 助记词无效，请检查单词拼写与顺序")
+	}
+
+	return bip39.NewSeed(mnemonic, passphrase), nil
+}
+
+//NewMasterKey 由种子生成主扩展私钥（SLIP-0010）
+func NewMasterKey(seed []byte) *ExtendedKey {
+	n := elliptic.P256().Params().N
+	data := seed
+	for {
+		mac := hmac.New(sha512.New, masterKeySeed)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+
+		k := new(big.Int).SetBytes(sum[:32])
+		if k.Sign() != 0 && k.Cmp(n) < 0 {
+			return &ExtendedKey{Key: sum[:32], ChainCode: sum[32:]}
+		}
+		data = sum //私钥不合法时对结果再做一次HMAC
+	}
+}
+
+//Child 派生索引为index的子扩展私钥，index不小于HardenedOffset时为强化派生
+func (k *ExtendedKey) Child(index uint32) *ExtendedKey {
+	curve := elliptic.P256()
+	n := curve.Params().N
+	parent := new(big.Int).SetBytes(k.Key)
+
+	var data []byte
+	if index >= HardenedOffset {
+		data = append([]byte{0x00}, k.Key...)
+	} else {
+		x, y := curve.ScalarBaseMult(k.Key)
+		data = elliptic.MarshalCompressed(curve, x, y)
+	}
+	data = append(data, ser32(index)...)
+
+	for {
+		mac := hmac.New(sha512.New, k.ChainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+
+		il := new(big.Int).SetBytes(sum[:32])
+		child := new(big.Int).Add(il, parent)
+		child.Mod(child, n)
+		if il.Cmp(n) < 0 && child.Sign() != 0 {
+			return &ExtendedKey{Key: child.FillBytes(make([]byte, 32)), ChainCode: sum[32:]}
+		}
+		//子私钥不合法时按SLIP-0010换用0x01||IR||index重新计算
+		data = append(append([]byte{0x01}, sum[32:]...), ser32(index)...)
+	}
+}
+
+//ser32 将索引编码为4字节大端序
+func ser32(i uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, i)
+	return b
+}
+
+//ParsePath 解析m/44'/0'/0'/0/1格式的派生路径，'或h表示强化派生
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("ERROR: 派生路径%q必须以m开头", path)
+	}
+
+	var indexes []uint32
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h")
+		part = strings.TrimRight(part, "'h")
+		i, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(i) >= HardenedOffset {
+			return nil, fmt.Errorf("ERROR: 派生路径%q中的索引%q非法", path, part)
+		}
+		if hardened {
+			i += uint64(HardenedOffset)
+		}
+		indexes = append(indexes, uint32(i))
+	}
+
+	return indexes, nil
+}
+
+//DeriveKey 由种子按派生路径path派生扩展私钥
+func DeriveKey(seed []byte, path string) (*ExtendedKey, error) {
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	key := NewMasterKey(seed)
+	for _, i := range indexes {
+		key = key.Child(i)
+	}
+
+	return key, nil
+}
+
+//newWalletFromKey 由私钥的字节构造钱包
+func newWalletFromKey(d []byte) *Wallet {
+	curve := elliptic.P256()
+	private := ecdsa.PrivateKey{D: new(big.Int).SetBytes(d)}
+	private.PublicKey.Curve = curve
+	private.PublicKey.X, private.PublicKey.Y = curve.ScalarBaseMult(d)
+
+	return &Wallet{PrivateKey: private, PublicKey: MarshalPubKey(&private.PublicKey)}
+}
+
+//HDPath 返回派生链chain上第index个地址的派生路径
+func HDPath(chain, index uint32) string {
+	return fmt.Sprintf("%s/%d/%d", AccountPath, chain, index)
+}
+
+//IsHD 钱包文件是否为分层确定性钱包
+func (ws *Wallets) IsHD() bool {
+	return ws.HD != nil
+}
+
+//SetSeed 将钱包文件设为由seed派生地址的分层确定性钱包，已有的随机私钥保持不变
+//钱包文件已加密时种子同样加密保存，因此必须先解锁
+func (ws *Wallets) SetSeed(seed []byte) error {
+	if ws.IsHD() {
+		return errors.New("ERROR: 钱包文件已经有种子，不能重复设置")
+	}
+
+	hd := &HDChain{Seed: append([]byte(nil), seed...)}
+	if ws.IsEncrypted() {
+		key := lookupKey(ws.file)
+		if key == nil {
+			return ErrWalletLocked
+		}
+		defer wipe(key)
+		if err := hd.encryptSeed(key); err != nil {
+			return err
+		}
+	}
+	ws.HD = hd
+
+	return nil
+}
+
+//DeriveWallet 派生链chain上第index个地址的钱包，不加入钱包文件
+func (ws *Wallets) DeriveWallet(chain, index uint32) (*Wallet, error) {
+	if !ws.IsHD() {
+		return nil, errors.New("ERROR: 钱包文件不是分层确定性钱包")
+	}
+	if ws.HD.Seed == nil {
+		return nil, ErrWalletLocked
+	}
+
+	path := HDPath(chain, index)
+	key, err := DeriveKey(ws.HD.Seed, path)
+	if err != nil {
+		return nil, err
+	}
+	w := newWalletFromKey(key.Key)
+	w.HDPath = path
+	w.Change = chain == ChangeChain
+
+	return w, nil
+}
+
+//NewAddress 派生链chain上下一个未使用的地址，加入钱包文件并返回地址
+func (ws *Wallets) NewAddress(chain uint32) (string, error) {
+	return ws.newAddress(chain, false)
+}
+
+//newAddress 派生链chain上下一个未使用的地址，legacy为true时使用旧版本编码的公钥（恢复旧钱包用过的地址）
+func (ws *Wallets) newAddress(chain uint32, legacy bool) (string, error) {
+	if !ws.IsHD() {
+		return "", errors.New("ERROR: 钱包文件不是分层确定性钱包")
+	}
+	next := &ws.HD.NextReceive
+	if chain == ChangeChain {
+		next = &ws.HD.NextChange
+	}
+
+	w, err := ws.DeriveWallet(chain, *next)
+	if err != nil {
+		return "", err
+	}
+	if legacy {
+		w.PublicKey = LegacyPubKey(&w.PrivateKey.PublicKey)
+	}
+	if err := ws.addWallet(w); err != nil {
+		return "", err
+	}
+	*next++
+
+	return string(w.GetAddress()), nil
+}
+
+//Discover 恢复钱包时按顺序派生链chain上的地址，直到连续gapLimit个地址都没有被used判定为用过
+//最后一个用过的地址及其之前的地址全部加入钱包文件，返回加入的地址个数
+//只有旧版本编码的公钥用过的地址仍以旧版本编码加入，以便花费其上的币；找零地址归入收款链上第一个地址的账户，因此先恢复收款链
+func (ws *Wallets) Discover(chain uint32, gapLimit int, used func(pubKeyHash []byte) bool) (int, error) {
+	if gapLimit <= 0 {
+		gapLimit = DefaultGapLimit
+	}
+
+	end := uint32(0) //最后一个用过的地址的索引加1
+	legacy := make(map[uint32]bool)
+	for index, gap := uint32(0), 0; gap < gapLimit; index++ {
+		w, err := ws.DeriveWallet(chain, index)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case used(HashPubKey(w.PublicKey)):
+			end, gap = index+1, 0
+		case used(HashPubKey(LegacyPubKey(&w.PrivateKey.PublicKey))):
+			end, gap = index+1, 0
+			legacy[index] = true
+		default:
+			gap++
+		}
+	}
+
+	added := 0
+	for {
+		if ws.HD.Next(chain) >= end {
+			break
+		}
+		if _, err := ws.newAddress(chain, legacy[ws.HD.Next(chain)]); err != nil {
+			return added, err
+		}
+		added++
+	}
+	if chain == ChangeChain {
+		ws.setHDChangeAccounts()
+	}
+
+	return added, nil
+}
+
+//addWallet 将钱包加入钱包文件，钱包文件已加密时用主密钥加密其私钥
+func (ws *Wallets) addWallet(w *Wallet) error {
+	if ws.IsEncrypted() {
+		key := lookupKey(ws.file)
+		if key == nil {
+			return ErrWalletLocked
+		}
+		defer wipe(key)
+		if err := w.encryptKey(key); err != nil {
+			return err
+		}
+	}
+	ws.Wallets[string(w.GetAddress())] = w
+
+	return nil
+}
+
+//encryptSeed 用主密钥加密种子
+func (hd *HDChain) encryptSeed(key []byte) error {
+	if hd.Seed == nil {
+		return ErrWalletLocked
+	}
+	sealed, err := seal(key, hd.Seed, []byte("hd seed"))
+	if err != nil {
+		return err
+	}
+	hd.EncryptedSeed = sealed
+
+	return nil
+}
+
+//decryptSeed 用主密钥解密种子
+func (hd *HDChain) decryptSeed(key []byte) error {
+	seed, err := open(key, hd.EncryptedSeed, []byte("hd seed"))
+	if err != nil {
+		return errors.New("ERROR: 钱包种子解密失败")
+	}
+	hd.Seed = seed
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package wallet
+
+import (
+	"crypto/elliptic"
+	"encoding/hex"
+	"testing"
+)
+
+//slip10Vector SLIP-0010 nist256p1的一组测试向量：路径上每一级的链码、私钥与压缩公钥
+type slip10Vector struct {
+	path      []uint32
+	chainCode string
+	key       string
+	pubKey    string
+}
+
+//checkSLIP10 由种子按向量的路径逐级派生，并与向量比较
+func checkSLIP10(t *testing.T, seedHex string, vectors []slip10Vector) {
+	seed, err := hex.DecodeString(seedHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	curve := elliptic.P256()
+	for _, v := range vectors {
+		k := NewMasterKey(seed)
+		for _, index := range v.path {
+			k = k.Child(index)
+		}
+		x, y := curve.ScalarBaseMult(k.Key)
+		pubKey := elliptic.MarshalCompressed(curve, x, y)
+
+		if hex.EncodeToString(k.ChainCode) != v.chainCode {
+			t.Errorf("路径%v的链码为%x，应为%s", v.path, k.ChainCode, v.chainCode)
+		}
+		if hex.EncodeToString(k.Key) != v.key {
+			t.Errorf("路径%v的私钥为%x，应为%s", v.path, k.Key, v.key)
+		}
+		if hex.EncodeToString(pubKey) != v.pubKey {
+			t.Errorf("路径%v的公钥为%x，应为%s", v.path, pubKey, v.pubKey)
+		}
+	}
+}
+
+//TestSLIP10Vector1 SLIP-0010 nist256p1测试向量1
+func TestSLIP10Vector1(t *testing.T) {
+	h := HardenedOffset
+	checkSLIP10(t, "000102030405060708090a0b0c0d0e0f", []slip10Vector{
+		{nil,
+			"beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea",
+			"612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2",
+			"0266874dc6ade47b3ecd096745ca09bcd29638dd52c2c12117b11ed3e458cfa9e8"},
+		{[]uint32{h + 0},
+			"3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11",
+			"6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c",
+			"0384610f5ecffe8fda089363a41f56a5c7ffc1d81b59a612d0d649b2d22355590c"},
+		{[]uint32{h + 0, 1},
+			"4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c",
+			"284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129",
+			"03526c63f8d0b4bbbf9c80df553fe66742df4676b241dabefdef67733e070f6844"},
+		{[]uint32{h + 0, 1, h + 2},
+			"98c7514f562e64e74170cc3cf304ee1ce54d6b6da4f880f313e8204c2a185318",
+			"694596e8a54f252c960eb771a3c41e7e32496d03b954aeb90f61635b8e092aa7",
+			"0359cf160040778a4b14c5f4d7b76e327ccc8c4a6086dd9451b7482b5a4972dda0"},
+		{[]uint32{h + 0, 1, h + 2, 2},
+			"ba96f776a5c3907d7fd48bde5620ee374d4acfd540378476019eab70790c63a0",
+			"5996c37fd3dd2679039b23ed6f70b506c6b56b3cb5e424681fb0fa64caf82aaa",
+			"029f871f4cb9e1c97f9f4de9ccd0d4a2f2a171110c61178f84430062230833ff20"},
+		{[]uint32{h + 0, 1, h + 2, 2, 1000000000},
+			"b9b7b82d326bb9cb5b5b121066feea4eb93d5241103c9e7a18aad40f1dde8059",
+			"21c4f269ef0a5fd1badf47eeacebeeaa3de22eb8e5b0adcd0f27dd99d34d0119",
+			"02216cd26d31147f72427a453c443ed2cde8a1e53c9cc44e5ddf739725413fe3f4"},
+	})
+}
+
+//TestSLIP10DerivationRetry SLIP-0010 nist256p1派生重试的测试向量：m/28578'/33941的第一次结果不合法，需要重新计算
+func TestSLIP10DerivationRetry(t *testing.T) {
+	checkSLIP10(t, "000102030405060708090a0b0c0d0e0f", []slip10Vector{
+		{[]uint32{HardenedOffset + 28578},
+			"e94c8ebe30c2250a14713212f6449b20f3329105ea15b652ca5bdfc68f6c65c2",
+			"06f0db126f023755d0b8d86d4591718a5210dd8d024e3e14b6159d63f53aa669",
+			"02519b5554a4872e8c9c1c847115363051ec43e93400e030ba3c36b52a3e70a5b7"},
+		{[]uint32{HardenedOffset + 28578, 33941},
+			"9e87fe95031f14736774cd82f25fd885065cb7c358c1edf813c72af535e83071",
+			"092154eed4af83e078ff9b84322015aefe5769e31270f62c3f66c33888335f3a",
+			"0235bfee614c0d5b2cae260000bb1d0d84b270099ad790022c1ae0b2e782efe120"},
+	})
+}
+
+//TestSLIP10MasterKeyRetry SLIP-0010 nist256p1主密钥重试的测试向量：第一次HMAC得到的私钥不合法
+func TestSLIP10MasterKeyRetry(t *testing.T) {
+	checkSLIP10(t, "a7305bc8df8d0951f0cb224c0e95d7707cbdf2c6ce7e8d481fec69c7ff5e9446", []slip10Vector{
+		{nil,
+			"7762f9729fed06121fd13f326884c82f59aa95c57ac492ce8c9654e60efd130c",
+			"3b8c18469a4634517d6d0b65448f8e6c62091b45540a1743c5846be55d47d88f",
+			"0383619fadcde31063d8c5cb00dbfe1713f3e6fa169d8541a798752a1c1ca0cb20"},
+	})
+}
@@ -0,0 +1,179 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+)
+
+//公钥编码：新生成的公钥为SEC1压缩格式（0x02/0x03||X），也接受SEC1非压缩格式（0x04||X||Y）
+//旧版本的公钥为X.Bytes()||Y.Bytes()，坐标有前导零时长度不足64字节，只能逐个尝试分割位置解析
+const (
+	PubKeyCompressedLen   = 33
+	PubKeyUncompressedLen = 65
+)
+
+//signatureLen 定长签名r||s的长度，r、s各占32字节
+const signatureLen = 64
+
+//MarshalPubKey 将公钥编码为33字节的SEC1压缩格式
+func MarshalPubKey(pub *ecdsa.PublicKey) []byte {
+	return elliptic.MarshalCompressed(pub.Curve, pub.X, pub.Y)
+}
+
+//LegacyPubKey 返回公钥旧版本的编码X.Bytes()||Y.Bytes()，用于找到旧版本地址上的币
+func LegacyPubKey(pub *ecdsa.PublicKey) []byte {
+	return append(pub.X.Bytes(), pub.Y.Bytes()...)
+}
+
+//IsLegacyPubKey 公钥是否为旧版本的X.Bytes()||Y.Bytes()编码
+//SEC1编码的长度为33或65字节，旧版本的编码不超过64字节，按长度即可区分
+func IsLegacyPubKey(public []byte) bool {
+	switch len(public) {
+	case PubKeyCompressedLen:
+		return public[0] != 0x02 && public[0] != 0x03
+	case PubKeyUncompressedLen:
+		return public[0] != 0x04
+	}
+	return true
+}
+
+//ParsePubKey 解析公钥，支持SEC1压缩、非压缩格式与旧版本的编码
+func ParsePubKey(public []byte) (*ecdsa.PublicKey, error) {
+	curve := elliptic.P256()
+
+	var x, y *big.Int
+	if IsLegacyPubKey(public) {
+		x, y = parseLegacyPubKey(public)
+	} else if len(public) == PubKeyCompressedLen {
+		x, y = elliptic.UnmarshalCompressed(curve, public)
+	} else {
+		x, y = elliptic.Unmarshal(curve, public)
+	}
+	if x == nil {
+		return nil, errors.New("ERROR: 公钥不是P256曲线上的点")
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+//parseLegacyPubKey 旧版本的公钥为X、Y坐标去掉前导零后拼接而成，逐个尝试分割位置，返回曲线上的点
+func parseLegacyPubKey(public []byte) (*big.Int, *big.Int) {
+	curve := elliptic.P256()
+	size := (curve.Params().BitSize + 7) / 8
+	if len(public) > 2*size {
+		return nil, nil
+	}
+
+	for i := len(public) - size; i <= size; i++ {
+		if i <= 0 || i >= len(public) {
+			continue
+		}
+		x := new(big.Int).SetBytes(public[:i])
+		y := new(big.Int).SetBytes(public[i:])
+		if curve.IsOnCurve(x, y) {
+			return x, y
+		}
+	}
+	return nil, nil
+}
+
+//Sign 用私钥对数据的sha256哈希签名：P256私钥返回DER编码的签名，secp256k1私钥返回可恢复签名
+//ECDSA只使用被签名数据的前32字节，因此必须先哈希，不能直接对数据签名
+func Sign(priv *ecdsa.PrivateKey, data []byte) ([]byte, error) {
+	if IsSecp256k1(&priv.PublicKey) {
+		return SignRecoverable(priv, data)
+	}
+	hash := sha256.Sum256(data)
+	return ecdsa.SignASN1(rand.Reader, priv, hash[:])
+}
+
+//ecdsaSignature DER编码的签名
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+//VerifySignature 用公钥public验证对数据data的sha256哈希的签名，即Sign生成的签名
+//签名可以是DER编码、定长的r||s，或旧版本的r.Bytes()||s.Bytes()（r、s有前导零时逐个尝试分割位置）
+func VerifySignature(public, data, signature []byte) bool {
+	hash := sha256.Sum256(data)
+	return verifyDigest(public, hash[:], signature)
+}
+
+//VerifyUnhashedSignature 用公钥public验证旧版本直接对数据data（不先哈希）的签名
+//这样的签名只覆盖数据的前32字节，只能用于校验哈希签名激活之前的区块
+func VerifyUnhashedSignature(public, data, signature []byte) bool {
+	return verifyDigest(public, data, signature)
+}
+
+//verifyDigest 用公钥public验证对digest的签名，签名的编码同VerifySignature
+func verifyDigest(public, digest, signature []byte) bool {
+	pub, err := ParsePubKey(public)
+	if err != nil || len(signature) == 0 {
+		return false
+	}
+
+	var der ecdsaSignature
+	if rest, err := asn1.Unmarshal(signature, &der); err == nil && len(rest) == 0 && der.R != nil && der.S != nil {
+		if ecdsa.Verify(pub, digest, der.R, der.S) {
+			return true
+		}
+	}
+
+	if len(signature) > signatureLen {
+		return false
+	}
+	size := signatureLen / 2
+	for i := len(signature) - size; i <= size; i++ {
+		if i <= 0 || i >= len(signature) {
+			continue
+		}
+		r := new(big.Int).SetBytes(signature[:i])
+		s := new(big.Int).SetBytes(signature[i:])
+		if ecdsa.Verify(pub, digest, r, s) {
+			return true
+		}
+	}
+	return false
+}
+
+//ConvertLegacyKeys 为每个旧版本编码公钥的钱包加入同一私钥、SEC1压缩公钥的新地址，返回旧地址到新地址的映射，之后需要调用SaveToFile保存
+//旧地址保留在钱包文件中，其上的币仍然可以花费，从旧地址转账时找零进入新的找零地址；只读地址没有私钥，不转换
+func (ws *Wallets) ConvertLegacyKeys() (map[string]string, error) {
+	var legacy []string
+	for address, w := range ws.Wallets {
+		if w.IsLegacy() && !w.WatchOnly {
+			legacy = append(legacy, address)
+		}
+	}
+
+	converted := make(map[string]string)
+	for _, address := range legacy {
+		w := ws.Wallets[address]
+		if w.IsLocked() {
+			return nil, ErrWalletLocked
+		}
+		pubKey := MarshalPubKey(&w.PrivateKey.PublicKey)
+		newAddress := string(AddressFromPubKey(pubKey))
+		if _, ok := ws.Wallets[newAddress]; !ok {
+			sibling := &Wallet{
+				PrivateKey: w.PrivateKey,
+				PublicKey:  pubKey,
+				HDPath:     w.HDPath,
+				Change:     w.Change,
+				Account:    w.Account,
+				Label:      w.Label,
+			}
+			if err := ws.addWallet(sibling); err != nil {
+				return nil, err
+			}
+		}
+		converted[address] = newAddress
+	}
+
+	return converted, nil
+}
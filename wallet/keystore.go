@@ -0,0 +1,462 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+//keystoreDir 目录形式的钱包：每个私钥一个keystore v3文件，文件名为地址.json，其余内容保存在keystoreIndex中
+//keystore文件用钱包密码加密，可以单独备份、删除，或放入以钱包密码加密的keystore文件，解锁时自动加入钱包
+const keystoreDir = "keystore_%s"
+
+//keystoreIndex 目录形式的钱包的索引文件：地址的元数据、主密钥加密的私钥副本、种子与通讯录，格式同钱包文件
+const keystoreIndex = "index.dat"
+
+//keystoreKeyLen keystore v3文件由密码派生的密钥长度：前16字节为AES-128-CTR密钥，后16字节用于计算MAC
+//目录中的keystore文件使用钱包的scrypt参数与同一个盐（见WalletCrypto.keystoreSalt），解锁时只派生一次密钥；导出的文件使用以太坊的标准参数
+const keystoreKeyLen = 32
+
+//keystore文件中私钥的曲线，以太坊的keystore文件没有该字段，为secp256k1
+const (
+	curveSecp256k1  = "secp256k1"
+	curveP256       = "p256"
+	curveP256Legacy = "p256-legacy" //旧版本编码公钥的P256私钥，导入后恢复为同一个地址
+)
+
+//keyFileJSON keystore v3文件，address为以太坊地址（secp256k1私钥），Curve与ChainAddress为本链的扩展字段
+type keyFileJSON struct {
+	Address      string              `json:"address"`
+	Crypto       keystore.CryptoJSON `json:"crypto"`
+	ID           string              `json:"id"`
+	Version      int                 `json:"version"`
+	Curve        string              `json:"curve,omitempty"`
+	ChainAddress string              `json:"chainaddress,omitempty"`
+}
+
+//EncryptKeystore 用passphrase将钱包w的私钥加密为keystore v3文件的内容
+func EncryptKeystore(w *Wallet, passphrase string, scryptN, scryptP int) ([]byte, error) {
+	if err := w.CanSign(); err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, errors.New("ERROR: 密码不能为空")
+	}
+
+	d := w.PrivateKey.D.FillBytes(make([]byte, privateKeyLen))
+	defer wipe(d)
+	cryptoJSON, err := keystore.EncryptDataV3(d, []byte(passphrase), scryptN, scryptP)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalKeyFile(w, cryptoJSON)
+}
+
+//encryptKeystoreWithKey 用由钱包密码派生的密钥key将钱包w的私钥加密为keystore v3文件的内容，kdf参数为c的scrypt参数与keystoreSalt
+//文件与EncryptKeystore生成的文件格式相同，可以用钱包密码单独解密
+func encryptKeystoreWithKey(w *Wallet, key []byte, c *WalletCrypto) ([]byte, error) {
+	if err := w.CanSign(); err != nil {
+		return nil, err
+	}
+	if len(key) != keystoreKeyLen {
+		return nil, ErrWalletLocked
+	}
+
+	d := w.PrivateKey.D.FillBytes(make([]byte, privateKeyLen))
+	defer wipe(d)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key[:16])
+	if err != nil {
+		return nil, err
+	}
+	cipherText := make([]byte, len(d))
+	cipher.NewCTR(block, iv).XORKeyStream(cipherText, d)
+
+	//keystore.CryptoJSON的cipherparams字段类型未导出，由JSON构造
+	raw, err := json.Marshal(map[string]interface{}{
+		"cipher":       "aes-128-ctr",
+		"ciphertext":   hex.EncodeToString(cipherText),
+		"cipherparams": map[string]string{"iv": hex.EncodeToString(iv)},
+		"kdf":          "scrypt",
+		"kdfparams":    map[string]interface{}{"n": c.N, "r": c.R, "p": c.P, "dklen": keystoreKeyLen, "salt": hex.EncodeToString(c.keystoreSalt())},
+		"mac":          hex.EncodeToString(crypto.Keccak256(key[16:keystoreKeyLen], cipherText)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var cryptoJSON keystore.CryptoJSON
+	if err := json.Unmarshal(raw, &cryptoJSON); err != nil {
+		return nil, err
+	}
+
+	return marshalKeyFile(w, cryptoJSON)
+}
+
+//marshalKeyFile 将钱包w加密后的私钥cryptoJSON编码为keystore v3文件的内容
+func marshalKeyFile(w *Wallet, cryptoJSON keystore.CryptoJSON) ([]byte, error) {
+	kf := keyFileJSON{Crypto: cryptoJSON, ID: newUUID(), Version: 3, ChainAddress: string(w.GetAddress())}
+	switch {
+	case w.Secp256k1:
+		kf.Address = hex.EncodeToString(crypto.PubkeyToAddress(w.PrivateKey.PublicKey).Bytes())
+		kf.Curve = curveSecp256k1
+	case w.IsLegacy():
+		kf.Curve = curveP256Legacy
+	default:
+		kf.Curve = curveP256
+	}
+
+	return json.MarshalIndent(kf, "", "  ")
+}
+
+//DecryptKeystore 用passphrase解密keystore v3文件，返回其中私钥的钱包
+//没有curve字段的文件（以太坊钱包导出的文件）按secp256k1私钥处理
+func DecryptKeystore(content []byte, passphrase string) (*Wallet, error) {
+	kf, err := unmarshalKeyFile(content)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := keystore.DecryptDataV3(kf.Crypto, passphrase)
+	if err == keystore.ErrDecrypt {
+		return nil, errors.New("ERROR: keystore文件的密码错误")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: keystore文件解密失败: %v", err)
+	}
+	defer wipe(d)
+
+	return walletFromKeyFile(kf, d)
+}
+
+//decryptKeystoreWithKey 用由钱包密码派生的密钥key解密encryptKeystoreWithKey生成的keystore v3文件
+//kdf参数不是c的scrypt参数与keystoreSalt的文件（如用钱包密码导出的文件）无法用key解密，需要在解锁时用密码解密
+func decryptKeystoreWithKey(content, key []byte, c *WalletCrypto) (*Wallet, error) {
+	kf, err := unmarshalKeyFile(content)
+	if err != nil {
+		return nil, err
+	}
+	if !c.matchesKeyFile(kf) {
+		return nil, errors.New("ERROR: keystore文件的加密参数与钱包不同，需要解锁钱包时用密码解密")
+	}
+
+	mac, err := hex.DecodeString(kf.Crypto.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: keystore文件格式错误: %v", err)
+	}
+	iv, err := hex.DecodeString(kf.Crypto.CipherParams.IV)
+	if err != nil || len(iv) != aes.BlockSize {
+		return nil, errors.New("ERROR: keystore文件格式错误: iv")
+	}
+	cipherText, err := hex.DecodeString(kf.Crypto.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: keystore文件格式错误: %v", err)
+	}
+	if !bytes.Equal(crypto.Keccak256(key[16:keystoreKeyLen], cipherText), mac) {
+		return nil, errors.New("ERROR: keystore文件的密码错误")
+	}
+
+	block, err := aes.NewCipher(key[:16])
+	if err != nil {
+		return nil, err
+	}
+	d := make([]byte, len(cipherText))
+	cipher.NewCTR(block, iv).XORKeyStream(d, cipherText)
+	defer wipe(d)
+
+	return walletFromKeyFile(kf, d)
+}
+
+//matchesKeyFile keystore文件kf是否用c的scrypt参数与keystoreSalt加密，即可以用钱包的keystore文件密钥解密
+func (c *WalletCrypto) matchesKeyFile(kf *keyFileJSON) bool {
+	params := kf.Crypto.KDFParams
+	return kf.Crypto.KDF == "scrypt" && kf.Crypto.Cipher == "aes-128-ctr" && params["salt"] == hex.EncodeToString(c.keystoreSalt()) &&
+		params["n"] == float64(c.N) && params["r"] == float64(c.R) && params["p"] == float64(c.P) && params["dklen"] == float64(keystoreKeyLen)
+}
+
+//unmarshalKeyFile 解析keystore v3文件
+func unmarshalKeyFile(content []byte) (*keyFileJSON, error) {
+	var kf keyFileJSON
+	if err := json.Unmarshal(content, &kf); err != nil {
+		return nil, fmt.Errorf("ERROR: keystore文件格式错误: %v", err)
+	}
+	if kf.Version != 3 {
+		return nil, fmt.Errorf("ERROR: 不支持版本%d的keystore文件，只支持版本3", kf.Version)
+	}
+
+	return &kf, nil
+}
+
+//walletFromKeyFile 由keystore文件kf解密出的私钥d构造钱包，并校验文件记录的地址
+func walletFromKeyFile(kf *keyFileJSON, d []byte) (*Wallet, error) {
+	if len(d) != privateKeyLen {
+		return nil, fmt.Errorf("ERROR: keystore文件中的私钥长度为%d字节，应为%d字节", len(d), privateKeyLen)
+	}
+
+	var w *Wallet
+	var err error
+	switch kf.Curve {
+	case "", curveSecp256k1:
+		w, err = newSecp256k1WalletFromKey(d)
+	case curveP256, curveP256Legacy:
+		w, err = NewWalletFromPrivateKey(hex.EncodeToString(d))
+		if err == nil && kf.Curve == curveP256Legacy {
+			w.PublicKey = LegacyPubKey(&w.PrivateKey.PublicKey)
+		}
+	default:
+		return nil, fmt.Errorf("ERROR: 不支持的曲线%q", kf.Curve)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if kf.ChainAddress != "" && kf.ChainAddress != string(w.GetAddress()) {
+		return nil, fmt.Errorf("ERROR: keystore文件记录的地址%s与私钥的地址%s不符", kf.ChainAddress, w.GetAddress())
+	}
+
+	return w, nil
+}
+
+//ExportKeystore 用passphrase将地址address的私钥导出为keystore v3文件的内容，钱包已锁定或是只读地址时返回错误
+func (ws *Wallets) ExportKeystore(address, passphrase string) ([]byte, error) {
+	w, err := ws.SigningWallet(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return EncryptKeystore(w, passphrase, keystore.StandardScryptN, keystore.StandardScryptP)
+}
+
+//ImportKeystore 用passphrase解密keystore v3文件并将私钥加入钱包文件，返回对应的地址，之后需要调用SaveToFile保存
+//地址已作为只读地址导入时替换为带私钥的钱包；钱包文件已加密时必须先解锁
+func (ws *Wallets) ImportKeystore(content []byte, passphrase string) (string, error) {
+	w, err := DecryptKeystore(content, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	return ws.importKey(w)
+}
+
+//IsKeystoreDir 钱包是否为目录形式，每个私钥保存为一个keystore文件
+func (ws *Wallets) IsKeystoreDir() bool {
+	return ws.dir != ""
+}
+
+//MigrateToKeystoreDir 将钱包name的钱包文件转换为目录形式，原钱包文件改名为.bak保留
+//未加密的钱包文件用passphrase加密，已加密的钱包文件passphrase必须是钱包密码
+func MigrateToKeystoreDir(name, passphrase string) error {
+	dir := WalletPath(keystoreDir, name)
+	if isDir(dir) {
+		return fmt.Errorf("ERROR: 钱包%s已经是目录形式", name)
+	}
+	ws, err := NewWallets(name)
+	if err != nil {
+		return err
+	}
+
+	if !ws.IsEncrypted() {
+		if err := ws.Encrypt(passphrase); err != nil {
+			return err
+		}
+	} else {
+		key, err := ws.Crypto.verify(passphrase)
+		if err != nil {
+			return err
+		}
+		err = ws.decryptAll(key)
+		wipe(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	keystoreKey, err := ws.Crypto.deriveKeystoreKey(passphrase)
+	if err != nil {
+		return err
+	}
+	defer wipe(keystoreKey)
+
+	oldFile := ws.file
+	dropKey(oldFile)
+	ws.dir, ws.file, ws.keystoreKey, ws.rewrite = dir, dir, keystoreKey, true
+	ws.saveKeystoreDir()
+
+	return os.Rename(oldFile, oldFile+".bak")
+}
+
+//loadKeystoreDir 从目录读取钱包：索引文件中的地址，其keystore文件已被删除的私钥不再加入
+func (ws *Wallets) loadKeystoreDir(dir string) error {
+	fileContent, err := ioutil.ReadFile(filepath.Join(dir, keystoreIndex))
+	if err != nil {
+		return err
+	}
+	ws.decode(fileContent, dir)
+	ws.dir = dir
+
+	for address, w := range ws.Wallets {
+		if w.WatchOnly {
+			continue
+		}
+		if _, err := os.Stat(keyFilePath(dir, address)); os.IsNotExist(err) {
+			delete(ws.Wallets, address)
+		}
+	}
+
+	//钱包已在本进程中解锁时加入新放入目录的keystore文件
+	if !ws.IsLocked() {
+		ws.adoptKeyFiles("")
+	}
+
+	return nil
+}
+
+//saveKeystoreDir 为还没有keystore文件的私钥写入文件（修改密码后重写全部文件），再写入索引文件
+func (ws Wallets) saveKeystoreDir() {
+	if err := os.MkdirAll(ws.dir, 0700); err != nil {
+		log.Panic(err)
+	}
+
+	key := ws.keystoreDerivedKey()
+	defer wipe(key)
+	for address, w := range ws.Wallets {
+		if w.WatchOnly {
+			continue
+		}
+		path := keyFilePath(ws.dir, address)
+		if _, err := os.Stat(path); err == nil && !ws.rewrite {
+			continue
+		}
+		if key == nil {
+			log.Panic(ErrWalletLocked)
+		}
+		content, err := encryptKeystoreWithKey(w, key, ws.Crypto)
+		if err != nil {
+			log.Panic(err)
+		}
+		if err := ioutil.WriteFile(path, content, 0600); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(ws.dir, keystoreIndex), ws.encode(), 0600); err != nil {
+		log.Panic(err)
+	}
+}
+
+//adoptKeyFiles 将目录中不属于任何地址的keystore文件用钱包密码解密后加入钱包，改名为地址.json并保存索引文件
+//解锁时passphrase为钱包密码；之后读取钱包时passphrase为空，只能解密用钱包的keystore文件密钥加密的文件
+//密码不同的文件保持不动，需要用importkeystore指定其密码导入；返回加入的私钥个数
+func (ws *Wallets) adoptKeyFiles(passphrase string) int {
+	key := ws.keystoreDerivedKey()
+	defer wipe(key)
+	if passphrase == "" && key == nil {
+		return 0
+	}
+	files, err := filepath.Glob(filepath.Join(ws.dir, "*.json"))
+	if err != nil {
+		log.Panic(err)
+	}
+
+	adopted := 0
+	for _, file := range files {
+		if _, ok := ws.Wallets[strings.TrimSuffix(filepath.Base(file), ".json")]; ok {
+			continue
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Panic(err)
+		}
+		var w *Wallet
+		if passphrase != "" {
+			w, err = DecryptKeystore(content, passphrase)
+		} else {
+			w, err = decryptKeystoreWithKey(content, key, ws.Crypto)
+		}
+		if err != nil {
+			log.Printf("keystore文件%s未加入钱包: %v", file, err)
+			continue
+		}
+		address := string(w.GetAddress())
+		if old, ok := ws.Wallets[address]; ok && !old.WatchOnly {
+			continue
+		}
+		if _, err := ws.importKey(w); err != nil {
+			log.Panic(err)
+		}
+		if err := os.Rename(file, keyFilePath(ws.dir, address)); err != nil {
+			log.Panic(err)
+		}
+		adopted++
+	}
+	if adopted > 0 {
+		ws.saveKeystoreDir()
+	}
+
+	return adopted
+}
+
+//upgradeKeyFiles 目录中有不能用keystore文件密钥解密的文件（旧版本用较轻的scrypt参数加密）时，重写全部keystore文件，钱包解锁后调用
+func (ws *Wallets) upgradeKeyFiles() {
+	for address, w := range ws.Wallets {
+		if w.WatchOnly {
+			continue
+		}
+		content, err := ioutil.ReadFile(keyFilePath(ws.dir, address))
+		if err != nil {
+			continue
+		}
+		if kf, err := unmarshalKeyFile(content); err == nil && !ws.Crypto.matchesKeyFile(kf) {
+			ws.rewrite = true
+			ws.saveKeystoreDir()
+			ws.rewrite = false
+			return
+		}
+	}
+}
+
+//keystoreDerivedKey 返回写入keystore文件的密钥：ChangePassphrase等由新密码派生的密钥，或钱包解锁时保存的密钥，都没有时返回nil
+func (ws Wallets) keystoreDerivedKey() []byte {
+	if ws.keystoreKey != nil {
+		return append([]byte(nil), ws.keystoreKey...)
+	}
+	return lookupKeystoreKey(ws.file)
+}
+
+//keyFilePath 返回目录dir中地址address的keystore文件名
+func keyFilePath(dir, address string) string {
+	return filepath.Join(dir, address+".json")
+}
+
+//isDir 路径是否为已存在的目录
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+//newUUID 生成keystore文件的随机id（UUID版本4）
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,107 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+//messagePrefix 签名消息的域分隔前缀，保证消息签名不能被当作交易签名或其他用途的签名使用
+const messagePrefix = "fishmanchain Signed Message:\n"
+
+//messageSigLen 消息签名中r、s各占32字节
+const messageSigLen = 32
+
+//MessageSigningData 返回消息的签名数据：前缀||8字节大端序的消息长度||消息
+func MessageSigningData(message string) []byte {
+	length := make([]byte, 8)
+	binary.BigEndian.PutUint64(length, uint64(len(message)))
+
+	data := append([]byte(messagePrefix), length...)
+
+	return append(data, message...)
+}
+
+//MessageHash 计算消息的哈希：sha256(MessageSigningData(message))
+func MessageHash(message string) []byte {
+	hash := sha256.Sum256(MessageSigningData(message))
+
+	return hash[:]
+}
+
+//SignMessage 用钱包的私钥签名消息，返回base64编码的r||s||公钥
+//地址只是公钥哈希，签名中带上公钥，验证时由公钥算出地址；secp256k1的钱包返回可恢复签名，公钥由签名恢复
+func (w Wallet) SignMessage(message string) (string, error) {
+	if err := w.CanSign(); err != nil {
+		return "", err
+	}
+	if w.Secp256k1 {
+		signature, err := crypto.Sign(MessageHash(message), &w.PrivateKey)
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(signature), nil
+	}
+
+	r, s, err := ecdsa.Sign(rand.Reader, &w.PrivateKey, MessageHash(message))
+	if err != nil {
+		return "", err
+	}
+	signature := append(r.FillBytes(make([]byte, messageSigLen)), s.FillBytes(make([]byte, messageSigLen))...)
+	signature = append(signature, w.PublicKey...)
+
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+//SignMessage 用钱包文件中地址address的私钥签名消息
+func (ws *Wallets) SignMessage(address, message string) (string, error) {
+	w, err := ws.SigningWallet(address)
+	if err != nil {
+		return "", err
+	}
+
+	return w.SignMessage(message)
+}
+
+//VerifyMessage 验证签名signature是地址address的私钥对消息message的签名
+//签名格式错误时返回错误，签名与地址或消息不符时返回false
+func VerifyMessage(address, signature, message string) (bool, error) {
+	if !ValidateAddress(address) {
+		return false, errors.New("ERROR: 地址非法")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil || len(sig) <= 2*messageSigLen {
+		return false, errors.New("ERROR: 签名格式错误")
+	}
+	if IsSecp256k1Address(address) {
+		if len(sig) != RecoverableSignatureLen {
+			return false, errors.New("ERROR: secp256k1地址的签名应为65字节的可恢复签名")
+		}
+		pub, err := crypto.SigToPub(MessageHash(message), sig)
+		if err != nil {
+			return false, nil
+		}
+		return bytes.Equal(HashPubKey(crypto.CompressPubkey(pub)), AddressToPubKeyHash([]byte(address))), nil
+	}
+	public := sig[2*messageSigLen:]
+	pubKey, err := ParsePubKey(public)
+	if err != nil {
+		return false, err
+	}
+
+	if !bytes.Equal(HashPubKey(public), AddressToPubKeyHash([]byte(address))) {
+		return false, nil
+	}
+	r := new(big.Int).SetBytes(sig[:messageSigLen])
+	s := new(big.Int).SetBytes(sig[messageSigLen : 2*messageSigLen])
+
+	return ecdsa.Verify(pubKey, MessageHash(message), r, s), nil
+}
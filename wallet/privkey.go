@@ -0,0 +1,129 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/mr-tron/base58"
+)
+
+//privateKeyVersion 私钥编码的版本号，与地址的版本号区分，编码结果以5开头
+const privateKeyVersion = byte(0x80)
+
+//privateKeyLen 私钥固定为32字节，不足时前面补零
+const privateKeyLen = 32
+
+//compressedFlag 私钥之后带有该字节时，对应的公钥为SEC1压缩格式，否则为旧版本的编码
+const compressedFlag = byte(0x01)
+
+//secp256k1Flag 私钥之后带有该字节时，私钥在secp256k1曲线上，公钥为SEC1压缩格式
+const secp256k1Flag = byte(0x02)
+
+//EncodePrivateKey 将私钥编码为Base58字符串：版本号||32字节私钥||压缩标志（可选）||4字节校验码
+//compressed为false表示旧版本编码的公钥，导入时恢复为同一个地址
+func EncodePrivateKey(d *big.Int, compressed bool) string {
+	if compressed {
+		return encodePrivateKey(d, []byte{compressedFlag})
+	}
+	return encodePrivateKey(d, nil)
+}
+
+//EncodeSecp256k1PrivateKey 将secp256k1私钥编码为Base58字符串，格式同EncodePrivateKey，标志为secp256k1Flag
+func EncodeSecp256k1PrivateKey(d *big.Int) string {
+	return encodePrivateKey(d, []byte{secp256k1Flag})
+}
+
+//encodePrivateKey 编码版本号||32字节私钥||flag||4字节校验码
+func encodePrivateKey(d *big.Int, flag []byte) string {
+	payload := append([]byte{privateKeyVersion}, d.FillBytes(make([]byte, privateKeyLen))...)
+	payload = append(payload, flag...)
+	payload = append(payload, checksum(payload)...)
+	encoded := base58.Encode(payload)
+	wipe(payload)
+
+	return encoded
+}
+
+//DecodePrivateKey 解码EncodePrivateKey编码的私钥，校验版本号、校验码与私钥范围
+func DecodePrivateKey(encoded string) (*Wallet, error) {
+	payload, err := base58.Decode(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errors.New("ERROR: 私钥编码含有非法字符")
+	}
+	defer wipe(payload)
+	legacyLen := 1 + privateKeyLen + addressChecksumLen
+	if len(payload) != legacyLen && len(payload) != legacyLen+1 {
+		return nil, fmt.Errorf("ERROR: 私钥编码长度错误，应为%d或%d字节，实际为%d字节", legacyLen, legacyLen+1, len(payload))
+	}
+	body := payload[:len(payload)-addressChecksumLen]
+	if !bytes.Equal(payload[len(body):], checksum(body)) {
+		return nil, errors.New("ERROR: 私钥校验码错误，请检查是否抄写有误")
+	}
+	if body[0] != privateKeyVersion {
+		return nil, fmt.Errorf("ERROR: 不支持的私钥版本号0x%02x", body[0])
+	}
+	compressed := len(body) == 1+privateKeyLen+1
+	if compressed && body[len(body)-1] != compressedFlag && body[len(body)-1] != secp256k1Flag {
+		return nil, fmt.Errorf("ERROR: 不支持的公钥压缩标志0x%02x", body[len(body)-1])
+	}
+
+	d := body[1 : 1+privateKeyLen]
+	if compressed && body[len(body)-1] == secp256k1Flag {
+		return newSecp256k1WalletFromKey(d)
+	}
+	k := new(big.Int).SetBytes(d)
+	if k.Sign() == 0 || k.Cmp(elliptic.P256().Params().N) >= 0 {
+		return nil, errors.New("ERROR: 私钥超出范围")
+	}
+
+	w := newWalletFromKey(d)
+	if !compressed {
+		w.PublicKey = LegacyPubKey(&w.PrivateKey.PublicKey)
+	}
+	return w, nil
+}
+
+//ImportPrivateKey 将编码的私钥加入钱包文件，返回对应的地址，之后需要调用SaveToFile保存
+//地址已作为只读地址导入时替换为带私钥的钱包；钱包文件已加密时必须先解锁
+func (ws *Wallets) ImportPrivateKey(encoded string) (string, error) {
+	w, err := DecodePrivateKey(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	return ws.importKey(w)
+}
+
+//importKey 将带私钥的钱包加入钱包文件，返回对应的地址；地址已作为只读地址导入时替换之
+func (ws *Wallets) importKey(w *Wallet) (string, error) {
+	address := string(w.GetAddress())
+	old := ws.FindByPubKeyHash(w.GetPubKeyHash())
+	if old != nil && !old.WatchOnly {
+		return "", fmt.Errorf("ERROR: 地址%s的私钥已在钱包文件中", address)
+	}
+	if err := ws.addWallet(w); err != nil {
+		return "", err
+	}
+	if old != nil && string(old.GetAddress()) != address { //只读地址以另一个版本号导入
+		delete(ws.Wallets, string(old.GetAddress()))
+	}
+
+	return address, nil
+}
+
+//DumpPrivateKey 返回地址address编码后的私钥，钱包已锁定或是只读地址时返回错误
+func (ws *Wallets) DumpPrivateKey(address string) (string, error) {
+	w, err := ws.SigningWallet(address)
+	if err != nil {
+		return "", err
+	}
+
+	if w.Secp256k1 {
+		return EncodeSecp256k1PrivateKey(w.PrivateKey.D), nil
+	}
+	return EncodePrivateKey(w.PrivateKey.D, !w.IsLegacy()), nil
+}
@@ -0,0 +1,118 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+//secp256k1AddressVersion secp256k1公钥的地址版本号，地址以S开头
+//secp256k1与P256的压缩公钥格式相同，只能由地址版本号区分曲线
+const secp256k1AddressVersion = byte(0x3f)
+
+//RecoverableSignatureLen secp256k1可恢复签名的长度：r||s||恢复标识v
+const RecoverableSignatureLen = crypto.SignatureLength
+
+//NewSecp256k1Wallet 创建一个secp256k1曲线的钱包，公钥为SEC1压缩格式
+func NewSecp256k1Wallet() *Wallet {
+	private, err := crypto.GenerateKey()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return &Wallet{PrivateKey: *private, PublicKey: crypto.CompressPubkey(&private.PublicKey), Secp256k1: true}
+}
+
+//newSecp256k1WalletFromKey 由私钥的字节构造secp256k1钱包
+func newSecp256k1WalletFromKey(d []byte) (*Wallet, error) {
+	private, err := crypto.ToECDSA(d)
+	if err != nil {
+		return nil, errors.New("ERROR: 私钥超出secp256k1的范围")
+	}
+
+	return &Wallet{PrivateKey: *private, PublicKey: crypto.CompressPubkey(&private.PublicKey), Secp256k1: true}, nil
+}
+
+//restoreSecp256k1Key 由钱包文件中保存的私钥D重建secp256k1私钥，私钥已加密时由公钥重建曲线与公钥坐标
+func (w *Wallet) restoreSecp256k1Key() error {
+	if w.PrivateKey.D != nil {
+		private, err := crypto.ToECDSA(w.PrivateKey.D.FillBytes(make([]byte, privateKeyLen)))
+		if err != nil {
+			return fmt.Errorf("ERROR: 地址%s的私钥超出secp256k1的范围", w.GetAddress())
+		}
+		w.PrivateKey = *private
+		return nil
+	}
+
+	var pub *ecdsa.PublicKey
+	var err error
+	switch len(w.PublicKey) {
+	case 0:
+		return nil //只导入地址的只读地址没有公钥
+	case PubKeyCompressedLen:
+		pub, err = crypto.DecompressPubkey(w.PublicKey)
+	default:
+		pub, err = crypto.UnmarshalPubkey(w.PublicKey)
+	}
+	if err != nil {
+		return fmt.Errorf("ERROR: 地址%s的secp256k1公钥格式错误", w.GetAddress())
+	}
+	w.PrivateKey.PublicKey = *pub
+
+	return nil
+}
+
+//IsSecp256k1 公钥是否在secp256k1曲线上
+func IsSecp256k1(pub *ecdsa.PublicKey) bool {
+	return pub.Curve != nil && pub.Curve.Params().P.Cmp(crypto.S256().Params().P) == 0
+}
+
+//Secp256k1PubKeyHashToAddress 将secp256k1公钥的哈希编码为Base58地址
+func Secp256k1PubKeyHashToAddress(pubKeyHash []byte) []byte {
+	return encodeAddress(secp256k1AddressVersion, pubKeyHash)
+}
+
+//IsSecp256k1Address 地址是否为secp256k1公钥的地址
+func IsSecp256k1Address(address string) bool {
+	return ValidateAddress(address) && Base58Decode([]byte(address))[0] == secp256k1AddressVersion
+}
+
+//SignRecoverable 用secp256k1私钥对数据的sha256哈希签名，返回65字节的可恢复签名
+//由签名和数据即可恢复出公钥，交易输入不必再带公钥
+func SignRecoverable(priv *ecdsa.PrivateKey, data []byte) ([]byte, error) {
+	hash := sha256.Sum256(data)
+	return crypto.Sign(hash[:], priv)
+}
+
+//RecoverPubKey 由可恢复签名与被签名的数据恢复secp256k1公钥，返回SEC1压缩格式
+func RecoverPubKey(data, signature []byte) ([]byte, error) {
+	if len(signature) != RecoverableSignatureLen {
+		return nil, errors.New("ERROR: 可恢复签名的长度错误")
+	}
+	hash := sha256.Sum256(data)
+	pub, err := crypto.SigToPub(hash[:], signature)
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.CompressPubkey(pub), nil
+}
+
+//CreateSecp256k1Wallet 随机生成一个secp256k1私钥加入钱包文件，返回地址，之后需要调用SaveToFile保存
+//分层确定性钱包的地址只能由种子派生P256私钥，无法由助记词恢复的随机私钥不加入其中
+func (ws *Wallets) CreateSecp256k1Wallet() (string, error) {
+	if ws.IsHD() {
+		return "", errors.New("ERROR: 分层确定性钱包只能派生P256地址，secp256k1地址请使用另一个钱包文件")
+	}
+
+	w := NewSecp256k1Wallet()
+	if err := ws.addWallet(w); err != nil {
+		return "", err
+	}
+
+	return string(w.GetAddress()), nil
+}
@@ -0,0 +1,232 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+//shareVersion 备份分片编码的版本号
+const shareVersion = byte(0x01)
+
+//shareHeaderLen 分片头部：版本号||门限||分片序号||备份编号(4)||地址指纹(4)
+const shareHeaderLen = 3 + 4 + 4
+
+//wordBits 助记词格式的分片每个单词表示11位
+const wordBits = 11
+
+//Share Shamir秘密分享的一个分片，门限个不同序号的分片即可恢复秘密
+type Share struct {
+	Threshold   byte
+	Index       byte   //分片序号，即多项式的横坐标，从1开始
+	SetID       []byte //同一次备份的分片编号相同，防止混用不同备份的分片
+	Fingerprint []byte //恢复出的钱包全部地址的指纹，用于校验恢复结果
+	Data        []byte
+}
+
+//gfExp、gfLog GF(2^8)（多项式x^8+x^4+x^3+x+1）以3为生成元的指数表与对数表
+var gfExp, gfLog [256]byte
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfLog[x] = byte(i)
+		x ^= gfDouble(x) //乘以3
+	}
+	gfExp[255] = gfExp[0]
+}
+
+//gfDouble GF(2^8)中乘以2
+func gfDouble(a byte) byte {
+	if a&0x80 != 0 {
+		return a<<1 ^ 0x1b
+	}
+	return a << 1
+}
+
+//gfMul GF(2^8)中的乘法
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+int(gfLog[b]))%255]
+}
+
+//gfDiv GF(2^8)中的除法，b不能为0
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])-int(gfLog[b])+255)%255]
+}
+
+//SplitSecret 将秘密分为n个分片，任意threshold个分片可以恢复秘密，少于threshold个分片得不到秘密的任何信息
+//秘密的每个字节分别取一个GF(2^8)上常数项为该字节的threshold-1次随机多项式，第i个分片为多项式在x=i处的值
+func SplitSecret(secret []byte, n, threshold int, fingerprint []byte) ([]*Share, error) {
+	if threshold < 2 || threshold > n {
+		return nil, fmt.Errorf("ERROR: 门限%d必须不小于2且不大于分片数%d", threshold, n)
+	}
+	if n > 255 {
+		return nil, errors.New("ERROR: 分片数不能超过255")
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("ERROR: 没有需要备份的秘密")
+	}
+
+	setID := make([]byte, 4)
+	if _, err := rand.Read(setID); err != nil {
+		return nil, err
+	}
+	shares := make([]*Share, n)
+	for i := range shares {
+		shares[i] = &Share{Threshold: byte(threshold), Index: byte(i + 1), SetID: setID, Fingerprint: fingerprint, Data: make([]byte, len(secret))}
+	}
+
+	coeffs := make([]byte, threshold)
+	defer wipe(coeffs)
+	for j, b := range secret {
+		coeffs[0] = b
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, err
+		}
+		for _, share := range shares {
+			//秦九韶算法求多项式在x处的值
+			y := byte(0)
+			for k := threshold - 1; k >= 0; k-- {
+				y = gfMul(y, share.Index) ^ coeffs[k]
+			}
+			share.Data[j] = y
+		}
+	}
+
+	return shares, nil
+}
+
+//CombineShares 由不少于门限个属于同一次备份的分片恢复秘密（拉格朗日插值求x=0处的值）
+func CombineShares(shares []*Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("ERROR: 没有分片")
+	}
+	first := shares[0]
+	if len(shares) < int(first.Threshold) {
+		return nil, fmt.Errorf("ERROR: 需要%d个分片，只提供了%d个", first.Threshold, len(shares))
+	}
+	seen := make(map[byte]bool)
+	for _, share := range shares {
+		if !bytes.Equal(share.SetID, first.SetID) || share.Threshold != first.Threshold || len(share.Data) != len(first.Data) {
+			return nil, errors.New("ERROR: 分片不属于同一次备份")
+		}
+		if share.Index == 0 || seen[share.Index] {
+			return nil, fmt.Errorf("ERROR: 分片序号%d重复或非法", share.Index)
+		}
+		seen[share.Index] = true
+	}
+
+	shares = shares[:first.Threshold]
+	secret := make([]byte, len(first.Data))
+	for i, si := range shares {
+		//拉格朗日基函数在0处的值：∏ xj/(xj-xi)，GF(2^8)中减法即异或
+		basis := byte(1)
+		for j, sj := range shares {
+			if i != j {
+				basis = gfMul(basis, gfDiv(sj.Index, sj.Index^si.Index))
+			}
+		}
+		for k := range secret {
+			secret[k] ^= gfMul(si.Data[k], basis)
+		}
+	}
+
+	return secret, nil
+}
+
+//payload 分片的二进制编码：头部||数据||4字节校验码
+func (s *Share) payload() []byte {
+	payload := []byte{shareVersion, s.Threshold, s.Index}
+	payload = append(payload, s.SetID...)
+	payload = append(payload, s.Fingerprint...)
+	payload = append(payload, s.Data...)
+
+	return append(payload, checksum(payload)...)
+}
+
+//Hex 将分片编码为带校验码的hex字符串
+func (s *Share) Hex() string {
+	return hex.EncodeToString(s.payload())
+}
+
+//Words 将分片编码为带校验码的单词串，每个单词取自BIP39英文词表，表示11位
+func (s *Share) Words() string {
+	payload := s.payload()
+	n := new(big.Int).SetBytes(payload)
+	count := (len(payload)*8 + wordBits - 1) / wordBits
+	n.Lsh(n, uint(count*wordBits-len(payload)*8)) //末尾补零到11位的整数倍
+
+	list := bip39.GetWordList()
+	words := make([]string, count)
+	mask := big.NewInt(1<<wordBits - 1)
+	for i := count - 1; i >= 0; i-- {
+		words[i] = list[new(big.Int).And(n, mask).Int64()]
+		n.Rsh(n, wordBits)
+	}
+
+	return strings.Join(words, " ")
+}
+
+//DecodeShare 解析hex或单词格式的分片，并校验版本号与校验码
+func DecodeShare(encoded string) (*Share, error) {
+	encoded = strings.TrimSpace(encoded)
+	if payload, err := hex.DecodeString(encoded); err == nil {
+		return parseShare(payload)
+	}
+
+	words := strings.Fields(strings.ToLower(encoded))
+	n := new(big.Int)
+	for _, word := range words {
+		index, ok := bip39.GetWordIndex(word)
+		if !ok {
+			return nil, fmt.Errorf("ERROR: 分片中的单词%q不在词表中", word)
+		}
+		n.Lsh(n, wordBits)
+		n.Or(n, big.NewInt(int64(index)))
+	}
+	//补零不足8位时丢弃，达到8位时会多出一个零字节，两种长度都试一下
+	bits := len(words) * wordBits
+	for size := bits / 8; size >= bits/8-1 && size > 0; size-- {
+		payload := new(big.Int).Rsh(n, uint(bits-size*8)).FillBytes(make([]byte, size))
+		if share, err := parseShare(payload); err == nil {
+			return share, nil
+		}
+	}
+
+	return nil, errors.New("ERROR: 分片的校验码错误，请检查单词拼写与顺序")
+}
+
+//parseShare 解析分片的二进制编码
+func parseShare(payload []byte) (*Share, error) {
+	if len(payload) <= shareHeaderLen+addressChecksumLen {
+		return nil, errors.New("ERROR: 分片长度不足")
+	}
+	body, sum := payload[:len(payload)-addressChecksumLen], payload[len(payload)-addressChecksumLen:]
+	if !bytes.Equal(checksum(body), sum) {
+		return nil, errors.New("ERROR: 分片的校验码错误")
+	}
+	if body[0] != shareVersion {
+		return nil, fmt.Errorf("ERROR: 不支持版本%d的分片", body[0])
+	}
+
+	return &Share{
+		Threshold:   body[1],
+		Index:       body[2],
+		SetID:       body[3:7],
+		Fingerprint: body[7:11],
+		Data:        body[shareHeaderLen:],
+	}, nil
+}
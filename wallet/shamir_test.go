@@ -0,0 +1,121 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+//TestSplitCombineAllSubsets 任意门限个分片（以任意顺序）都恢复出原秘密，少于门限个分片时报错
+func TestSplitCombineAllSubsets(t *testing.T) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+	fingerprint := []byte{1, 2, 3, 4}
+
+	for n := 2; n <= 6; n++ {
+		for threshold := 2; threshold <= n; threshold++ {
+			shares, err := SplitSecret(secret, n, threshold, fingerprint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for mask := 1; mask < 1<<n; mask++ {
+				var subset []*Share
+				for i := 0; i < n; i++ {
+					if mask&(1<<i) != 0 {
+						subset = append(subset, shares[i])
+					}
+				}
+
+				recovered, err := CombineShares(subset)
+				if len(subset) < threshold {
+					if err == nil {
+						t.Fatalf("%d-of-%d: %d个分片不应能恢复秘密", threshold, n, len(subset))
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("%d-of-%d: 分片组合%b: %v", threshold, n, mask, err)
+				}
+				if !bytes.Equal(recovered, secret) {
+					t.Fatalf("%d-of-%d: 分片组合%b恢复出错误的秘密", threshold, n, mask)
+				}
+
+				reversed := make([]*Share, len(subset))
+				for i, share := range subset {
+					reversed[len(subset)-1-i] = share
+				}
+				if recovered, err := CombineShares(reversed); err != nil || !bytes.Equal(recovered, secret) {
+					t.Fatalf("%d-of-%d: 分片组合%b倒序后恢复失败: %v", threshold, n, mask, err)
+				}
+			}
+		}
+	}
+}
+
+//TestCombineSharesRejectsMixedSets 不同备份的分片或重复的分片不能组合
+func TestCombineSharesRejectsMixedSets(t *testing.T) {
+	secret := []byte("secret")
+	a, err := SplitSecret(secret, 3, 2, []byte{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := SplitSecret(secret, 3, 2, []byte{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CombineShares([]*Share{a[0], b[1]}); err == nil {
+		t.Fatal("不同备份的分片组合成功")
+	}
+	if _, err := CombineShares([]*Share{a[0], a[0]}); err == nil {
+		t.Fatal("重复的分片组合成功")
+	}
+}
+
+//TestDecodeShareRoundTrip 分片编码为hex或单词后都能解码回原分片；秘密长度覆盖单词补零不足8位与达到8位两种情况
+func TestDecodeShareRoundTrip(t *testing.T) {
+	for size := 1; size <= 48; size++ {
+		secret := make([]byte, size)
+		if _, err := rand.Read(secret); err != nil {
+			t.Fatal(err)
+		}
+		shares, err := SplitSecret(secret, 3, 2, []byte{0, 0xff, 0, 0xff})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, share := range shares {
+			for _, encoded := range []string{share.Hex(), share.Words(), "  " + share.Words() + "\n"} {
+				decoded, err := DecodeShare(encoded)
+				if err != nil {
+					t.Fatalf("秘密长度%d: 解码%q: %v", size, encoded, err)
+				}
+				if !bytes.Equal(decoded.payload(), share.payload()) {
+					t.Fatalf("秘密长度%d: %q解码后与原分片不同", size, encoded)
+				}
+			}
+		}
+	}
+}
+
+//TestDecodeShareRejectsTypo 单词顺序错误或hex被改动时校验码不通过
+func TestDecodeShareRejectsTypo(t *testing.T) {
+	shares, err := SplitSecret([]byte("secret"), 2, 2, []byte{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	words := bytes.Fields([]byte(shares[0].Words()))
+	words[0], words[1] = words[1], words[0]
+	if _, err := DecodeShare(string(bytes.Join(words, []byte(" ")))); err == nil {
+		t.Fatal("单词顺序错误的分片解码成功")
+	}
+
+	encoded := []byte(shares[0].Hex())
+	encoded[len(encoded)-1] ^= 1
+	if _, err := DecodeShare(string(encoded)); err == nil {
+		t.Fatal("被改动的hex分片解码成功")
+	}
+}
@@ -0,0 +1,222 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/mr-tron/base58"
+)
+
+//stealthAddressVersion 隐身地址的版本号，隐身地址以Y开头
+//隐身地址为版本号||扫描公钥||花费公钥||校验码的Base58编码，两个公钥均为SEC1压缩格式
+const stealthAddressVersion = byte(0xa1)
+
+//stealthAddressLen 解码后隐身地址的长度
+const stealthAddressLen = 1 + 2*PubKeyCompressedLen + addressChecksumLen
+
+//StealthKeys 钱包中的一个隐身地址
+//扫描私钥与花费私钥作为StealthKey钱包保存在Wallets中（加密、锁定与备份同其他私钥），这里只记录其地址
+type StealthKeys struct {
+	ScanAddress  string
+	SpendAddress string
+	Height       int64  //已扫描完成的区块号，-1表示尚未扫描
+	Hash         []byte //区块号为Height的区块哈希，继续扫描时用于检查区块链是否发生了重组
+}
+
+//NewStealthAddress 生成扫描与花费两个P256私钥，加入钱包文件并返回隐身地址，之后需要调用SaveToFile保存
+//钱包文件已加密时必须先解锁
+func (ws *Wallets) NewStealthAddress() (string, error) {
+	scan, spend := NewWallet(), NewWallet()
+	scan.StealthKey, spend.StealthKey = true, true
+	for _, w := range []*Wallet{scan, spend} {
+		if err := ws.addWallet(w); err != nil {
+			return "", err
+		}
+	}
+
+	address := EncodeStealthAddress(scan.PublicKey, spend.PublicKey)
+	if ws.Stealth == nil {
+		ws.Stealth = make(map[string]*StealthKeys)
+	}
+	ws.Stealth[address] = &StealthKeys{ScanAddress: string(scan.GetAddress()), SpendAddress: string(spend.GetAddress()), Height: -1}
+
+	return address, nil
+}
+
+//StealthAddresses 返回钱包中的隐身地址，按地址排序
+func (ws *Wallets) StealthAddresses() []string {
+	var addresses []string
+	for address := range ws.Stealth {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	return addresses
+}
+
+//EncodeStealthAddress 将扫描公钥与花费公钥编码为隐身地址
+func EncodeStealthAddress(scanPubKey, spendPubKey []byte) string {
+	payload := append([]byte{stealthAddressVersion}, scanPubKey...)
+	payload = append(payload, spendPubKey...)
+	payload = append(payload, checksum(payload)...)
+
+	return string(Base58Encode(payload))
+}
+
+//DecodeStealthAddress 解码隐身地址，返回扫描公钥与花费公钥
+func DecodeStealthAddress(address string) (*ecdsa.PublicKey, *ecdsa.PublicKey, error) {
+	payload, err := base58.Decode(address)
+	if err != nil || len(payload) != stealthAddressLen || payload[0] != stealthAddressVersion {
+		return nil, nil, fmt.Errorf("ERROR: %s不是隐身地址", address)
+	}
+	body := payload[:len(payload)-addressChecksumLen]
+	if !bytes.Equal(checksum(body), payload[len(body):]) {
+		return nil, nil, fmt.Errorf("ERROR: 隐身地址%s的校验码错误", address)
+	}
+
+	scanPubKey, err := ParsePubKey(body[1 : 1+PubKeyCompressedLen])
+	if err != nil {
+		return nil, nil, err
+	}
+	spendPubKey, err := ParsePubKey(body[1+PubKeyCompressedLen:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return scanPubKey, spendPubKey, nil
+}
+
+//IsStealthAddress 检查是否为合法的隐身地址
+func IsStealthAddress(address string) bool {
+	_, _, err := DecodeStealthAddress(address)
+	return err == nil
+}
+
+//ValidateRecipient 检查收款地址是否合法：普通地址或隐身地址
+func ValidateRecipient(address string) bool {
+	return ValidateAddress(address) || IsStealthAddress(address)
+}
+
+//NewStealthPayment 为付给隐身地址address的一笔输出生成临时私钥r，返回一次性地址与临时公钥R=r·G
+//一次性公钥P=B+H(r·S)·G，S、B为扫描公钥与花费公钥；收款人由扫描私钥s算出相同的s·R=r·S，花费私钥为b+H(s·R)
+func NewStealthPayment(address string) (string, []byte, error) {
+	scanPubKey, spendPubKey, err := DecodeStealthAddress(address)
+	if err != nil {
+		return "", nil, err
+	}
+
+	ephemeral := NewWallet()
+	curve := elliptic.P256()
+	x, y := curve.ScalarMult(scanPubKey.X, scanPubKey.Y, ephemeral.PrivateKey.D.Bytes())
+	oneTime := stealthPubKey(spendPubKey, stealthTweak(x, y))
+
+	return string(AddressFromPubKey(oneTime)), ephemeral.PublicKey, nil
+}
+
+//ScanStealthOutput 检查携带临时公钥ephemeral、锁定到pubKeyHash的输出是否付给钱包中的隐身地址
+//是则将一次性地址的私钥加入钱包文件，返回一次性地址，之后需要调用SaveToFile保存；一次性地址已在钱包文件中时只返回地址，不是时返回空字符串
+//派生私钥需要扫描私钥与花费私钥，钱包文件已加密时必须先解锁
+func (ws *Wallets) ScanStealthOutput(ephemeral, pubKeyHash []byte) (string, error) {
+	if len(ws.Stealth) == 0 {
+		return "", nil
+	}
+	if ws.IsLocked() {
+		return "", ErrWalletLocked
+	}
+	if w := ws.FindByPubKeyHash(pubKeyHash); w != nil { //之前扫描时已经加入
+		if w.Stealth == "" {
+			return "", nil
+		}
+		return string(w.GetAddress()), nil
+	}
+	if len(ephemeral) != PubKeyCompressedLen || IsLegacyPubKey(ephemeral) {
+		return "", nil
+	}
+	ephemeralPubKey, err := ParsePubKey(ephemeral)
+	if err != nil {
+		return "", nil //不在曲线上的临时公钥不可能由发送者正确生成
+	}
+
+	curve := elliptic.P256()
+	for _, address := range ws.StealthAddresses() {
+		keys := ws.Stealth[address]
+		scan, spend := ws.Wallets[keys.ScanAddress], ws.Wallets[keys.SpendAddress]
+		if scan == nil || spend == nil {
+			return "", fmt.Errorf("ERROR: 钱包文件中缺少隐身地址%s的私钥", address)
+		}
+
+		x, y := curve.ScalarMult(ephemeralPubKey.X, ephemeralPubKey.Y, scan.PrivateKey.D.Bytes())
+		tweak := stealthTweak(x, y)
+		if !bytes.Equal(HashPubKey(stealthPubKey(&spend.PrivateKey.PublicKey, tweak)), pubKeyHash) {
+			continue
+		}
+
+		d := new(big.Int).Add(spend.PrivateKey.D, tweak)
+		d.Mod(d, curve.Params().N)
+		w := newWalletFromKey(d.FillBytes(make([]byte, privateKeyLen)))
+		w.Stealth = address
+		return ws.importKey(w)
+	}
+
+	return "", nil
+}
+
+//StealthHeight 返回全部隐身地址都已扫描完成的区块号，没有隐身地址时返回错误
+func (ws *Wallets) StealthHeight() (int64, error) {
+	if len(ws.Stealth) == 0 {
+		return 0, errors.New("ERROR: 钱包中没有隐身地址，请先用getnewstealthaddress创建")
+	}
+
+	height := int64(-1)
+	for i, address := range ws.StealthAddresses() {
+		if h := ws.Stealth[address].Height; i == 0 || h < height {
+			height = h
+		}
+	}
+
+	return height, nil
+}
+
+//SetStealthHeight 记录隐身地址address已扫描到区块号height，hash为该区块的哈希，之后需要调用SaveToFile保存
+func (ws *Wallets) SetStealthHeight(address string, height int64, hash []byte) {
+	if keys, ok := ws.Stealth[address]; ok {
+		keys.Height, keys.Hash = height, hash
+	}
+}
+
+//StealthReceived 返回由隐身地址address收款的一次性地址，按地址排序
+func (ws *Wallets) StealthReceived(address string) []string {
+	var addresses []string
+	for a, w := range ws.Wallets {
+		if w.Stealth == address {
+			addresses = append(addresses, a)
+		}
+	}
+	sort.Strings(addresses)
+
+	return addresses
+}
+
+//stealthTweak 由共享点(x, y)计算一次性私钥的偏移量H(x, y) mod N
+func stealthTweak(x, y *big.Int) *big.Int {
+	curve := elliptic.P256()
+	hash := sha256.Sum256(elliptic.MarshalCompressed(curve, x, y))
+	tweak := new(big.Int).SetBytes(hash[:])
+
+	return tweak.Mod(tweak, curve.Params().N)
+}
+
+//stealthPubKey 返回一次性公钥spend+tweak·G，SEC1压缩格式
+func stealthPubKey(spend *ecdsa.PublicKey, tweak *big.Int) []byte {
+	curve := elliptic.P256()
+	tx, ty := curve.ScalarBaseMult(tweak.FillBytes(make([]byte, privateKeyLen)))
+	x, y := curve.Add(spend.X, spend.Y, tx, ty)
+
+	return elliptic.MarshalCompressed(curve, x, y)
+}
@@ -0,0 +1,87 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+//txStoreFile 钱包未确认交易的文件名格式，%s为钱包名称
+const txStoreFile = "wallettx_%s.dat"
+
+// TxStore 保存钱包发出但尚未确认的交易（序列化后的字节），用于之后提高手续费等操作
+type TxStore struct {
+	Txs map[string][]byte
+}
+
+// NewTxStore 从钱包name的交易文件读取生成TxStore
+func NewTxStore(name string) (*TxStore, error) {
+	store := TxStore{}
+	store.Txs = make(map[string][]byte)
+
+	err := store.LoadFromFile(name)
+
+	return &store, err
+}
+
+// Put 保存一笔交易
+func (s *TxStore) Put(txID string, tx []byte) {
+	s.Txs[txID] = tx
+}
+
+// Get 返回一笔交易
+func (s *TxStore) Get(txID string) ([]byte, bool) {
+	tx, ok := s.Txs[txID]
+	return tx, ok
+}
+
+// Delete 删除一笔交易
+func (s *TxStore) Delete(txID string) {
+	delete(s.Txs, txID)
+}
+
+// LoadFromFile 从文件读取TxStore
+func (s *TxStore) LoadFromFile(name string) error {
+	storeFile := WalletPath(txStoreFile, name)
+	if _, err := os.Stat(storeFile); err != nil {
+		return err
+	}
+
+	fileContent, err := ioutil.ReadFile(storeFile)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var store TxStore
+	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
+	err = decoder.Decode(&store)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if store.Txs != nil { //空的map不会被gob编码
+		s.Txs = store.Txs
+	}
+
+	return nil
+}
+
+// SaveToFile 保存TxStore到文件
+func (s TxStore) SaveToFile(name string) {
+	var content bytes.Buffer
+
+	storeFile := WalletPath(txStoreFile, name)
+
+	encoder := gob.NewEncoder(&content)
+	err := encoder.Encode(s)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = ioutil.WriteFile(storeFile, content.Bytes(), 0644)
+	if err != nil {
+		log.Panic(err)
+	}
+}
@@ -0,0 +1,253 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//base58Alphabet 地址使用的Base58字母表，没有0、O、I、l
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+//maxVanityPrefix 前缀的最大长度，不区分大小写时需要逐个计算各种大小写组合的概率
+const maxVanityPrefix = 12
+
+//VanityPattern 靓号地址的匹配条件：地址以Prefix开头、以Suffix结尾
+type VanityPattern struct {
+	Prefix     string
+	Suffix     string
+	IgnoreCase bool //不区分大小写
+	Secp256k1  bool //生成secp256k1私钥的地址（以S开头），否则为P256私钥的地址（以F开头）
+}
+
+//VanityOptions 靓号地址搜索的选项
+type VanityOptions struct {
+	Workers  int                   //并行生成私钥的协程数
+	Stop     <-chan struct{}       //关闭时放弃搜索
+	Progress func(attempts uint64) //每秒以已尝试的次数调用一次，可以为nil
+}
+
+//version 匹配的地址的版本号
+func (p *VanityPattern) version() byte {
+	if p.Secp256k1 {
+		return secp256k1AddressVersion
+	}
+	return addressVersion
+}
+
+//Validate 校验前缀与后缀只包含地址可能出现的字符，且前缀与地址的版本号相容
+func (p *VanityPattern) Validate() error {
+	if p.Prefix == "" && p.Suffix == "" {
+		return errors.New("ERROR: 需要指定前缀或后缀")
+	}
+	if len(p.Prefix) > maxVanityPrefix {
+		return fmt.Errorf("ERROR: 前缀不能超过%d个字符", maxVanityPrefix)
+	}
+	for _, s := range []string{p.Prefix, p.Suffix} {
+		for _, c := range s {
+			if len(p.variants(c)) == 0 {
+				return fmt.Errorf("ERROR: 字符%q不会出现在地址中，Base58不使用0、O、I、l", c)
+			}
+		}
+	}
+	if p.Prefix != "" && p.prefixProbability() == 0 {
+		lo, hi := versionRange(p.version())
+		last := new(big.Int).Sub(hi, big.NewInt(1))
+		return fmt.Errorf("ERROR: 地址在%s...与%s...之间，不可能以%s开头", Base58Encode(lo.Bytes())[:3], Base58Encode(last.Bytes())[:3], p.Prefix)
+	}
+
+	return nil
+}
+
+//Match 地址是否匹配
+func (p *VanityPattern) Match(address string) bool {
+	if p.IgnoreCase {
+		address = strings.ToLower(address)
+		return strings.HasPrefix(address, strings.ToLower(p.Prefix)) && strings.HasSuffix(address, strings.ToLower(p.Suffix))
+	}
+	return strings.HasPrefix(address, p.Prefix) && strings.HasSuffix(address, p.Suffix)
+}
+
+//Probability 随机生成的一个地址匹配的概率
+//前缀决定地址的高位，按版本号下全部地址的取值范围精确计算；后缀由校验码决定，每个字符按均匀分布计算
+func (p *VanityPattern) Probability() float64 {
+	probability := 1.0
+	if p.Prefix != "" {
+		probability = p.prefixProbability()
+	}
+	for _, c := range p.Suffix {
+		probability *= float64(len(p.variants(c))) / float64(len(base58Alphabet))
+	}
+
+	return probability
+}
+
+//Difficulty 平均需要尝试的次数
+func (p *VanityPattern) Difficulty() float64 {
+	return 1 / p.Probability()
+}
+
+//variants 字符c在地址中可能的写法，不区分大小写时包括大写与小写
+func (p *VanityPattern) variants(c rune) []byte {
+	candidates := string(c)
+	if p.IgnoreCase {
+		candidates = strings.ToLower(candidates) + strings.ToUpper(candidates)
+	}
+	var variants []byte
+	for _, v := range []byte(candidates) {
+		if strings.IndexByte(base58Alphabet, v) >= 0 && !strings.Contains(string(variants), string(v)) {
+			variants = append(variants, v)
+		}
+	}
+
+	return variants
+}
+
+//prefixProbability 地址以前缀（的任一大小写组合）开头的概率：前缀对应的地址区间与版本号下全部地址的区间之交占后者的比例
+func (p *VanityPattern) prefixProbability() float64 {
+	lo, hi := versionRange(p.version())
+	length := len(Base58Encode(new(big.Int).Sub(hi, big.NewInt(1)).Bytes())) //版本号下全部地址的长度相同
+	scale := new(big.Int).Exp(big.NewInt(int64(len(base58Alphabet))), big.NewInt(int64(length-len(p.Prefix))), nil)
+
+	matched := new(big.Int)
+	prefixes := []string{""}
+	for _, c := range p.Prefix {
+		var next []string
+		for _, prefix := range prefixes {
+			for _, v := range p.variants(c) {
+				next = append(next, prefix+string(v))
+			}
+		}
+		prefixes = next
+	}
+	for _, prefix := range prefixes {
+		value := big.NewInt(0)
+		for _, c := range []byte(prefix) {
+			value.Mul(value, big.NewInt(int64(len(base58Alphabet))))
+			value.Add(value, big.NewInt(int64(strings.IndexByte(base58Alphabet, c))))
+		}
+		start := new(big.Int).Mul(value, scale)
+		end := new(big.Int).Add(start, scale)
+		if start.Cmp(lo) < 0 {
+			start = lo
+		}
+		if end.Cmp(hi) > 0 {
+			end = hi
+		}
+		if end.Cmp(start) > 0 {
+			matched.Add(matched, new(big.Int).Sub(end, start))
+		}
+	}
+
+	total := new(big.Int).Sub(hi, lo)
+	probability, _ := new(big.Rat).SetFrac(matched, total).Float64()
+	return probability
+}
+
+//versionRange 版本号addrVersion的地址（版本号||公钥哈希||校验码）作为整数的取值范围[lo, hi)
+func versionRange(addrVersion byte) (*big.Int, *big.Int) {
+	bits := uint(8 * (20 + addressChecksumLen))
+	lo := new(big.Int).Lsh(big.NewInt(int64(addrVersion)), bits)
+	hi := new(big.Int).Lsh(big.NewInt(int64(addrVersion)+1), bits)
+
+	return lo, hi
+}
+
+//ExpectedTime 以每秒rate个地址的速度，找到靓号地址的平均用时与有50%概率找到的用时
+func (p *VanityPattern) ExpectedTime(rate float64) (time.Duration, time.Duration) {
+	seconds := p.Difficulty() / rate
+	toDuration := func(s float64) time.Duration {
+		if s*float64(time.Second) >= math.MaxInt64 {
+			return time.Duration(math.MaxInt64)
+		}
+		return time.Duration(s * float64(time.Second))
+	}
+
+	return toDuration(seconds), toDuration(seconds * math.Ln2)
+}
+
+//newVanityWallet 随机生成一个候选钱包
+func (p *VanityPattern) newVanityWallet() *Wallet {
+	if p.Secp256k1 {
+		return NewSecp256k1Wallet()
+	}
+	return NewWallet()
+}
+
+//VanityRate 估计workers个协程每秒可以生成的地址数，用于搜索前估计用时，协程数超过CPU核数时按核数计算
+func (p *VanityPattern) VanityRate(workers int) float64 {
+	const samples = 200
+	if workers > runtime.NumCPU() {
+		workers = runtime.NumCPU()
+	}
+	start := time.Now()
+	for i := 0; i < samples; i++ {
+		p.Match(string(p.newVanityWallet().GetAddress()))
+	}
+
+	return float64(samples) / time.Since(start).Seconds() * float64(workers)
+}
+
+//SearchVanity 并行随机生成私钥，直到地址匹配pattern，返回该钱包；opts.Stop关闭时放弃搜索，返回nil
+func SearchVanity(pattern *VanityPattern, opts VanityOptions) *Wallet {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+
+	var attempts uint64
+	var found *Wallet
+	var once sync.Once
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				w := pattern.newVanityWallet()
+				atomic.AddUint64(&attempts, 1)
+				if pattern.Match(string(w.GetAddress())) {
+					once.Do(func() {
+						found = w
+						close(done)
+					})
+					return
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			wg.Wait()
+			return found
+		case <-opts.Stop:
+			once.Do(func() { close(done) })
+			wg.Wait()
+			return found
+		case <-ticker.C:
+			if opts.Progress != nil {
+				opts.Progress(atomic.LoadUint64(&attempts))
+			}
+		}
+	}
+}
+
+//ImportVanityWallet 将靓号地址的钱包加入钱包文件，之后需要调用SaveToFile保存；钱包已加密时必须先解锁
+func (ws *Wallets) ImportVanityWallet(w *Wallet) (string, error) {
+	return ws.importKey(w)
+}
@@ -6,34 +6,109 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
+	"math/big"
+	"strings"
 
+	"github.com/mr-tron/base58"
 	"golang.org/x/crypto/ripemd160"
 )
 
-const version = byte(0x00) //钱包版本，一个字节
+const version = byte(0x00) //钱包版本，一个字节，旧版本编码的公钥的地址使用该版本号
+
+const addressVersion = byte(0x23) //SEC1编码的公钥的地址版本号，地址以F开头
 
 const addressChecksumLen = 4
 
 //Wallet 钱包保存公钥和私钥对
 type Wallet struct {
-	PrivateKey ecdsa.PrivateKey
-	PublicKey  []byte
+	PrivateKey   ecdsa.PrivateKey
+	PublicKey    []byte
+	EncryptedKey []byte //钱包文件加密后保存加密的私钥，PrivateKey.D在解锁前为nil
+	HDPath       string //由种子派生的钱包的派生路径，随机生成的钱包为空
+	Change       bool   //找零地址
+	Account      string //找零地址所属的账户（发送交易的地址）
+	WatchOnly    bool   //只读地址：没有私钥，只用于查询余额与交易记录
+	WatchHash    []byte //只导入地址时保存的公钥哈希，此时PublicKey为空
+	WatchVersion byte   //只导入地址时保存的地址版本号
+	Label        string //地址的标签
+	Secp256k1    bool   //私钥在secp256k1曲线上，否则为P256
+	StealthKey   bool   //隐身地址的扫描私钥或花费私钥，其地址不用于收款
+	Stealth      string //扫描区块找到的一次性地址所属的隐身地址
 }
 
 // NewWallet 创建并返回一个钱包
 func NewWallet() *Wallet {
 	private, public := newKeyPair()
-	wallet := Wallet{private, public}
+	wallet := Wallet{PrivateKey: private, PublicKey: public}
 
 	return &wallet
 }
 
+// NewWalletFromPrivateKey 由hex编码的私钥恢复钱包
+func NewWalletFromPrivateKey(privateKey string) (*Wallet, error) {
+	d, err := hex.DecodeString(strings.TrimPrefix(privateKey, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: 私钥格式错误: %v", err)
+	}
+
+	curve := elliptic.P256()
+	k := new(big.Int).SetBytes(d)
+	if k.Sign() == 0 || k.Cmp(curve.Params().N) >= 0 {
+		return nil, errors.New("ERROR: 私钥超出范围")
+	}
+
+	private := ecdsa.PrivateKey{D: k}
+	private.PublicKey.Curve = curve
+	private.PublicKey.X, private.PublicKey.Y = curve.ScalarBaseMult(k.Bytes())
+
+	return &Wallet{PrivateKey: private, PublicKey: MarshalPubKey(&private.PublicKey)}, nil
+}
+
 // GetAddress 返回钱包地址（可为人识别的地址）
 func (w Wallet) GetAddress() []byte {
-	pubKeyHash := HashPubKey(w.PublicKey)
+	if len(w.PublicKey) == 0 {
+		return encodeAddress(w.WatchVersion, w.WatchHash)
+	}
+	if w.Secp256k1 {
+		return encodeAddress(secp256k1AddressVersion, HashPubKey(w.PublicKey))
+	}
+	return AddressFromPubKey(w.PublicKey)
+}
+
+//IsLegacy 钱包的公钥是否为旧版本的编码
+func (w Wallet) IsLegacy() bool {
+	return len(w.PublicKey) > 0 && IsLegacyPubKey(w.PublicKey)
+}
 
-	versionedPayload := append([]byte{version}, pubKeyHash...)
+//AddressFromPubKey 返回P256公钥对应的地址，旧版本编码的公钥使用旧的地址版本号
+func AddressFromPubKey(pubKey []byte) []byte {
+	if IsLegacyPubKey(pubKey) {
+		return encodeAddress(version, HashPubKey(pubKey))
+	}
+	return encodeAddress(addressVersion, HashPubKey(pubKey))
+}
+
+//GetPubKeyHash 返回钱包的公钥哈希，只导入了地址的只读钱包返回导入的公钥哈希
+func (w Wallet) GetPubKeyHash() []byte {
+	if len(w.PublicKey) == 0 {
+		return w.WatchHash
+	}
+	return HashPubKey(w.PublicKey)
+}
+
+// PubKeyHashToAddress 将公钥哈希编码为Base58地址
+//只知道公钥哈希时无法区分公钥的编码，使用新的地址版本号，两种版本号的地址对应同一个公钥哈希
+func PubKeyHashToAddress(pubKeyHash []byte) []byte {
+	return encodeAddress(addressVersion, pubKeyHash)
+}
+
+//encodeAddress 将版本号与公钥哈希编码为Base58地址
+func encodeAddress(addrVersion byte, pubKeyHash []byte) []byte {
+	versionedPayload := append([]byte{addrVersion}, pubKeyHash...)
 	checksum := checksum(versionedPayload)
 
 	fullPayload := append(versionedPayload, checksum...)
@@ -56,13 +131,27 @@ func HashPubKey(pubKey []byte) []byte {
 	return publicRIPEMD160
 }
 
+// AddressToPubKeyHash 反编码Base58地址，去掉版本号与校验码后得到公钥哈希
+func AddressToPubKeyHash(address []byte) []byte {
+	fullPayload := Base58Decode(address)
+
+	return fullPayload[1 : len(fullPayload)-addressChecksumLen]
+}
+
 // ValidateAddress 检查地址是否合法
+//非法字符或长度不足时返回false而不是panic：批量付款逐笔校验文件中的地址，需要报告是第几笔非法
 func ValidateAddress(address string) bool {
-	pubKeyHash := Base58Decode([]byte(address))
+	pubKeyHash, err := base58.Decode(address)
+	if err != nil || len(pubKeyHash) <= 1+addressChecksumLen { //非法字符或长度不足
+		return false
+	}
 	actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
-	version := pubKeyHash[0]
+	addrVersion := pubKeyHash[0]
+	if addrVersion != version && addrVersion != addressVersion && addrVersion != secp256k1AddressVersion { //未知的地址版本号
+		return false
+	}
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
-	targetChecksum := checksum(append([]byte{version}, pubKeyHash...))
+	targetChecksum := checksum(append([]byte{addrVersion}, pubKeyHash...))
 
 	return bytes.Compare(actualChecksum, targetChecksum) == 0
 }
@@ -84,8 +173,8 @@ func newKeyPair() (ecdsa.PrivateKey, []byte) {
 	}
 
 	//从私钥生成一个公钥
-	//在基于椭圆曲线的算法中，公钥是曲线上的点，因此，公钥是 X，Y 坐标的组合
-	pubKey := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
+	//在基于椭圆曲线的算法中，公钥是曲线上的点，编码为SEC1压缩格式：Y的奇偶||定长的X
+	pubKey := MarshalPubKey(&private.PublicKey)
 
 	return *private, pubKey
 }
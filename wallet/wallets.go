@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"crypto/elliptic"
 	"encoding/gob"
 	"encoding/hex"
@@ -9,31 +10,57 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
-const walletFile = "./tmp/wallet/wallet_%s.dat"
+//walletFile 钱包文件名格式，%s为钱包名称，保存在数据目录DataDir中
+const walletFile = "wallet_%s.dat"
 
 // Wallets 保存钱包集合
 type Wallets struct {
-	Wallets map[string]*Wallet
+	Wallets  map[string]*Wallet
+	Crypto   *WalletCrypto           //为nil时钱包文件未加密
+	HD       *HDChain                //为nil时每个钱包的私钥随机生成
+	Contacts map[string]string       //通讯录，联系人名称到地址
+	Stealth  map[string]*StealthKeys //隐身地址，见stealth.go
+
+	file string //钱包文件名（目录形式的钱包为目录名），用于查找已解锁的主密钥
+
+	dir         string //目录形式的钱包的目录，为空时使用单个钱包文件，见keystore.go
+	keystoreKey []byte //目录形式的钱包尚未解锁时，由ChangePassphrase等从新密码派生的keystore文件密钥
+	rewrite     bool   //修改密码后需要重写全部keystore文件
 }
 
-// NewWallets 从文件读取生成Wallets
-func NewWallets(nodeID string) (*Wallets, error) {
+// NewWallets 从数据目录中名称为name的钱包文件读取生成Wallets
+func NewWallets(name string) (*Wallets, error) {
 	wallets := Wallets{}
 	wallets.Wallets = make(map[string]*Wallet)
+	wallets.Contacts = make(map[string]string)
+	wallets.file = WalletPath(walletFile, name)
 
-	err := wallets.LoadFromFile(nodeID)
+	err := wallets.LoadFromFile(name)
 
 	return &wallets, err
 }
 
 // CreateWallet 添加一个钱包到Wallets
+// 分层确定性钱包从收款链派生下一个地址，否则随机生成私钥
+// 钱包文件已加密时新私钥用主密钥加密，因此必须先解锁
 func (ws *Wallets) CreateWallet() string {
+	if ws.IsHD() {
+		address, err := ws.NewAddress(ReceiveChain)
+		if err != nil {
+			log.Panic(err)
+		}
+		return address
+	}
+
 	wallet := NewWallet()
 	address := fmt.Sprintf("%s", wallet.GetAddress())
-
-	ws.Wallets[address] = wallet
+	if err := ws.addWallet(wallet); err != nil {
+		log.Panic(err)
+	}
 
 	return address
 }
@@ -55,8 +82,13 @@ func (ws Wallets) GetWallet(address string) Wallet {
 }
 
 // LoadFromFile 从文件读取wallets
-func (ws *Wallets) LoadFromFile(nodeID string) error {
-	walletFile := fmt.Sprintf(walletFile, nodeID)
+// 钱包已转换为目录形式时从keystore目录读取
+func (ws *Wallets) LoadFromFile(name string) error {
+	if dir := WalletPath(keystoreDir, name); isDir(dir) {
+		return ws.loadKeystoreDir(dir)
+	}
+
+	walletFile := WalletPath(walletFile, name)
 	if _, err := os.Stat(walletFile); os.IsNotExist(err) {
 		return err
 	}
@@ -65,74 +97,119 @@ func (ws *Wallets) LoadFromFile(nodeID string) error {
 	if err != nil {
 		log.Panic(err)
 	}
+	ws.decode(fileContent, walletFile)
+
+	return nil
+}
 
+//decode 解码gob编码的钱包文件内容，file为钱包文件名，钱包已在本进程中解锁时自动解密私钥
+func (ws *Wallets) decode(fileContent []byte, file string) {
 	var wallets Wallets
 
 	gob.Register(elliptic.P256())
+	gob.Register(crypto.S256()) //只用于读取旧版本保存了secp256k1曲线的钱包文件
 	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
-	err = decoder.Decode(&wallets)
+	err := decoder.Decode(&wallets)
 	if err != nil {
 		log.Panic(err)
 	}
+	for _, w := range wallets.Wallets {
+		if w.Secp256k1 {
+			if err := w.restoreSecp256k1Key(); err != nil {
+				log.Panic(err)
+			}
+		}
+	}
 
-	ws.Wallets = wallets.Wallets
-
-	return nil
+	if wallets.Wallets != nil { //新建的空钱包文件
+		ws.Wallets = wallets.Wallets
+	}
+	ws.Crypto = wallets.Crypto
+	ws.HD = wallets.HD
+	if wallets.Contacts != nil { //空的map不会被gob编码
+		ws.Contacts = wallets.Contacts
+	}
+	ws.Stealth = wallets.Stealth
+	ws.file = file
+
+	//钱包已在本进程中解锁时自动解密私钥
+	if key := lookupKey(file); ws.IsEncrypted() && key != nil {
+		defer wipe(key)
+		if err := ws.decryptAll(key); err != nil {
+			log.Panic(err)
+		}
+	}
 }
 
 // SaveToFile 保存wallets到文件
-func (ws Wallets) SaveToFile(nodeID string) {
-	var content bytes.Buffer
+// 目录形式的钱包每个私钥保存为一个keystore文件，其余内容保存在目录中的索引文件
+func (ws Wallets) SaveToFile(name string) {
+	if ws.IsKeystoreDir() {
+		ws.saveKeystoreDir()
+		return
+	}
+
+	walletFile := WalletPath(walletFile, name)
+	err := ioutil.WriteFile(walletFile, ws.encode(), 0600)
+	if err != nil {
+		log.Panic(err)
+	}
+}
 
-	walletFile := fmt.Sprintf(walletFile, nodeID)
+//encode gob编码钱包文件的内容
+func (ws Wallets) encode() []byte {
+	var content bytes.Buffer
 
 	//Wallet的PrivateKey的结构体类型逐层分析下去，有一个结构体字段是priv.PublicKey.Curve，
 	//其类型是elliptic.Curve，而elliptic.Curve是一个interface，实际上在产生wallet时候，
 	//传递的具体实现类型是curve := elliptic.P256()
 	gob.Register(elliptic.P256())
 
+	//加密的钱包文件只保存加密后的私钥
+	//secp256k1的私钥只保存D，不保存曲线与公钥坐标：crypto.S256()的具体类型随是否启用cgo而不同，读取时由D或公钥重建
+	plain := ws.Wallets
+	ws.Wallets = make(map[string]*Wallet, len(plain))
+	for address, w := range plain {
+		if ws.IsEncrypted() && len(w.EncryptedKey) == 0 && !w.WatchOnly {
+			log.Panic(fmt.Errorf("ERROR: 地址%s的私钥没有加密", address))
+		}
+		stored := *w
+		if ws.IsEncrypted() {
+			stored.PrivateKey.D = nil
+		}
+		if w.Secp256k1 {
+			stored.PrivateKey.PublicKey = ecdsa.PublicKey{}
+		}
+		ws.Wallets[address] = &stored
+	}
+	if ws.IsEncrypted() && ws.IsHD() {
+		hd := *ws.HD
+		hd.Seed = nil
+		ws.HD = &hd
+	}
+
 	encoder := gob.NewEncoder(&content)
 	err := encoder.Encode(ws)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	err = ioutil.WriteFile(walletFile, content.Bytes(), 0644)
-	if err != nil {
-		log.Panic(err)
-	}
+	return content.Bytes()
 }
 
 //通过私钥加载公钥与地址
-func (ws Wallets) LoadPrivate(private string, nodeID string) (string, string) {
-	walletFile := fmt.Sprintf(walletFile, nodeID)
-	if _, err := os.Stat(walletFile); os.IsNotExist(err) {
+func (ws Wallets) LoadPrivate(private string, name string) (string, string) {
+	if err := ws.LoadFromFile(name); err != nil { //已加密的钱包需要先解锁才能按私钥查找
 		return "", ""
 	}
 
-	fileContent, err := ioutil.ReadFile(walletFile)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	var wallets Wallets
-
-	gob.Register(elliptic.P256())
-	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
-	err = decoder.Decode(&wallets)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	ws.Wallets = wallets.Wallets
-
 	privatekey, err := hex.DecodeString(private)
 	if err != nil {
 		log.Panic(err)
 	}
 
 	for _, value := range ws.Wallets {
-		if bytes.Equal(value.PrivateKey.D.Bytes(), privatekey) {
+		if value.PrivateKey.D != nil && bytes.Equal(value.PrivateKey.D.Bytes(), privatekey) {
 			return hex.EncodeToString(value.PublicKey), string(value.GetAddress())
 		}
 	}
@@ -0,0 +1,64 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//ErrWatchOnly 只读地址没有私钥，不能签名
+var ErrWatchOnly = errors.New("ERROR: 只读地址没有私钥，不能签名，请在持有私钥的钱包中签名")
+
+//CanSign 钱包能否签名：只读地址返回ErrWatchOnly，已锁定返回ErrWalletLocked
+func (w Wallet) CanSign() error {
+	if w.WatchOnly {
+		return ErrWatchOnly
+	}
+	if w.IsLocked() {
+		return ErrWalletLocked
+	}
+	return nil
+}
+
+//ImportAddress 将地址address作为只读地址加入钱包文件，之后需要调用SaveToFile保存
+func (ws *Wallets) ImportAddress(address string) error {
+	if !ValidateAddress(address) {
+		return errors.New("ERROR: 地址非法")
+	}
+	w := NewWatchWallet(address)
+	if ws.FindByPubKeyHash(w.WatchHash) != nil {
+		return fmt.Errorf("ERROR: 地址%s已在钱包文件中", address)
+	}
+	ws.Wallets[address] = w
+
+	return nil
+}
+
+//NewWatchWallet 由合法的地址构造只读钱包，只有公钥哈希，没有公钥与私钥
+func NewWatchWallet(address string) *Wallet {
+	return &Wallet{WatchOnly: true, WatchHash: AddressToPubKeyHash([]byte(address)), WatchVersion: Base58Decode([]byte(address))[0]}
+}
+
+//ImportPubKey 将hex编码的公钥作为只读地址加入钱包文件，返回对应的地址，之后需要调用SaveToFile保存
+//地址已作为只读地址导入时补充其公钥
+func (ws *Wallets) ImportPubKey(pubKey string) (string, error) {
+	public, err := hex.DecodeString(strings.TrimPrefix(pubKey, "0x"))
+	if err != nil {
+		return "", fmt.Errorf("ERROR: 公钥格式错误: %v", err)
+	}
+	if _, err := ParsePubKey(public); err != nil {
+		return "", err
+	}
+
+	address := string(AddressFromPubKey(public))
+	if w := ws.FindByPubKeyHash(HashPubKey(public)); w != nil {
+		if !w.WatchOnly || len(w.PublicKey) > 0 {
+			return "", fmt.Errorf("ERROR: 地址%s已在钱包文件中", address)
+		}
+		delete(ws.Wallets, string(w.GetAddress()))
+	}
+	ws.Wallets[address] = &Wallet{PublicKey: public, WatchOnly: true}
+
+	return address, nil
+}